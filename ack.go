@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const ackPrefix = "ack:"
+
+func ackKeyboard(height int) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Принято 👍", ackPrefix+strconv.Itoa(height)),
+	))
+}
+
+// acknowledge records that the chat of msg has read the notification about
+// the block at height and removes the button. It returns the text shown to
+// the user.
+func (r *commandRouter) acknowledge(msg *tgbotapi.Message, height string) string {
+	h, err := strconv.Atoi(height)
+	if err != nil {
+		return ""
+	}
+
+	if err := r.store.RecordAck(msg.Chat.ID, h); err != nil {
+		log.Printf("error: record ack of %s for block %d: %s", chatRef(msg.Chat.ID), h, err.Error())
+		return "Не удалось сохранить отметку"
+	}
+
+	edit := tgbotapi.NewEditMessageReplyMarkup(msg.Chat.ID, msg.MessageID, tgbotapi.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{},
+	})
+	if _, err := r.bot.Request(edit); err != nil {
+		log.Printf("error: remove ack button: %s", err.Error())
+	}
+
+	return "Спасибо!"
+}
+
+// cmdAckStats implements /ackstats [height], defaulting to the last block
+// of every pool.
+func (r *commandRouter) cmdAckStats(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	var heights []int
+	if arg := msg.CommandArguments(); arg != "" {
+		h, err := strconv.Atoi(arg)
+		if err != nil {
+			return reply(msg, "Использование: /ackstats [высота]")
+		}
+		heights = append(heights, h)
+	} else {
+		for _, pool := range r.notifier.pools {
+			if b := r.notifier.lastBlocks.getLastBlock(pool.Name); b.height != 0 {
+				heights = append(heights, b.height)
+			}
+		}
+	}
+
+	if len(heights) == 0 {
+		return reply(msg, "Блоков пока не было")
+	}
+
+	text := ""
+	for _, h := range heights {
+		acked, total, err := r.store.GetAckStats(h)
+		if err != nil {
+			log.Printf("error: ack stats for block %d: %s", h, err.Error())
+			return reply(msg, "Не удалось получить статистику")
+		}
+		text += fmt.Sprintf("Блок %d: прочитали %d из %d\n", h, acked, total)
+	}
+
+	return reply(msg, text)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// defaultAliases map the commands users tend to guess, and /start typed
+// in Russian or on a Russian keyboard layout, to the real ones.
+var defaultAliases = map[string]string{
+	"stop":   "unsubscribe",
+	"info":   "pools",
+	"status": "pools",
+	"старт":  "start",
+	"стоп":   "unsubscribe",
+	"ыефке":  "start",
+}
+
+// maxSuggestionDistance is the largest edit distance from an unknown
+// command to a real one that is still suggested.
+const maxSuggestionDistance = 2
+
+// commandName is the command msg starts with, lowercased. Telegram only
+// marks Latin commands as such, so Cyrillic ones are parsed from the text.
+func commandName(msg *tgbotapi.Message) string {
+	if name := msg.Command(); name != "" {
+		return strings.ToLower(name)
+	}
+	if !strings.HasPrefix(msg.Text, "/") {
+		return ""
+	}
+
+	name, _, _ := strings.Cut(strings.Fields(msg.Text)[0][1:], "@")
+	return strings.ToLower(name)
+}
+
+// buildAliases adds the aliases of the config to the defaults, skipping
+// ones that point at unknown commands.
+func (r *commandRouter) buildAliases(extra map[string]string) map[string]string {
+	aliases := make(map[string]string, len(defaultAliases)+len(extra))
+	for alias, name := range defaultAliases {
+		aliases[alias] = name
+	}
+	for alias, name := range extra {
+		alias, name = strings.ToLower(strings.TrimPrefix(alias, "/")), strings.ToLower(strings.TrimPrefix(name, "/"))
+		if r.commands[name] == nil && r.adminCommands[name] == nil {
+			log.Printf("warning: CommandAliases: /%s points at unknown command /%s, ignored", alias, name)
+			continue
+		}
+		aliases[alias] = name
+	}
+	return aliases
+}
+
+// suggest returns the command closest to name, if any is close enough.
+// Admin commands are only suggested to admins.
+func (r *commandRouter) suggest(name string, admin bool) (string, bool) {
+	best, bestDistance := "", maxSuggestionDistance+1
+	consider := func(candidate string) {
+		if d := editDistance(name, candidate); d < bestDistance || (d == bestDistance && candidate < best) {
+			best, bestDistance = candidate, d
+		}
+	}
+	for candidate := range r.commands {
+		consider(candidate)
+	}
+	if admin {
+		for candidate := range r.adminCommands {
+			consider(candidate)
+		}
+	}
+	return best, best != ""
+}
+
+// editDistance is the Levenshtein distance between a and b in runes.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func cmdSuggestion(name, suggestion string) commandFunc {
+	return func(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+		return reply(msg, fmt.Sprintf("Неизвестная команда /%s. Возможно, вы имели в виду /%s?", sanitize(name), suggestion))
+	}
+}
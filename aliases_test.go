@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"start", "start", 0},
+		{"strat", "start", 2},
+		{"unsubscrbe", "unsubscribe", 1},
+		{"", "pools", 5},
+		// Distances count runes, not bytes.
+		{"старт", "стар", 1},
+		{"старт", "start", 5},
+		{"пулы", "пуль", 1},
+		{"ыефке", "ыефкеы", 1},
+	}
+
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := editDistance(tt.b, tt.a); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+// textMessage is msg without command entities, as Telegram sends
+// commands that aren't in Latin letters.
+func textMessage(id int64, text string) *tgbotapi.Message {
+	return &tgbotapi.Message{
+		MessageID: 1,
+		From:      &tgbotapi.User{ID: id},
+		Chat:      &tgbotapi.Chat{ID: id, Type: "private"},
+		Text:      text,
+	}
+}
+
+func TestRouteAliasesAndSuggestions(t *testing.T) {
+	const user, admin = 1, 2
+	conf := config{
+		AdminIDs:       []int64{admin},
+		CommandAliases: map[string]string{"/bye": "/unsubscribe", "nowhere": "nosuch"},
+	}
+
+	tests := []struct {
+		name      string
+		msg       *tgbotapi.Message
+		wantRoute string
+		// wantReply is in the reply of a suggestion.
+		wantReply string
+	}{
+		{name: "canonical", msg: command(user, "/pools"), wantRoute: "pools"},
+		{name: "alias", msg: command(user, "/stop"), wantRoute: "unsubscribe"},
+		{name: "alias in capitals", msg: command(user, "/STOP"), wantRoute: "unsubscribe"},
+		{name: "configured alias", msg: command(user, "/bye"), wantRoute: "unsubscribe"},
+		{name: "alias to unknown command", msg: command(user, "/nowhere"), wantRoute: "start"},
+		{name: "cyrillic alias", msg: textMessage(user, "/старт"), wantRoute: "start"},
+		{name: "wrong layout", msg: textMessage(user, "/ыефке"), wantRoute: "start"},
+		{name: "cyrillic alias with bot name", msg: textMessage(user, "/стоп@test_bot"), wantRoute: "unsubscribe"},
+		{name: "typo", msg: command(user, "/unsubscrbe"), wantRoute: "unknown", wantReply: "/unsubscribe?"},
+		// The o's are Cyrillic: two runes off, four bytes.
+		{name: "mixed scripts", msg: textMessage(user, "/pооls"), wantRoute: "unknown", wantReply: "/pools?"},
+		{name: "admin command hidden", msg: command(user, "/stat"), wantRoute: "unknown", wantReply: "/start?"},
+		{name: "admin command suggested", msg: command(admin, "/statss"), wantRoute: "unknown", wantReply: "/stats?"},
+		{name: "nothing close", msg: command(user, "/xyzzyplugh"), wantRoute: "start"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t)
+			n := newTestNotifier(t, conf, store)
+			r := newCommandRouter(nil, store, n, n.usage, conf)
+
+			name, cmd := r.route(tt.msg)
+			if name != tt.wantRoute {
+				t.Fatalf("routed to %q, want %q", name, tt.wantRoute)
+			}
+			if tt.wantReply == "" {
+				return
+			}
+
+			// A suggestion only answers, it never runs the command.
+			text := cmd(tt.msg).Text
+			if !strings.Contains(text, tt.wantReply) {
+				t.Errorf("reply = %q, lacks %q", text, tt.wantReply)
+			}
+			if count, _ := store.Count(); count != 0 {
+				t.Errorf("a suggestion subscribed the chat")
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	defaultPoolName = "mini"
+	blocksURL       = "https://p2pool.io/mini/api/pool/blocks"
+)
+
+var errUnexpectedStructure = errors.New("unexpected response structure")
+
+// apiClient fetches the pool blocks. main replaces it with newAPIClient.
+var apiClient = http.DefaultClient
+
+// debugPayloadFile, when set, receives the body of a pool API response
+// that couldn't be decoded, up to maxDebugPayload bytes.
+var debugPayloadFile string
+
+const maxDebugPayload = 64 << 10
+
+// apiTimeout bounds a request to a pool API, so that a pool that stops
+// answering fails the poll instead of holding up its worker for good.
+const apiTimeout = 30 * time.Second
+
+// saveBadPayload keeps body for diagnosing API changes.
+func saveBadPayload(url string, body []byte, cause error) {
+	if debugPayloadFile == "" {
+		return
+	}
+
+	if len(body) > maxDebugPayload {
+		body = body[:maxDebugPayload]
+	}
+	if err := os.WriteFile(debugPayloadFile, body, 0644); err != nil {
+		log.Printf("error: save bad payload: %s", err.Error())
+		return
+	}
+	log.Printf("response of %s didn't decode (%s), %d bytes saved to %s", url, cause.Error(), len(body), debugPayloadFile)
+}
+
+func newAPIClient(conf config) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if conf.PreferIPv6 {
+		transport.DialContext = dialPreferIPv6
+	}
+
+	headers := map[string]string{"User-Agent": userAgent(conf.UserAgentSuffix)}
+	for name, value := range conf.APIHeaders {
+		headers[http.CanonicalHeaderKey(name)] = value
+	}
+	return &http.Client{Timeout: apiTimeout, Transport: headersTransport{headers: headers, next: transport}}
+}
+
+// headersTransport adds fixed headers, e.g. an API key, to every request.
+type headersTransport struct {
+	headers map[string]string
+	next    http.RoundTripper
+}
+
+func (t headersTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the caller's request.
+	req = req.Clone(req.Context())
+	for name, value := range t.headers {
+		req.Header.Set(name, value)
+	}
+	return t.next.RoundTrip(req)
+}
+
+type block struct {
+	height int
+	ts     time.Time
+	hash   string
+	// reward in atomic units (piconero), zero if unknown.
+	reward uint64
+
+	difficulty  uint64
+	totalHashes uint64
+	// effort of the round that ended with this block, 1 being 100%.
+	// Zero if unknown.
+	effort float64
+}
+
+func fetchLastBlock(url string) (block, error) {
+	return fetchLastBlockContext(context.Background(), url)
+}
+
+func fetchLastBlockContext(ctx context.Context, url string) (block, error) {
+	blocks, err := fetchRecentBlocksContext(ctx, url)
+	if err != nil {
+		return block{}, err
+	}
+
+	return blocks[0], nil
+}
+
+// fetchAllPools fetches the last block of every pool at once. It takes the
+// pool configs rather than their URLs, since the result is keyed by pool
+// name. A pool that fails doesn't fail the others: the blocks of the pools
+// that answered come with the errors of the ones that didn't, each also
+// logged. err is set only if no pool answered.
+func fetchAllPools(ctx context.Context, pools []poolConfig) (blocks map[string]block, errs map[string]error, err error) {
+	var mu sync.Mutex
+	blocks = make(map[string]block, len(pools))
+	errs = make(map[string]error)
+
+	var g errgroup.Group
+	for _, pool := range pools {
+		pool := pool
+		g.Go(func() error {
+			b, err := fetchLastBlockContext(ctx, pool.URL)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				log.Printf("error: %s: fetch last block: %s", pool.Name, err.Error())
+				errs[pool.Name] = err
+				return fmt.Errorf("%s: %w", pool.Name, err)
+			}
+			blocks[pool.Name] = b
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil && len(blocks) == 0 {
+		return blocks, errs, err
+	}
+	return blocks, errs, nil
+}
+
+// fetchRecentBlocks returns the blocks the pool API knows about, newest
+// first, with the effort of each round filled in where possible.
+func fetchRecentBlocks(url string) ([]block, error) {
+	return fetchRecentBlocksContext(context.Background(), url)
+}
+
+func fetchRecentBlocksContext(ctx context.Context, url string) ([]block, error) {
+	req, err := newBlocksRequest(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := apiClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, fmt.Errorf("unexpected status %s", res.Status)
+	}
+	observeFreshness(url, res.Header)
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	rawBlocks, err := decodeBlocks(url, body)
+	if err != nil {
+		saveBadPayload(url, body, err)
+		return nil, err
+	}
+
+	if len(rawBlocks) <= 0 {
+		saveBadPayload(url, body, errUnexpectedStructure)
+		return nil, errUnexpectedStructure
+	}
+
+	now := time.Now()
+	missingTs := 0
+	blocks := make([]block, 0, len(rawBlocks))
+	for _, raw := range rawBlocks {
+		b, err := parseBlock(raw)
+		if err != nil {
+			saveBadPayload(url, body, err)
+			return nil, err
+		}
+		if b.ts.IsZero() {
+			b.ts = now
+			missingTs++
+		}
+		blocks = append(blocks, b)
+	}
+
+	if missingTs > 0 {
+		log.Printf("warning: %d of %d blocks from %s have no valid ts, using the current time", missingTs, len(blocks), url)
+	}
+
+	for i := 0; i+1 < len(blocks); i++ {
+		blocks[i].effort = roundEffort(blocks[i+1], blocks[i])
+	}
+
+	return blocks, nil
+}
+
+func parseBlock(raw map[string]interface{}) (block, error) {
+	if _, ok := raw["height"]; !ok {
+		return block{}, errUnexpectedStructure
+	}
+
+	if _, ok := raw["height"].(float64); !ok {
+		return block{}, errUnexpectedStructure
+	}
+
+	height := raw["height"].(float64)
+
+	// The rest is optional, not every API flavour reports it. A block
+	// without a valid ts gets a zero one.
+	var blockTime time.Time
+	if ts, ok := raw["ts"].(float64); ok && ts > 0 {
+		blockTime = time.UnixMilli(int64(ts))
+	}
+	hash, _ := raw["hash"].(string)
+	reward, _ := raw["reward"].(float64)
+	difficulty, _ := raw["difficulty"].(float64)
+	totalHashes, _ := raw["totalHashes"].(float64)
+
+	return block{
+		height:      int(height),
+		ts:          blockTime,
+		hash:        hash,
+		reward:      uint64(reward),
+		difficulty:  uint64(difficulty),
+		totalHashes: uint64(totalHashes),
+	}, nil
+}
+
+// blockIntervals returns the average and longest time between consecutive
+// blocks, given newest first.
+func blockIntervals(blocks []block) (avg, max time.Duration) {
+	if len(blocks) < 2 {
+		return 0, 0
+	}
+
+	for i := 0; i+1 < len(blocks); i++ {
+		interval := blocks[i].ts.Sub(blocks[i+1].ts)
+		if interval > max {
+			max = interval
+		}
+	}
+
+	avg = blocks[0].ts.Sub(blocks[len(blocks)-1].ts) / time.Duration(len(blocks)-1)
+	return avg, max
+}
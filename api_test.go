@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFetchAllPools(t *testing.T) {
+	up := newPoolAPI(t, http.StatusOK, blocksJSON(4500111, 4500074))
+	down := newPoolAPI(t, http.StatusBadGateway, "")
+
+	tests := []struct {
+		name       string
+		pools      []poolConfig
+		wantBlocks map[string]int
+		wantFailed []string
+		wantErr    string
+	}{
+		{
+			name:       "all up",
+			pools:      []poolConfig{{Name: "mini", URL: up}, {Name: "main", URL: up}},
+			wantBlocks: map[string]int{"mini": 4500111, "main": 4500111},
+		},
+		{
+			name:       "one down",
+			pools:      []poolConfig{{Name: "mini", URL: up}, {Name: "main", URL: down}},
+			wantBlocks: map[string]int{"mini": 4500111},
+			wantFailed: []string{"main"},
+		},
+		{
+			name:       "all down",
+			pools:      []poolConfig{{Name: "mini", URL: down}},
+			wantBlocks: map[string]int{},
+			wantFailed: []string{"mini"},
+			wantErr:    "mini: ",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blocks, errs, err := fetchAllPools(context.Background(), tt.pools)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("error = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.wantErr)):
+				t.Errorf("error = %v, want one starting with %q", err, tt.wantErr)
+			}
+			if len(blocks) != len(tt.wantBlocks) {
+				t.Errorf("got blocks of %d pools, want %d", len(blocks), len(tt.wantBlocks))
+			}
+			if len(errs) != len(tt.wantFailed) {
+				t.Errorf("errors = %v, want ones for %v", errs, tt.wantFailed)
+			}
+			for _, name := range tt.wantFailed {
+				if errs[name] == nil {
+					t.Errorf("no error for %s", name)
+				}
+			}
+			for name, height := range tt.wantBlocks {
+				if blocks[name].height != height {
+					t.Errorf("%s height = %d, want %d", name, blocks[name].height, height)
+				}
+			}
+		})
+	}
+}
+
+func TestBlockIntervals(t *testing.T) {
+	at := func(minutes ...int) []block {
+		blocks := make([]block, len(minutes))
+		for i, m := range minutes {
+			blocks[i] = block{height: len(minutes) - i, ts: time.Unix(1700000000, 0).Add(time.Duration(m) * time.Minute)}
+		}
+		return blocks
+	}
+
+	tests := []struct {
+		name    string
+		blocks  []block
+		wantAvg time.Duration
+		wantMax time.Duration
+	}{
+		{"none", nil, 0, 0},
+		{"one", at(0), 0, 0},
+		{"two", at(10, 0), 10 * time.Minute, 10 * time.Minute},
+		{"even", at(30, 20, 10, 0), 10 * time.Minute, 10 * time.Minute},
+		{"uneven", at(60, 50, 10, 0), 20 * time.Minute, 40 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		avg, max := blockIntervals(tt.blocks)
+		if avg != tt.wantAvg || max != tt.wantMax {
+			t.Errorf("%s: blockIntervals = %s, %s, want %s, %s", tt.name, avg, max, tt.wantAvg, tt.wantMax)
+		}
+	}
+}
+
+func TestCmdDiff(t *testing.T) {
+	conf := config{Pools: []poolConfig{{Name: defaultPoolName, URL: newPoolAPI(t, http.StatusOK, blocksJSON(103, 102, 101, 100))}}}
+	store := newTestStore(t)
+	n := newTestNotifier(t, conf, store)
+	r := newCommandRouter(nil, store, n, n.usage, conf)
+
+	if text := r.cmdDiff(command(1, "/diff")).Text; !strings.Contains(text, "Недостаточно блоков") {
+		t.Errorf("/diff before the first poll = %q, want not enough blocks", text)
+	}
+	if err := n.tryNotifyIfNewBlock(context.Background(), conf.Pools[0]); err != nil {
+		t.Fatal(err)
+	}
+	text := r.cmdDiff(command(1, "/diff")).Text
+
+	for _, want := range []string{"Последние 4 блоков", "среднее время между блоками: 1m0s", "максимальное: 1m0s"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("/diff = %q, lacks %q", text, want)
+		}
+	}
+}
+
+func TestAPIHeaders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "k3y" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, blocksJSON(101, 100))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		wantErr bool
+	}{
+		{"with header", map[string]string{"x-api-key": "k3y"}, false},
+		{"wrong value", map[string]string{"X-API-Key": "nope"}, true},
+		{"without header", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(c *http.Client) { apiClient = c }(apiClient)
+			apiClient = newAPIClient(config{APIHeaders: tt.headers})
+
+			b, err := fetchLastBlock(srv.URL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && b.height != 101 {
+				t.Errorf("height = %d, want 101", b.height)
+			}
+		})
+	}
+}
+
+func TestAPIHeadersValidation(t *testing.T) {
+	tests := []struct {
+		headers map[string]string
+		invalid bool
+	}{
+		{map[string]string{"Authorization": "Bearer x"}, false},
+		{map[string]string{"": "x"}, true},
+		{map[string]string{" ": "x"}, true},
+	}
+
+	for _, tt := range tests {
+		conf := config{ApiKey: "key", APIHeaders: tt.headers}
+		if got := hasProblem(conf.validate(), "APIHeaders"); got != tt.invalid {
+			t.Errorf("%v: invalid = %v, want %v", tt.headers, got, tt.invalid)
+		}
+	}
+}
+
+func TestBadPayloadSaved(t *testing.T) {
+	big := `[{"height":"` + strings.Repeat("x", 2*maxDebugPayload) + `"}]`
+
+	tests := []struct {
+		name     string
+		body     string
+		wantSave bool
+		wantSize int
+	}{
+		{"valid", blocksJSON(101, 100), false, 0},
+		{"not JSON", "<html>502</html>", true, len("<html>502</html>")},
+		{"no blocks", "[]", true, 2},
+		{"height not a number", `[{"height":"101"}]`, true, len(`[{"height":"101"}]`)},
+		{"truncated", big, true, maxDebugPayload},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "payload")
+			defer func(old string) { debugPayloadFile = old }(debugPayloadFile)
+			debugPayloadFile = path
+
+			_, err := fetchLastBlock(newPoolAPI(t, http.StatusOK, tt.body))
+			if tt.wantSave == (err == nil) {
+				t.Errorf("error = %v", err)
+			}
+
+			saved, readErr := os.ReadFile(path)
+			if !tt.wantSave {
+				if readErr == nil {
+					t.Errorf("saved %d bytes of a good payload", len(saved))
+				}
+				return
+			}
+			if readErr != nil {
+				t.Fatal(readErr)
+			}
+			if len(saved) != tt.wantSize || string(saved) != tt.body[:tt.wantSize] {
+				t.Errorf("saved %d bytes, want the first %d of the body", len(saved), tt.wantSize)
+			}
+		})
+	}
+}
+
+func TestFetchRecentBlocksFailures(t *testing.T) {
+	hung := make(chan struct{})
+	defer close(hung)
+	stuck := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-hung:
+		case <-r.Context().Done():
+		}
+	}))
+	defer stuck.Close()
+
+	tests := []struct {
+		name    string
+		url     string
+		wantErr string
+	}{
+		{"server error", newPoolAPI(t, http.StatusInternalServerError, blocksJSON(7)), "unexpected status 500"},
+		{"not found", newPoolAPI(t, http.StatusNotFound, blocksJSON(7)), "unexpected status 404"},
+		{"no answer", stuck.URL, context.DeadlineExceeded.Error()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			_, err := fetchRecentBlocksContext(ctx, tt.url)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want one with %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAPIClientTimeout(t *testing.T) {
+	if c := newAPIClient(config{}); c.Timeout != apiTimeout {
+		t.Errorf("timeout = %s, want %s", c.Timeout, apiTimeout)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// apiRequest is how to ask a pool API for its blocks when a plain GET of
+// the URL doesn't do, e.g. for GraphQL endpoints.
+type apiRequest struct {
+	method      string
+	body        string
+	contentType string
+	// blocksPath is the dot-separated path to the array of blocks in an
+	// object response, e.g. "data.blocks"; empty if the response is the
+	// array itself.
+	blocksPath string
+}
+
+// apiRequests holds the requests of the pools that set Method, Body or
+// BlocksPath, by URL. main fills it in before polling starts.
+var apiRequests = map[string]apiRequest{}
+
+func (c config) apiRequests() map[string]apiRequest {
+	requests := make(map[string]apiRequest)
+	for _, pool := range c.pools() {
+		if pool.Method == "" && pool.Body == "" && pool.BlocksPath == "" {
+			continue
+		}
+		req := apiRequest{
+			method:      strings.ToUpper(pool.Method),
+			body:        pool.Body,
+			contentType: pool.ContentType,
+			blocksPath:  pool.BlocksPath,
+		}
+		if req.method == "" {
+			req.method = http.MethodGet
+		}
+		if req.body != "" && req.contentType == "" {
+			req.contentType = "application/json"
+		}
+		requests[pool.URL] = req
+	}
+	return requests
+}
+
+// newBlocksRequest builds the request for the blocks at url, a GET unless
+// the pool says otherwise.
+func newBlocksRequest(ctx context.Context, url string) (*http.Request, error) {
+	spec, ok := apiRequests[url]
+	if !ok {
+		return http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	}
+
+	var body io.Reader
+	if spec.body != "" {
+		body = strings.NewReader(spec.body)
+	}
+	req, err := http.NewRequestWithContext(ctx, spec.method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if spec.contentType != "" {
+		req.Header.Set("Content-Type", spec.contentType)
+	}
+	return req, nil
+}
+
+// decodeBlocks decodes the raw blocks in body, found at the blocksPath of
+// the pool at url.
+func decodeBlocks(url string, body []byte) ([]map[string]interface{}, error) {
+	var rawBlocks []map[string]interface{}
+	path := apiRequests[url].blocksPath
+	if path == "" {
+		err := json.Unmarshal(body, &rawBlocks)
+		return rawBlocks, err
+	}
+
+	var node interface{}
+	if err := json.Unmarshal(body, &node); err != nil {
+		return nil, err
+	}
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: no %q in %s", errUnexpectedStructure, key, path)
+		}
+		node = obj[key]
+	}
+
+	items, ok := node.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not an array", errUnexpectedStructure, path)
+	}
+	for _, item := range items {
+		raw, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: %s holds a non-object", errUnexpectedStructure, path)
+		}
+		rawBlocks = append(rawBlocks, raw)
+	}
+	return rawBlocks, nil
+}
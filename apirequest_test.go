@@ -0,0 +1,109 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetchWithPoolRequest(t *testing.T) {
+	const query = `{"query":"{ blocks { height ts } }"}`
+
+	tests := []struct {
+		name string
+		pool poolConfig
+		// response is what the server answers a request it expects.
+		response        string
+		wantMethod      string
+		wantBody        string
+		wantContentType string
+		wantHeight      int
+		wantErr         bool
+	}{
+		{
+			name:       "plain GET",
+			pool:       poolConfig{Name: "mini"},
+			response:   blocksJSON(12, 11),
+			wantMethod: http.MethodGet,
+			wantHeight: 12,
+		},
+		{
+			name:            "GraphQL POST",
+			pool:            poolConfig{Name: "gql", Method: "post", Body: query, BlocksPath: "data.blocks"},
+			response:        `{"data":{"blocks":` + blocksJSON(22, 21) + `}}`,
+			wantMethod:      http.MethodPost,
+			wantBody:        query,
+			wantContentType: "application/json",
+			wantHeight:      22,
+		},
+		{
+			name:            "POST with a content type",
+			pool:            poolConfig{Name: "form", Method: "POST", Body: "pool=mini", ContentType: "application/x-www-form-urlencoded"},
+			response:        blocksJSON(32),
+			wantMethod:      http.MethodPost,
+			wantBody:        "pool=mini",
+			wantContentType: "application/x-www-form-urlencoded",
+			wantHeight:      32,
+		},
+		{
+			name:       "blocks not at the path",
+			pool:       poolConfig{Name: "gql", BlocksPath: "data.blocks"},
+			response:   `{"data":{"items":[]}}`,
+			wantMethod: http.MethodGet,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if r.Method != tt.wantMethod || string(body) != tt.wantBody || r.Header.Get("Content-Type") != tt.wantContentType {
+					t.Errorf("got %s %q (%q), want %s %q (%q)", r.Method, body, r.Header.Get("Content-Type"), tt.wantMethod, tt.wantBody, tt.wantContentType)
+					http.Error(w, "unexpected request", http.StatusBadRequest)
+					return
+				}
+				io.WriteString(w, tt.response)
+			}))
+			defer srv.Close()
+
+			pool := tt.pool
+			pool.URL = srv.URL
+			defer func(r map[string]apiRequest) { apiRequests = r }(apiRequests)
+			apiRequests = config{Pools: []poolConfig{pool}}.apiRequests()
+
+			b, err := fetchLastBlock(srv.URL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fetchLastBlock() = %v, want an error %v", err, tt.wantErr)
+			}
+			if b.height != tt.wantHeight {
+				t.Errorf("height = %d, want %d", b.height, tt.wantHeight)
+			}
+		})
+	}
+}
+
+func TestValidatePoolMethod(t *testing.T) {
+	tests := []struct {
+		name string
+		pool poolConfig
+		want bool
+	}{
+		{name: "GET", pool: poolConfig{Method: "get"}},
+		{name: "POST with a body", pool: poolConfig{Method: "POST", Body: "{}"}},
+		{name: "PUT", pool: poolConfig{Method: "PUT"}, want: true},
+		{name: "body without POST", pool: poolConfig{Body: "{}"}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := tt.pool
+			pool.Name, pool.URL = "mini", "http://mini"
+			conf := config{Pools: []poolConfig{pool}}
+			if got := hasProblem(conf.validate(), "Pools[0]"); got != tt.want {
+				t.Errorf("problem with Pools[0] = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// auditQueueSize bounds the entries waiting to be written; beyond it
+	// entries are dropped rather than holding up the action.
+	auditQueueSize = 64
+	// auditMaxSize is the size at which the audit log is rotated to
+	// <path>.1.
+	auditMaxSize = 10 << 20
+
+	defaultAuditEntries = 10
+	maxAuditEntries     = 50
+)
+
+// auditEntry is one administrative or destructive action.
+type auditEntry struct {
+	Time time.Time `json:"time"`
+	// Actor is the user who acted, 0 for the bot itself.
+	Actor  int64             `json:"actor"`
+	Action string            `json:"action"`
+	Params map[string]string `json:"params,omitempty"`
+
+	// ActorRef replaces Actor with LogRedactChatIDs.
+	ActorRef string `json:"actor_ref,omitempty"`
+}
+
+// actor is how /audit shows who acted.
+func (e auditEntry) actor() string {
+	if e.ActorRef != "" {
+		return e.ActorRef
+	}
+	return strconv.FormatInt(e.Actor, 10)
+}
+
+// auditLog appends entries as JSON lines to <StateFile>.audit. Writing is
+// best effort: a failed or dropped entry is only counted.
+type auditLog struct {
+	path    string
+	entries chan auditEntry
+	done    chan struct{}
+	failed  atomic.Int64
+}
+
+func auditPath(conf config) string {
+	if conf.StateFile == "" {
+		return ""
+	}
+	return conf.StateFile + ".audit"
+}
+
+// newAuditLog returns a disabled log when path is empty.
+func newAuditLog(path string) *auditLog {
+	a := &auditLog{path: path}
+	if path != "" {
+		a.entries = make(chan auditEntry, auditQueueSize)
+		a.done = make(chan struct{})
+		go a.run()
+	}
+	return a
+}
+
+func (a *auditLog) record(actor int64, action string, params map[string]string) {
+	if a.entries == nil {
+		return
+	}
+
+	entry := auditEntry{Time: time.Now(), Actor: actor, Action: action, Params: params}
+	if chatIDSalt != nil {
+		redactAuditEntry(&entry)
+	}
+
+	select {
+	case a.entries <- entry:
+	default:
+		a.failed.Add(1)
+	}
+}
+
+// redactAuditEntry hashes the acting user and the "chat" param.
+func redactAuditEntry(e *auditEntry) {
+	if e.Actor != 0 {
+		e.ActorRef, e.Actor = chatRef(e.Actor), 0
+	}
+	id, err := strconv.ParseInt(e.Params["chat"], 10, 64)
+	if err != nil {
+		return
+	}
+	params := make(map[string]string, len(e.Params))
+	for key, value := range e.Params {
+		params[key] = value
+	}
+	params["chat"] = chatRef(id)
+	e.Params = params
+}
+
+// close writes the queued entries and stops the log, for short-lived
+// commands such as import.
+func (a *auditLog) close() {
+	if a.entries == nil {
+		return
+	}
+	close(a.entries)
+	<-a.done
+}
+
+func (a *auditLog) run() {
+	defer close(a.done)
+	for e := range a.entries {
+		if err := a.write(e); err != nil {
+			a.failed.Add(1)
+			log.Printf("error: write audit log: %s", err.Error())
+		}
+	}
+}
+
+func (a *auditLog) write(e auditEntry) error {
+	if info, err := os.Stat(a.path); err == nil && info.Size() > auditMaxSize {
+		if err := os.Rename(a.path, a.path+".1"); err != nil {
+			return err
+		}
+	}
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(line, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// last returns up to n of the most recent entries, newest first.
+func (a *auditLog) last(n int) ([]auditEntry, error) {
+	file, err := os.Open(a.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []auditEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var e auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		entries = append(entries, e)
+		if len(entries) > n {
+			entries = entries[1:]
+		}
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, scanner.Err()
+}
+
+// textDigest identifies a text in the audit log without keeping it.
+func textDigest(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:8])
+}
+
+// actorOf is the user who sent msg, 0 if unknown.
+func actorOf(msg *tgbotapi.Message) int64 {
+	if msg.From == nil {
+		return 0
+	}
+	return msg.From.ID
+}
+
+// cmdAudit implements /audit [N], the last N audit log entries.
+func (r *commandRouter) cmdAudit(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	audit := r.notifier.audit
+	if audit.path == "" {
+		return reply(msg, "Журнал аудита ведётся только при заданном StateFile")
+	}
+
+	n := defaultAuditEntries
+	if arg := strings.TrimSpace(msg.CommandArguments()); arg != "" {
+		var err error
+		if n, err = strconv.Atoi(arg); err != nil || n < 1 {
+			return reply(msg, "Использование: /audit [количество записей]")
+		}
+		n = min(n, maxAuditEntries)
+	}
+
+	entries, err := audit.last(n)
+	if err != nil {
+		log.Printf("error: read audit log: %s", err.Error())
+		return reply(msg, "Не удалось прочитать журнал аудита")
+	}
+	if len(entries) == 0 {
+		return reply(msg, "Журнал аудита пуст")
+	}
+
+	var sb strings.Builder
+	for _, e := range entries {
+		fmt.Fprintf(&sb, "%s %s %s", e.Time.Format(time.DateTime), e.actor(), e.Action)
+		for _, key := range sortedKeys(e.Params) {
+			fmt.Fprintf(&sb, " %s=%s", key, e.Params[key])
+		}
+		sb.WriteString("\n")
+	}
+	if failed := audit.failed.Load(); failed > 0 {
+		fmt.Fprintf(&sb, "\nНе записано записей: %d", failed)
+	}
+	return reply(msg, truncateRunes(sb.String(), maxMessageLength))
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultAutoTuneCeiling = 2 * time.Minute
+
+// upstreamFreshness is how long each pool API URL says its responses stay
+// fresh, from Cache-Control. Polling more often only returns cached data.
+var upstreamFreshness sync.Map // url -> time.Duration
+
+// observeFreshness records the max-age (or s-maxage, which shared caches
+// such as the one in front of p2pool.io honor) of a response from url.
+func observeFreshness(url string, header http.Header) {
+	var maxAge, sMaxAge time.Duration
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds <= 0 {
+			continue
+		}
+		switch strings.ToLower(name) {
+		case "max-age":
+			maxAge = time.Duration(seconds) * time.Second
+		case "s-maxage":
+			sMaxAge = time.Duration(seconds) * time.Second
+		}
+	}
+	if sMaxAge > 0 {
+		maxAge = sMaxAge
+	}
+	if maxAge > 0 {
+		upstreamFreshness.Store(url, maxAge)
+	}
+}
+
+func observedFreshness(url string) (time.Duration, bool) {
+	d, ok := upstreamFreshness.Load(url)
+	if !ok {
+		return 0, false
+	}
+	return d.(time.Duration), true
+}
+
+// intervalTuner picks the polling interval of each pool. Without
+// AutoTuneInterval it only warns when the configured interval is much
+// shorter than the upstream cache.
+type intervalTuner struct {
+	configured time.Duration
+	enabled    bool
+	ceiling    time.Duration
+
+	mu        sync.Mutex
+	effective map[string]time.Duration
+	warned    map[string]bool
+}
+
+func newIntervalTuner(configured time.Duration, conf config) *intervalTuner {
+	return &intervalTuner{
+		configured: configured,
+		enabled:    conf.AutoTuneInterval,
+		ceiling:    conf.autoTuneCeiling(),
+		effective:  make(map[string]time.Duration),
+		warned:     make(map[string]bool),
+	}
+}
+
+// interval returns how long to wait before polling pool again.
+func (t *intervalTuner) interval(pool poolConfig) time.Duration {
+	interval := t.configured
+	freshness, ok := observedFreshness(pool.URL)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Half the freshness is still meaningfully short; anything closer is
+	// within the jitter of when the cache happens to refresh.
+	if ok && t.configured < freshness/2 {
+		if !t.warned[pool.Name] {
+			t.warned[pool.Name] = true
+			log.Printf("warning: %s: NotifyDuration %s is shorter than the %s the API caches responses for", pool.Name, t.configured, freshness)
+		}
+		if t.enabled {
+			interval = min(freshness, max(t.ceiling, t.configured))
+		}
+	}
+
+	if t.effective[pool.Name] != interval {
+		if _, seen := t.effective[pool.Name]; seen || interval != t.configured {
+			log.Printf("%s: polling every %s", pool.Name, interval)
+		}
+		t.effective[pool.Name] = interval
+	}
+	return interval
+}
+
+// intervals returns the current interval of every pool polled so far.
+func (t *intervalTuner) intervals() map[string]time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := make(map[string]time.Duration, len(t.effective))
+	for name, d := range t.effective {
+		out[name] = d
+	}
+	return out
+}
+
+func (t *intervalTuner) String() string {
+	intervals := t.intervals()
+	names := make([]string, 0, len(intervals))
+	for name := range intervals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString("Интервал опроса API:")
+	for _, name := range names {
+		fmt.Fprintf(&sb, "\n%s — %s", name, intervals[name])
+	}
+	if len(names) == 0 {
+		fmt.Fprintf(&sb, " %s", t.configured)
+	}
+	return sb.String()
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// batchingNotifier holds back block notifications for a window that is
+// restarted by every further block of the same pool, then sends all of
+// them as one message. It keeps a lucky streak from flooding subscribers.
+type batchingNotifier struct {
+	n      *Notifier
+	window time.Duration
+
+	mu      sync.Mutex
+	pending map[string]*pendingBatch
+}
+
+type pendingBatch struct {
+	// ctx is that of the worker that started the batch, so a shutdown
+	// cancels its broadcast.
+	ctx    context.Context
+	pool   poolConfig
+	blocks []block
+	timer  *time.Timer
+}
+
+func newBatchingNotifier(n *Notifier, window time.Duration) *batchingNotifier {
+	return &batchingNotifier{
+		n:       n,
+		window:  window,
+		pending: make(map[string]*pendingBatch),
+	}
+}
+
+func (b *batchingNotifier) add(ctx context.Context, pool poolConfig, blk block) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if batch, ok := b.pending[pool.Name]; ok {
+		batch.blocks = append(batch.blocks, blk)
+		// A timer that already fired has a flush waiting for the lock,
+		// which takes blk along. Restarting it would flush the next
+		// batch early.
+		if batch.timer.Stop() {
+			batch.timer.Reset(b.window)
+		}
+		return
+	}
+
+	batch := &pendingBatch{ctx: ctx, pool: pool, blocks: []block{blk}}
+	batch.timer = time.AfterFunc(b.window, func() { b.flush(batch) })
+	b.pending[pool.Name] = batch
+}
+
+// flush broadcasts batch unless it was flushed already.
+func (b *batchingNotifier) flush(batch *pendingBatch) {
+	poolName := batch.pool.Name
+	b.mu.Lock()
+	current := b.pending[poolName] == batch
+	if current {
+		delete(b.pending, poolName)
+	}
+	b.mu.Unlock()
+
+	if !current {
+		return
+	}
+
+	last := batch.blocks[len(batch.blocks)-1]
+	text := func(id int64) string {
+		if len(batch.blocks) > 1 {
+			return batchMessage(batch.blocks)
+		}
+		return b.n.messageFor(id, batch.pool, last)
+	}
+
+	if err := b.n.broadcast(batch.ctx, batch.pool, batch.blocks, text); err != nil {
+		log.Printf("error: %s: broadcast batch of %d blocks: %s", poolName, len(batch.blocks), err.Error())
+	}
+}
+
+func batchMessage(blocks []block) string {
+	heights := make([]string, len(blocks))
+	for i, blk := range blocks {
+		heights[i] = strconv.Itoa(blk.height)
+	}
+
+	avg := blocks[len(blocks)-1].ts.Sub(blocks[0].ts) / time.Duration(len(blocks)-1)
+
+	return fmt.Sprintf("Найдено блоков: %d! Высоты: %s. Средний интервал: %s",
+		len(blocks), strings.Join(heights, ", "), avg.Round(time.Second))
+}
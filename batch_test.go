@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBatchMessage(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name   string
+		blocks []block
+		want   string
+	}{
+		{
+			name:   "two",
+			blocks: []block{{height: 12345, ts: start}, {height: 12346, ts: start.Add(90 * time.Second)}},
+			want:   "Найдено блоков: 2! Высоты: 12345, 12346. Средний интервал: 1m30s",
+		},
+		{
+			name: "three",
+			blocks: []block{
+				{height: 12345, ts: start},
+				{height: 12346, ts: start.Add(3 * time.Minute)},
+				{height: 12347, ts: start.Add(400 * time.Second)},
+			},
+			want: "Найдено блоков: 3! Высоты: 12345, 12346, 12347. Средний интервал: 3m20s",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := batchMessage(tt.blocks); got != tt.want {
+			t.Errorf("%s: batchMessage = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBatchingSendsOneMessage(t *testing.T) {
+	tg := newFakeTelegram(t)
+	store := newTestStore(t)
+	store.Add(1)
+	n := newTestNotifier(t, config{BatchWindow: "200ms"}, store)
+	n.bot = tg.bot(t)
+	if n.batcher == nil {
+		t.Fatal("BatchWindow didn't enable batching")
+	}
+
+	start := time.Now().Add(-10 * time.Minute)
+	for i := 0; i < 3; i++ {
+		n.batcher.add(context.Background(), poolConfig{Name: defaultPoolName}, block{height: 12345 + i, ts: start.Add(time.Duration(i) * 200 * time.Second)})
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(tg.sentTexts()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Give a wrongly split batch the time to show up.
+	time.Sleep(300 * time.Millisecond)
+
+	texts := tg.sentTexts()
+	if len(texts) != 1 {
+		t.Fatalf("sent %d messages, want 1: %q", len(texts), texts)
+	}
+	if want := "Найдено блоков: 3! Высоты: 12345, 12346, 12347. Средний интервал: 3m20s"; texts[0] != want {
+		t.Errorf("message = %q, want %q", texts[0], want)
+	}
+}
+
+func TestBatchingIgnoresStaleTimer(t *testing.T) {
+	tg := newFakeTelegram(t)
+	store := newTestStore(t)
+	store.Add(1)
+	n := newTestNotifier(t, config{BatchWindow: "500ms"}, store)
+	n.bot = tg.bot(t)
+	pool := poolConfig{Name: defaultPoolName}
+
+	start := time.Now()
+	n.batcher.add(context.Background(), pool, block{height: 100, ts: start})
+	// Flush as if the timer had fired just before a block came in.
+	n.batcher.mu.Lock()
+	first := n.batcher.pending[pool.Name]
+	n.batcher.mu.Unlock()
+	n.batcher.flush(first)
+
+	time.Sleep(200 * time.Millisecond)
+	n.batcher.add(context.Background(), pool, block{height: 101, ts: time.Now()})
+
+	// The timer of the first batch fires at 500ms, the second batch is
+	// due at 700ms.
+	time.Sleep(time.Until(start.Add(600 * time.Millisecond)))
+	if texts := tg.sentTexts(); len(texts) != 1 {
+		t.Fatalf("sent %q before the second window ended, want only the first block", texts)
+	}
+	if texts := tg.waitForTexts(t, 2); len(texts) != 2 {
+		t.Errorf("sent %q, want both blocks", texts)
+	}
+}
+
+func TestBatchingStopsWithWorker(t *testing.T) {
+	tg := newFakeTelegram(t)
+	store := newTestStore(t)
+	store.Add(1)
+	n := newTestNotifier(t, config{BatchWindow: "50ms"}, store)
+	n.bot = tg.bot(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n.batcher.add(ctx, poolConfig{Name: defaultPoolName}, block{height: 100, ts: time.Now()})
+	cancel()
+
+	time.Sleep(200 * time.Millisecond)
+	if texts := tg.sentTexts(); len(texts) != 0 {
+		t.Errorf("sent %q after the worker stopped", texts)
+	}
+}
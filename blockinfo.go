@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	explorerBlockURL = "https://xmrchain.net/block/"
+
+	// blockLookupTTL is how long a pool API response fetched for /block
+	// answers further lookups.
+	blockLookupTTL = 5 * time.Minute
+	// blockLookupInterval is how often one user may make /block query the
+	// pool API, so the bot can't be used as a proxy for it.
+	blockLookupInterval = 30 * time.Second
+)
+
+// blockLookup caches the pool API responses fetched by /block and rate
+// limits fetching them per user.
+type blockLookup struct {
+	mu       sync.Mutex
+	cached   map[string]cachedBlocks
+	lastByID map[int64]time.Time
+}
+
+type cachedBlocks struct {
+	// blocks are newest first, as the API returns them.
+	blocks    []block
+	fetchedAt time.Time
+}
+
+func newBlockLookup() *blockLookup {
+	return &blockLookup{
+		cached:   make(map[string]cachedBlocks),
+		lastByID: make(map[int64]time.Time),
+	}
+}
+
+// get returns the blocks of pool cached within blockLookupTTL.
+func (l *blockLookup) get(pool string) ([]block, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c, ok := l.cached[pool]
+	if !ok || time.Since(c.fetchedAt) > blockLookupTTL {
+		return nil, false
+	}
+	return c.blocks, true
+}
+
+func (l *blockLookup) put(pool string, blocks []block) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cached[pool] = cachedBlocks{blocks: blocks, fetchedAt: time.Now()}
+}
+
+// allow reports whether userID may query the API now and, if not, how long
+// to wait.
+func (l *blockLookup) allow(userID int64) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if wait := blockLookupInterval - time.Since(l.lastByID[userID]); wait > 0 {
+		return false, wait
+	}
+	l.lastByID[userID] = time.Now()
+	return true, 0
+}
+
+// findBlock returns the block at height in blocks, newest first, along with
+// the block before it.
+func findBlock(blocks []block, height int) (b, prev block, ok bool) {
+	for i, candidate := range blocks {
+		if candidate.height != height {
+			continue
+		}
+		if i+1 < len(blocks) {
+			prev = blocks[i+1]
+		}
+		return candidate, prev, true
+	}
+	return block{}, block{}, false
+}
+
+// newestFirst returns a reversed copy of blocks.
+func newestFirst(blocks []block) []block {
+	out := make([]block, len(blocks))
+	for i, b := range blocks {
+		out[len(blocks)-1-i] = b
+	}
+	return out
+}
+
+// cmdBlock implements /block <height> [pool]: the details of a block the
+// pool found, from the local history or, failing that, the pool API.
+func (r *commandRouter) cmdBlock(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	args := strings.Fields(msg.CommandArguments())
+	if len(args) == 0 || len(args) > 2 {
+		return reply(msg, "Использование: /block <высота> [пул]")
+	}
+	height, err := strconv.Atoi(args[0])
+	if err != nil || height <= 0 {
+		return reply(msg, "Использование: /block <высота> [пул]")
+	}
+	pool := r.notifier.pools[0]
+	if len(args) == 2 {
+		var ok bool
+		if pool, ok = r.notifier.pool(args[1]); !ok {
+			return reply(msg, fmt.Sprintf("Пул %s не отслеживается, см. /pools", sanitize(args[1])))
+		}
+	}
+
+	tip := r.notifier.lastBlocks.getLastBlock(pool.Name)
+	if tip.height != 0 && height > tip.height {
+		return reply(msg, fmt.Sprintf("Блок %d ещё не найден, последний блок пула — %d", height, tip.height))
+	}
+
+	blocks := r.notifier.recentBlocks(pool.Name)
+	b, prev, ok := findBlock(blocks, height)
+	if !ok {
+		blocks, ok = r.blockLookup.get(pool.Name)
+		if !ok {
+			if msg.From == nil {
+				return reply(msg, "Блока нет в истории бота")
+			}
+			if allowed, wait := r.blockLookup.allow(msg.From.ID); !allowed {
+				return reply(msg, fmt.Sprintf("Блока нет в истории бота, запросить его у пула можно через %s", wait.Round(time.Second)))
+			}
+			blocks, err = fetchRecentBlocks(pool.URL)
+			if err != nil {
+				log.Printf("error: %s: fetch recent blocks: %s", pool.Name, err.Error())
+				return reply(msg, "Не удалось получить историю блоков")
+			}
+			r.blockLookup.put(pool.Name, blocks)
+		}
+		b, prev, ok = findBlock(blocks, height)
+	}
+	if !ok {
+		if oldest := blocks[len(blocks)-1]; height < oldest.height {
+			return reply(msg, fmt.Sprintf("Блок %d старше истории пула, самый старый известный блок — %d", height, oldest.height))
+		}
+		return reply(msg, fmt.Sprintf("Пул %s не находил блок %d", pool.Name, height))
+	}
+
+	resp := reply(msg, r.notifier.blockDetails(pool, b, prev, tip))
+	resp.DisableWebPagePreview = true
+	return resp
+}
+
+func (n *Notifier) blockDetails(pool poolConfig, b, prev, tip block) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Блок %d пула %s\n", b.height, pool.Name)
+	fmt.Fprintf(&sb, "Время: %s\n", b.ts.Format(n.timeFormat))
+	if b.hash != "" {
+		fmt.Fprintf(&sb, "Хеш: %s%s\n", explorerBlockURL, b.hash)
+	}
+	if prev.height != 0 {
+		fmt.Fprintf(&sb, "Длительность раунда: %s\n", b.ts.Sub(prev.ts).Round(time.Second))
+	}
+	if b.effort != 0 {
+		fmt.Fprintf(&sb, "Усилие: %s\n", formatEffort(b.effort))
+	}
+	if b.reward != 0 {
+		fmt.Fprintf(&sb, "Награда: %s\n", formatXMR(b.reward))
+	}
+	if tip.height != 0 {
+		fmt.Fprintf(&sb, "На %d блоков ниже последнего блока пула", tip.height-b.height)
+	}
+	return strings.TrimSpace(sb.String())
+}
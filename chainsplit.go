@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// chainSplitEvent is a height at which API sources disagree on the block.
+type chainSplitEvent struct {
+	height int
+	// hashes is the block hash reported by each source.
+	hashes map[string]string
+}
+
+func (e chainSplitEvent) String() string {
+	sources := make([]string, 0, len(e.hashes))
+	for source := range e.hashes {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "⚠️ Chain split detected at height %d! Hash mismatch between API sources.", e.height)
+	for _, source := range sources {
+		fmt.Fprintf(&sb, "\n%s: %s", source, e.hashes[source])
+	}
+	return sb.String()
+}
+
+// detectChainSplit compares the blocks that sources report for the same
+// height. Sources at other heights, e.g. lagging mirrors, and blocks
+// without a hash are ignored.
+func detectChainSplit(blocks map[string]block) *chainSplitEvent {
+	byHeight := make(map[int]map[string]string)
+	for source, b := range blocks {
+		if b.hash == "" {
+			continue
+		}
+		if byHeight[b.height] == nil {
+			byHeight[b.height] = make(map[string]string)
+		}
+		byHeight[b.height][source] = b.hash
+	}
+
+	var split *chainSplitEvent
+	for height, hashes := range byHeight {
+		distinct := make(map[string]bool)
+		for _, hash := range hashes {
+			distinct[hash] = true
+		}
+		if len(distinct) > 1 && (split == nil || height > split.height) {
+			split = &chainSplitEvent{height: height, hashes: hashes}
+		}
+	}
+	return split
+}
+
+// checkChainSplit asks the cross-check sources of pool about the block at
+// the height of b and alerts admins if any reports a different hash.
+func (n *Notifier) checkChainSplit(ctx context.Context, pool poolConfig, b block) {
+	blocks := map[string]block{pool.URL: b}
+	for _, url := range pool.CrossCheckURLs {
+		recent, err := fetchRecentBlocksContext(ctx, url)
+		if err != nil {
+			logger(ctx).Error("cross-check", "url", url, "err", err)
+			continue
+		}
+		if other, _, ok := findBlock(recent, b.height); ok {
+			blocks[url] = other
+		}
+	}
+
+	split := detectChainSplit(blocks)
+	if split == nil {
+		return
+	}
+
+	logger(ctx).Warn("chain split", "height", split.height, "hashes", split.hashes)
+	if n.inMaintenance() {
+		return
+	}
+	for id := range n.admins {
+		if _, err := n.bot.Send(tgbotapi.NewMessage(id, split.String())); err != nil {
+			logger(ctx).Error("send chain split alert", "chat", chatRef(id), "err", err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDetectChainSplit(t *testing.T) {
+	tests := []struct {
+		name       string
+		blocks     map[string]block
+		wantHeight int
+		wantHashes map[string]string
+	}{
+		{
+			name:   "agree",
+			blocks: map[string]block{"a": {height: 100, hash: "aa"}, "b": {height: 100, hash: "aa"}},
+		},
+		{
+			name:       "disagree",
+			blocks:     map[string]block{"a": {height: 100, hash: "aa"}, "b": {height: 100, hash: "bb"}, "c": {height: 100, hash: "aa"}},
+			wantHeight: 100,
+			wantHashes: map[string]string{"a": "aa", "b": "bb", "c": "aa"},
+		},
+		{
+			name:   "lagging source",
+			blocks: map[string]block{"a": {height: 101, hash: "aa"}, "b": {height: 100, hash: "bb"}},
+		},
+		{
+			name:   "no hash",
+			blocks: map[string]block{"a": {height: 100, hash: "aa"}, "b": {height: 100}},
+		},
+		{
+			name: "highest split wins",
+			blocks: map[string]block{
+				"a": {height: 100, hash: "aa"}, "b": {height: 100, hash: "bb"},
+				"c": {height: 101, hash: "cc"}, "d": {height: 101, hash: "dd"},
+			},
+			wantHeight: 101,
+			wantHashes: map[string]string{"c": "cc", "d": "dd"},
+		},
+		{
+			name:   "one source",
+			blocks: map[string]block{"a": {height: 100, hash: "aa"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			split := detectChainSplit(tt.blocks)
+			if tt.wantHashes == nil {
+				if split != nil {
+					t.Errorf("split = %+v, want none", *split)
+				}
+				return
+			}
+			if split == nil {
+				t.Fatal("no split detected")
+			}
+			if split.height != tt.wantHeight || !maps.Equal(split.hashes, tt.wantHashes) {
+				t.Errorf("split = %+v, want height %d with %v", *split, tt.wantHeight, tt.wantHashes)
+			}
+		})
+	}
+}
+
+func TestCheckChainSplitAlertsAdmins(t *testing.T) {
+	agreeing := newPoolAPI(t, http.StatusOK, blocksJSON(4500111, 4500074))
+	other := fmt.Sprintf("%064x", 1)
+	forked := newPoolAPI(t, http.StatusOK, strings.Replace(blocksJSON(4500111), fmt.Sprintf("%064x", 4500111), other, 1))
+	down := newPoolAPI(t, http.StatusBadGateway, "")
+
+	tests := []struct {
+		name      string
+		mirrors   []string
+		wantAlert bool
+	}{
+		{"mirrors agree", []string{agreeing, down}, false},
+		{"mirror forked", []string{agreeing, forked}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tg := newFakeTelegram(t)
+			n := newTestNotifier(t, config{AdminIDs: []int64{7}}, newTestStore(t))
+			n.bot = tg.bot(t)
+			pool := poolConfig{Name: defaultPoolName, URL: "primary", CrossCheckURLs: tt.mirrors}
+
+			n.checkChainSplit(context.Background(), pool, block{height: 4500111, hash: fmt.Sprintf("%064x", 4500111)})
+
+			texts := tg.sentTexts()
+			if !tt.wantAlert {
+				if len(texts) != 0 {
+					t.Errorf("alerted %q", texts)
+				}
+				return
+			}
+			if len(texts) != 1 || !strings.Contains(texts[0], "height 4500111") || !strings.Contains(texts[0], forked+": "+other) {
+				t.Errorf("alerts = %q, want one naming the forked mirror", texts)
+			}
+		})
+	}
+}
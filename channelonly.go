@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// recipients are the chats notifications go to: ChannelIDs in channel-only
+// mode, the subscribers otherwise.
+func (n *Notifier) recipients() ([]int64, error) {
+	if n.channelOnly {
+		return n.channels, nil
+	}
+	return n.store.Subscribers()
+}
+
+// channelOnlyCommands are the commands answered for everyone in
+// channel-only mode, all pointing to the channel.
+var channelOnlyCommands = map[string]bool{
+	"start":       true,
+	"subscribe":   true,
+	"unsubscribe": true,
+}
+
+// ignoreInChannelOnly reports whether msg goes unanswered in channel-only
+// mode: private messages other than admin commands and the subscription
+// commands.
+func (r *commandRouter) ignoreInChannelOnly(msg *tgbotapi.Message, name string) bool {
+	return r.channelOnly && msg.Chat.IsPrivate() && !r.isAdmin(msg) && !channelOnlyCommands[name]
+}
+
+func (r *commandRouter) cmdChannelOnly(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	return reply(msg, fmt.Sprintf("Бот не ведёт личных подписок. Подпишитесь, пожалуйста, на канал %s", r.channelName))
+}
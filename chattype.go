@@ -0,0 +1,51 @@
+package main
+
+import (
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const chatTypeUnknown = "unknown"
+
+// rememberChatType caches the type of a chat we heard from.
+func (n *Notifier) rememberChatType(id int64, chatType string) {
+	var known bool
+	n.state.view(func(st state) {
+		known = st.ChatTypes[id] == chatType
+	})
+	if known {
+		return
+	}
+
+	err := n.state.update(func(st *state) {
+		if st.ChatTypes == nil {
+			st.ChatTypes = make(map[int64]string)
+		}
+		st.ChatTypes[id] = chatType
+	})
+	if err != nil {
+		log.Printf("error: save chat type of %s: %s", chatRef(id), err.Error())
+	}
+}
+
+// chatType returns the cached type of a chat, asking Telegram the first
+// time for subscribers that predate the cache.
+func (n *Notifier) chatType(id int64) string {
+	var chatType string
+	n.state.view(func(st state) {
+		chatType = st.ChatTypes[id]
+	})
+	if chatType != "" {
+		return chatType
+	}
+
+	chat, err := n.bot.GetChat(tgbotapi.ChatInfoConfig{ChatConfig: tgbotapi.ChatConfig{ChatID: id}})
+	if err != nil {
+		log.Printf("error: get chat %s: %s", chatRef(id), err.Error())
+		return chatTypeUnknown
+	}
+
+	n.rememberChatType(id, chat.Type)
+	return chat.Type
+}
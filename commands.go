@@ -0,0 +1,485 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+type commandFunc func(msg *tgbotapi.Message) tgbotapi.MessageConfig
+
+// commandRouter dispatches incoming messages to command handlers. Anything
+// that isn't a known command subscribes the chat, as /start does, except in
+// groups, where only commands are answered.
+type commandRouter struct {
+	bot      *tgbotapi.BotAPI
+	store    Storer
+	notifier *Notifier
+	usage    *usageStats
+	admins   map[int64]bool
+
+	subscribeAttempts int
+	donationAddress   string
+	groupAdmins       *groupAdminCache
+	blockLookup       *blockLookup
+	aliases           map[string]string
+	retention         time.Duration
+	// conf is the configuration the bot started with, for /debug.
+	conf config
+
+	// channelOnly points everyone to channelName instead of subscribing
+	// them, see ChannelOnlyMode.
+	channelOnly bool
+	channelName string
+
+	commands      map[string]commandFunc
+	adminCommands map[string]commandFunc
+}
+
+func newCommandRouter(bot *tgbotapi.BotAPI, store Storer, notifier *Notifier, usage *usageStats, conf config) *commandRouter {
+	r := &commandRouter{
+		bot:      bot,
+		store:    store,
+		notifier: notifier,
+		usage:    usage,
+		admins:   make(map[int64]bool, len(conf.AdminIDs)),
+
+		subscribeAttempts: conf.subscribeAttempts(),
+		donationAddress:   conf.DonationAddress,
+		groupAdmins:       newGroupAdminCache(),
+		blockLookup:       newBlockLookup(),
+		retention:         conf.unsubscribeRetention(),
+		conf:              conf,
+		channelOnly:       conf.ChannelOnlyMode,
+		channelName:       conf.ChannelName,
+	}
+	for _, id := range conf.AdminIDs {
+		r.admins[id] = true
+	}
+
+	r.commands = map[string]commandFunc{
+		"start":       r.requireGroupAdmin(r.cmdStart),
+		"subscribe":   r.requireGroupAdmin(r.cmdStart),
+		"unsubscribe": r.requireGroupAdmin(r.cmdUnsubscribe),
+		"pools":       r.cmdPools,
+		"luck":        r.cmdLuck,
+		"diff":        r.cmdDiff,
+		"whichpool":   r.cmdWhichPool,
+		"template":    r.requireGroupAdmin(r.cmdTemplate),
+		"preview":     r.cmdPreview,
+		"missed":      r.cmdMissed,
+		"skipnext":    r.requireGroupAdmin(r.cmdSkipNext),
+		"block":       r.cmdBlock,
+		"uptime":      r.cmdUptime,
+		"delete":      r.requireGroupAdmin(r.cmdDelete),
+		"hashrate":    r.cmdHashrate,
+		"me":          r.cmdMe,
+		"hidename":    r.requireGroupAdmin(r.cmdHideName),
+	}
+	if r.donationAddress != "" {
+		r.commands["donate"] = r.cmdDonate
+	}
+	r.adminCommands = map[string]commandFunc{
+		"usage":         r.cmdUsage,
+		"stats":         r.cmdStats,
+		"subscribers":   r.cmdSubscribers,
+		"pausebot":      r.cmdPauseBot,
+		"resumebot":     r.cmdResumeBot,
+		"ackstats":      r.cmdAckStats,
+		"add":           r.cmdAdd,
+		"addchat":       r.cmdAdd,
+		"removechat":    r.cmdRemoveChat,
+		"reset":         r.cmdReset,
+		"verifyhistory": r.cmdVerifyHistory,
+		"tag":           r.cmdTag,
+		"untag":         r.cmdUntag,
+		"broadcast":     r.cmdBroadcast,
+		"growth":        r.cmdGrowth,
+		"audit":         r.cmdAudit,
+		"maintenance":   r.cmdMaintenance,
+		"debug":         r.cmdDebug,
+	}
+	if r.channelOnly {
+		for name := range channelOnlyCommands {
+			r.commands[name] = r.cmdChannelOnly
+		}
+	}
+	r.aliases = r.buildAliases(conf.CommandAliases)
+	return r
+}
+
+func (r *commandRouter) handle(msg *tgbotapi.Message) {
+	if msg.MigrateToChatID != 0 {
+		r.migrate(msg.Chat.ID, msg.MigrateToChatID)
+		return
+	}
+
+	text := msg.Text
+	msg.Text = limitInput(msg.Text)
+	r.notifier.rememberChatType(msg.Chat.ID, msg.Chat.Type)
+	r.notifier.rememberName(msg.Chat)
+	// Groups get messages meant for people too; only commands are for the
+	// bot there.
+	if commandName(msg) == "" && (msg.Chat.IsGroup() || msg.Chat.IsSuperGroup()) {
+		return
+	}
+
+	name, cmd := r.route(msg)
+	if fullTextCommands[name] {
+		msg.Text = text
+	}
+	if r.ignoreInChannelOnly(msg, name) {
+		return
+	}
+	r.usage.countCommand(name)
+
+	if backgroundCommands[name] {
+		go r.respond(msg, cmd)
+		return
+	}
+	r.respond(msg, cmd)
+}
+
+// fullTextCommands pass their argument on as is, so they get the whole
+// message rather than the maxCommandInput runes that routing looks at.
+var fullTextCommands = map[string]bool{
+	"broadcast": true,
+	"template":  true,
+}
+
+// backgroundCommands query pool APIs or other services, which can take up
+// to apiTimeout, or message every subscriber; they are answered in the
+// background so that the updates of everyone else aren't held up
+// meanwhile.
+var backgroundCommands = map[string]bool{
+	"hashrate":      true,
+	"whichpool":     true,
+	"block":         true,
+	"verifyhistory": true,
+	"broadcast":     true,
+}
+
+func (r *commandRouter) respond(msg *tgbotapi.Message, cmd commandFunc) {
+	resp := cmd(msg)
+	if r.notifier.paused() {
+		resp.Text += "\n\n⏸ Уведомления о блоках временно приостановлены оператором"
+	}
+
+	if _, err := r.bot.Send(resp); err != nil {
+		log.Printf("error: reply to %s: %s", chatRef(msg.Chat.ID), err.Error())
+	}
+}
+
+// migrate moves a subscription to the supergroup a group was turned into;
+// messages to the old chat ID fail from then on.
+func (r *commandRouter) migrate(oldID, newID int64) {
+	if err := r.store.Replace(oldID, newID); err != nil {
+		log.Printf("error: migrate %s to %s: %s", chatRef(oldID), chatRef(newID), err.Error())
+		return
+	}
+	log.Printf("chat %s migrated to %s", chatRef(oldID), chatRef(newID))
+}
+
+// route picks the handler for msg along with the name it is counted under.
+func (r *commandRouter) route(msg *tgbotapi.Message) (string, commandFunc) {
+	name := commandName(msg)
+	if canonical, ok := r.aliases[name]; ok {
+		name = canonical
+	}
+	if cmd, ok := r.commands[name]; ok {
+		return name, cmd
+	}
+
+	if cmd, ok := r.adminCommands[name]; ok {
+		if !r.isAdmin(msg) {
+			return name, r.cmdForbidden
+		}
+		return name, cmd
+	}
+
+	// Suggest a close command rather than run one the user didn't ask
+	// for; anything else subscribes, as always.
+	if name != "" {
+		if suggestion, ok := r.suggest(name, r.isAdmin(msg)); ok {
+			return "unknown", cmdSuggestion(name, suggestion)
+		}
+	}
+
+	if r.channelOnly {
+		return "start", r.cmdChannelOnly
+	}
+	return "start", r.requireGroupAdmin(r.cmdStart)
+}
+
+func (r *commandRouter) isAdmin(msg *tgbotapi.Message) bool {
+	return msg.From != nil && r.admins[msg.From.ID]
+}
+
+// handleCallback handles presses of inline keyboard buttons.
+func (r *commandRouter) handleCallback(cq *tgbotapi.CallbackQuery) {
+	if cq.Message == nil {
+		return
+	}
+
+	answer := tgbotapi.NewCallback(cq.ID, "")
+	if height, ok := strings.CutPrefix(cq.Data, ackPrefix); ok {
+		answer.Text = r.acknowledge(cq.Message, height)
+	} else if height, ok := strings.CutPrefix(cq.Data, seenPrefix); ok {
+		answer.Text = r.markSeenFromButton(cq.Message, cq.From, height)
+	} else if page, ok := strings.CutPrefix(cq.Data, subscribersPagePrefix); ok {
+		if r.admins[cq.From.ID] {
+			r.showSubscribersPage(cq.Message, page)
+		} else {
+			answer.Text = "Эта команда доступна только администраторам"
+		}
+	} else if confirm, ok := strings.CutPrefix(cq.Data, resetPrefix); ok {
+		if r.admins[cq.From.ID] {
+			r.confirmReset(cq.Message, cq.From, confirm)
+		} else {
+			answer.Text = "Эта команда доступна только администраторам"
+		}
+	}
+
+	if _, err := r.bot.Request(answer); err != nil {
+		log.Printf("error: answer callback %s: %s", cq.ID, err.Error())
+	}
+}
+
+func reply(msg *tgbotapi.Message, text string) tgbotapi.MessageConfig {
+	resp := tgbotapi.NewMessage(msg.Chat.ID, text)
+	if !msg.Chat.IsChannel() {
+		resp.ReplyToMessageID = msg.MessageID
+	}
+	return resp
+}
+
+// replyLong is reply for text that may not fit one message: every part but
+// the last is sent right away, the last one is the reply.
+func (r *commandRouter) replyLong(msg *tgbotapi.Message, text string) tgbotapi.MessageConfig {
+	parts := splitMessage(text, maxMessageLength)
+	if len(parts) == 0 {
+		return reply(msg, text)
+	}
+	for _, part := range parts[:len(parts)-1] {
+		if _, err := r.bot.Send(reply(msg, part)); err != nil {
+			log.Printf("error: reply to %s: %s", chatRef(msg.Chat.ID), err.Error())
+		}
+	}
+	return reply(msg, parts[len(parts)-1])
+}
+
+func (r *commandRouter) cmdForbidden(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	return reply(msg, "Эта команда доступна только администраторам")
+}
+
+func (r *commandRouter) cmdStart(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	subscribed, known := wasSubscribed(r.store, msg.Chat.ID)
+	err := retry(r.subscribeAttempts, subscribeBackoff, func() error {
+		return r.store.Add(msg.Chat.ID)
+	})
+	if errors.Is(err, errStoreReadOnly) {
+		return reply(msg, subscriptionsClosed)
+	}
+	if err != nil {
+		log.Printf("error: subscribe %s: %s", chatRef(msg.Chat.ID), err.Error())
+		return reply(msg, "Ошибка при попытке подписаться на уведомления :c")
+	}
+	if known && !subscribed {
+		r.notifier.markJoined(msg.Chat.ID)
+		r.usage.countSubscribe()
+	}
+	if msg.Command() == "start" {
+		r.usage.recordSource(msg.Chat.ID, msg.CommandArguments())
+	}
+
+	if r.notifier.winBack(msg.Chat.ID) {
+		return reply(msg, "С возвращением! Ваши настройки сохранились, уведомления снова включены.")
+	}
+	return reply(msg, welcomeText(msg.Chat.Type))
+}
+
+func (r *commandRouter) cmdPools(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	return reply(msg, r.notifier.snapshot().poolsText(r.notifier.pools))
+}
+
+func (r *commandRouter) cmdUnsubscribe(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	subscribed, known := wasSubscribed(r.store, msg.Chat.ID)
+	err := r.store.Remove(msg.Chat.ID)
+	if errors.Is(err, errStoreReadOnly) {
+		return reply(msg, subscriptionsClosed)
+	}
+	if err != nil {
+		log.Printf("error: unsubscribe %s: %s", chatRef(msg.Chat.ID), err.Error())
+		return reply(msg, "Ошибка при попытке отписаться от уведомлений :c")
+	}
+	if known && subscribed {
+		r.notifier.retain(msg.Chat.ID)
+		r.usage.countUnsubscribe()
+	}
+
+	return reply(msg, fmt.Sprintf("Вы отписались от уведомлений. Подписаться снова: /subscribe, настройки сохранятся %s. Удалить их сразу: /delete", retentionDays(r.retention)))
+}
+
+// cmdPreview implements /preview: a notification about a made-up block in
+// the format the chat would get, template included.
+func (r *commandRouter) cmdPreview(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	text := r.notifier.messageFor(msg.Chat.ID, r.notifier.pools[0], generatePreviewBlock())
+	return reply(msg, "📋 Preview:\n"+text)
+}
+
+// generatePreviewBlock returns a typical block for /preview.
+func generatePreviewBlock() block {
+	return block{
+		height:      12345,
+		ts:          time.Now(),
+		hash:        "5f1e4a3c9b0d7e2f6a8c1b4d3e9f0a7c2b5d8e1f4a7c0b3d6e9f2a5c8b1d4e7f",
+		reward:      600000000000,
+		difficulty:  300000000000,
+		totalHashes: 261900000000,
+		effort:      0.873,
+	}
+}
+
+// poolFromArgs picks the pool named in the command arguments, the first
+// configured one by default.
+func (r *commandRouter) poolFromArgs(msg *tgbotapi.Message) (poolConfig, bool) {
+	name := msg.CommandArguments()
+	if name == "" {
+		return r.notifier.pools[0], true
+	}
+	return r.notifier.pool(name)
+}
+
+func unknownPool(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	return reply(msg, fmt.Sprintf("Пул %s не отслеживается, см. /pools", sanitize(msg.CommandArguments())))
+}
+
+// noHistoryYet is the reply of the stats commands until the first poll of
+// the pool has filled its history.
+const noHistoryYet = "история блоков ещё не собрана, попробуйте через минуту"
+
+// cmdLuck implements /luck [pool], from the block history.
+func (r *commandRouter) cmdLuck(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	pool, ok := r.poolFromArgs(msg)
+	if !ok {
+		return unknownPool(msg)
+	}
+
+	blocks := r.notifier.recentBlocks(pool.Name)
+	if len(blocks) == 0 {
+		return reply(msg, noHistoryYet)
+	}
+
+	return reply(msg, computeLuck(blocks, time.Now()).String())
+}
+
+// cmdHashrate implements /hashrate [pool], the current pool hashrate.
+func (r *commandRouter) cmdHashrate(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	pool, ok := r.poolFromArgs(msg)
+	if !ok {
+		return unknownPool(msg)
+	}
+	url, ok := pool.statsURL()
+	if !ok {
+		return reply(msg, fmt.Sprintf("Для пула %s не настроена статистика (StatsURL)", pool.Name))
+	}
+
+	stats, err := fetchPoolStats(url)
+	if err != nil {
+		log.Printf("error: %s: fetch pool stats: %s", pool.Name, err.Error())
+		return reply(msg, "Не удалось получить статистику пула")
+	}
+
+	return reply(msg, fmt.Sprintf("Хешрейт пула %s: %s, майнеров: %d", pool.Name, formatHashrate(uint64(stats.HashRate)), stats.Miners))
+}
+
+// cmdDiff implements /diff [pool], the time between the blocks in the
+// history.
+func (r *commandRouter) cmdDiff(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	pool, ok := r.poolFromArgs(msg)
+	if !ok {
+		return unknownPool(msg)
+	}
+
+	blocks := r.notifier.recentBlocks(pool.Name)
+	if len(blocks) < 2 {
+		return reply(msg, "Недостаточно блоков для расчёта")
+	}
+
+	avg, max := blockIntervals(blocks)
+	return reply(msg, fmt.Sprintf("Последние %d блоков пула %s:\nсреднее время между блоками: %s\nмаксимальное: %s",
+		len(blocks), pool.Name, avg.Round(time.Second), max.Round(time.Second)))
+}
+
+// cmdMissed implements /missed: how many blocks each pool found since the
+// chat was last notified.
+func (r *commandRouter) cmdMissed(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	var sb strings.Builder
+	for _, pool := range r.notifier.pools {
+		var last int
+		r.notifier.state.view(func(st state) {
+			last = st.Delivered[pool.Name][msg.Chat.ID]
+		})
+		if last == 0 {
+			fmt.Fprintf(&sb, "%s: уведомлений вам ещё не отправлялось\n", pool.Name)
+			continue
+		}
+
+		blocks := r.notifier.recentBlocks(pool.Name)
+		if len(blocks) == 0 {
+			fmt.Fprintf(&sb, "%s: %s\n", pool.Name, noHistoryYet)
+			continue
+		}
+
+		missed := 0
+		for _, b := range blocks {
+			if b.height <= last {
+				break
+			}
+			missed++
+		}
+
+		switch {
+		case missed == 0:
+			fmt.Fprintf(&sb, "%s: вы ничего не пропустили, последний блок %d\n", pool.Name, last)
+		case missed == len(blocks):
+			fmt.Fprintf(&sb, "%s: пропущено не меньше %d блоков с %d\n", pool.Name, missed, last)
+		default:
+			fmt.Fprintf(&sb, "%s: пропущено блоков: %d (%d → %d)\n", pool.Name, missed, last, blocks[0].height)
+		}
+	}
+
+	return reply(msg, sb.String())
+}
+
+// cmdWhichPool implements /whichpool <hashrate>.
+func (r *commandRouter) cmdWhichPool(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	hashrate, err := parseHashrate(msg.CommandArguments())
+	if err != nil || hashrate == 0 {
+		return reply(msg, "Использование: /whichpool <хешрейт>, например /whichpool 500h или /whichpool 12kh")
+	}
+
+	miniStats, err := fetchPoolStats(miniStatsURL)
+	if err != nil {
+		log.Printf("error: fetch mini stats: %s", err.Error())
+		return reply(msg, "Не удалось получить статистику пулов")
+	}
+	mainStats, err := fetchPoolStats(mainStatsURL)
+	if err != nil {
+		log.Printf("error: fetch main stats: %s", err.Error())
+		return reply(msg, "Не удалось получить статистику пулов")
+	}
+
+	switch recommendPool(float64(hashrate), miniStats, mainStats) {
+	case "mini":
+		return reply(msg, "С таким хешрейтом лучше майнить на p2pool mini: на основном пуле шары будут находиться слишком редко")
+	case "main":
+		return reply(msg, "Хешрейта достаточно для основного p2pool, но mini тоже подойдёт")
+	default:
+		return reply(msg, "Не удалось определить сложность пулов")
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	subscribersPageSize   = 20
+	subscribersPagePrefix = "subscribers:"
+)
+
+func (r *commandRouter) cmdUsage(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	return reply(msg, r.usage.report())
+}
+
+func (r *commandRouter) cmdStats(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	return reply(msg, r.notifier.latency.summary().String()+"\n\n"+r.notifier.tuner.String()+"\n\n"+r.notifier.snapshot().fetchText())
+}
+
+func (r *commandRouter) cmdPauseBot(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	if err := r.notifier.pause(); err != nil {
+		log.Printf("error: pause notifications: %s", err.Error())
+		return reply(msg, "Не удалось приостановить уведомления")
+	}
+
+	log.Printf("notifications paused by %s", chatRef(msg.From.ID))
+	r.notifier.audit.record(actorOf(msg), "pause", nil)
+	return reply(msg, "Уведомления приостановлены. Найденные блоки будут отправлены после /resumebot")
+}
+
+func (r *commandRouter) cmdResumeBot(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	deferred, stale, err := r.notifier.resume(context.Background())
+	if err != nil {
+		log.Printf("error: resume notifications: %s", err.Error())
+		return reply(msg, "Не удалось возобновить уведомления")
+	}
+
+	log.Printf("notifications resumed by %s, %d deferred blocks, %d stale", chatRef(msg.From.ID), deferred, stale)
+	r.notifier.audit.record(actorOf(msg), "resume", map[string]string{"deferred": strconv.Itoa(deferred), "stale": strconv.Itoa(stale)})
+	text := fmt.Sprintf("Уведомления возобновлены. Отложенных блоков к отправке: %d", deferred)
+	if stale > 0 {
+		text += fmt.Sprintf("\nУстаревших блоков (старше %s) пропущено: %d", r.notifier.deferredMaxAge, stale)
+	}
+	return reply(msg, text)
+}
+
+// cmdAdd implements /add <chat_id> and /addchat <chat_id>: it subscribes any
+// chat on its behalf, such as a group or channel the bot was added to, and
+// welcomes it. The chat is saved first, so it is never told about a
+// subscription that wasn't stored; if the welcome fails, it is removed
+// again.
+func (r *commandRouter) cmdAdd(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	chatID, err := strconv.ParseInt(strings.TrimSpace(msg.CommandArguments()), 10, 64)
+	if err != nil || validateChatID(chatID) != nil {
+		return reply(msg, fmt.Sprintf("Использование: /%s <ID чата>", msg.Command()))
+	}
+
+	subscribed, _ := wasSubscribed(r.store, chatID)
+	if subscribed {
+		return reply(msg, fmt.Sprintf("Чат %d уже подписан", chatID))
+	}
+	if err := r.store.Add(chatID); err != nil {
+		log.Printf("error: add %s: %s", chatRef(chatID), err.Error())
+		return reply(msg, "Не удалось сохранить чат")
+	}
+
+	welcome, err := r.bot.Send(tgbotapi.NewMessage(chatID, "Этот чат подписан на уведомления о блоках, найденных пулом https://p2pool.io/mini/#pool"))
+	if err != nil {
+		if rmErr := r.store.Remove(chatID); rmErr != nil {
+			log.Printf("error: remove %s after failed welcome: %s", chatRef(chatID), rmErr.Error())
+		}
+		return reply(msg, fmt.Sprintf("Не удалось отправить приветствие в чат %d, чат не подписан: %s", chatID, err.Error()))
+	}
+	r.notifier.rememberChatType(chatID, welcome.Chat.Type)
+	r.notifier.markJoined(chatID)
+
+	log.Printf("chat %s added by %s", chatRef(chatID), chatRef(msg.From.ID))
+	r.notifier.audit.record(actorOf(msg), "add_chat", map[string]string{"chat": strconv.FormatInt(chatID, 10)})
+	return reply(msg, fmt.Sprintf("Чат %d подписан и получил приветствие", chatID))
+}
+
+// cmdRemoveChat implements /removechat <chat_id>.
+func (r *commandRouter) cmdRemoveChat(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	chatID, err := strconv.ParseInt(strings.TrimSpace(msg.CommandArguments()), 10, 64)
+	if err != nil {
+		return reply(msg, "Использование: /removechat <ID чата>")
+	}
+
+	if err := r.store.Remove(chatID); err != nil {
+		log.Printf("error: remove chat %s: %s", chatRef(chatID), err.Error())
+		return reply(msg, "Не удалось удалить чат")
+	}
+
+	log.Printf("chat %s removed by %s", chatRef(chatID), chatRef(msg.From.ID))
+	r.notifier.audit.record(actorOf(msg), "remove_chat", map[string]string{"chat": strconv.FormatInt(chatID, 10)})
+	return reply(msg, fmt.Sprintf("Чат %d отписан от уведомлений", chatID))
+}
+
+// cmdSubscribers implements /subscribers list [page] and /subscribers find <query>.
+func (r *commandRouter) cmdSubscribers(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	args := strings.Fields(msg.CommandArguments())
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list":
+		page := 1
+		if len(args) > 1 {
+			var err error
+			page, err = strconv.Atoi(args[1])
+			if err != nil || page < 1 {
+				return reply(msg, "Номер страницы должен быть положительным числом")
+			}
+		}
+		return r.subscribersPage(msg, page)
+	case "find":
+		if len(args) < 2 {
+			return reply(msg, "Использование: /subscribers find <ID или часть имени>")
+		}
+		found, err := r.store.Find(r.notifier.subscriberQuery(strings.Join(args[1:], " ")))
+		if err != nil {
+			log.Printf("error: find subscribers: %s", err.Error())
+			return reply(msg, "Не удалось выполнить поиск")
+		}
+		if len(found) == 0 {
+			return reply(msg, "Ничего не найдено")
+		}
+		return r.replyLong(msg, fmt.Sprintf("Найдено: %d\n%s", len(found), r.formatSubscribers(found)))
+	default:
+		return reply(msg, "Использование: /subscribers list [страница] или /subscribers find <ID или часть имени>")
+	}
+}
+
+func (r *commandRouter) subscribersPage(msg *tgbotapi.Message, page int) tgbotapi.MessageConfig {
+	ids, total, err := r.store.List((page-1)*subscribersPageSize, subscribersPageSize)
+	if err != nil {
+		log.Printf("error: list subscribers: %s", err.Error())
+		return reply(msg, "Не удалось получить список подписчиков")
+	}
+
+	if total == 0 {
+		return reply(msg, "Подписчиков пока нет")
+	}
+
+	pages := (total + subscribersPageSize - 1) / subscribersPageSize
+	if len(ids) == 0 {
+		return reply(msg, fmt.Sprintf("Страницы %d нет, всего страниц: %d", page, pages))
+	}
+
+	resp := reply(msg, fmt.Sprintf("Подписчики, страница %d из %d (всего %d, ещё %d отписались с сохранёнными настройками):\n%s",
+		page, pages, total, r.notifier.retainedCount(), r.formatSubscribers(ids)))
+	if keyboard, ok := subscribersKeyboard(page, pages); ok {
+		resp.ReplyMarkup = keyboard
+	}
+	return resp
+}
+
+// showSubscribersPage replaces the listing in msg with the requested page.
+func (r *commandRouter) showSubscribersPage(msg *tgbotapi.Message, page string) {
+	n, err := strconv.Atoi(page)
+	if err != nil || n < 1 {
+		return
+	}
+
+	listing := r.subscribersPage(msg, n)
+	edit := tgbotapi.NewEditMessageText(msg.Chat.ID, msg.MessageID, listing.Text)
+	if keyboard, ok := listing.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup); ok {
+		edit.ReplyMarkup = &keyboard
+	}
+
+	if _, err := r.bot.Send(edit); err != nil {
+		log.Printf("error: edit subscribers page: %s", err.Error())
+	}
+}
+
+func subscribersKeyboard(page, pages int) (tgbotapi.InlineKeyboardMarkup, bool) {
+	var row []tgbotapi.InlineKeyboardButton
+	if page > 1 {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("« Назад", subscribersPagePrefix+strconv.Itoa(page-1)))
+	}
+	if page < pages {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("Вперёд »", subscribersPagePrefix+strconv.Itoa(page+1)))
+	}
+
+	if len(row) == 0 {
+		return tgbotapi.InlineKeyboardMarkup{}, false
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(row), true
+}
+
+// formatSubscribers shows a line per chat: ID, name unless hidden, type,
+// join date if known and status.
+func (r *commandRouter) formatSubscribers(ids []int64) string {
+	lines := make([]string, len(ids))
+	for i, id := range ids {
+		info := r.notifier.subscriberInfo(id)
+		line := strconv.FormatInt(id, 10)
+		switch {
+		case info.hidden:
+			line += " (имя скрыто)"
+		case info.name != "":
+			line += " " + info.name
+		}
+		line += fmt.Sprintf(" (%s)", r.notifier.chatType(id))
+		if !info.since.IsZero() {
+			line += ", с " + info.since.Format(time.DateOnly)
+		}
+		lines[i] = line + ", " + info.status
+	}
+	return strings.Join(lines, "\n")
+}
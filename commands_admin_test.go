@@ -0,0 +1,85 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestCmdAdd(t *testing.T) {
+	const admin, chat = 100, 200
+
+	tests := []struct {
+		name          string
+		brokenStore   bool
+		welcomeFails  bool
+		wantStored    bool
+		wantWelcomeTo bool
+	}{
+		{name: "subscribed and welcomed", wantStored: true, wantWelcomeTo: true},
+		{name: "welcome fails", welcomeFails: true},
+		{name: "store fails", brokenStore: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tg := newFakeTelegram(t)
+			if tt.welcomeFails {
+				tg.failFor(chat)
+			}
+			store := newTestStore(t)
+			if tt.brokenStore {
+				store = newFileStore(filepath.Join(t.TempDir(), "missing", "subscribers.txt"))
+				t.Cleanup(func() { store.Close() })
+			}
+			n := newTestNotifier(t, config{}, store)
+			n.bot = tg.bot(t)
+			r := &commandRouter{bot: n.bot, store: store, notifier: n}
+
+			r.cmdAdd(command(admin, "/add 200"))
+
+			ids, _ := store.Subscribers()
+			if got := slices.Contains(ids, chat); got != tt.wantStored {
+				t.Errorf("stored = %v, want %v", got, tt.wantStored)
+			}
+			if got := slices.Contains(tg.sentTo(), chat); got != tt.wantWelcomeTo {
+				t.Errorf("welcomed = %v, want %v", got, tt.wantWelcomeTo)
+			}
+		})
+	}
+}
+
+func TestCmdAddKeepsExistingSubscriber(t *testing.T) {
+	tg := newFakeTelegram(t)
+	tg.failFor(200)
+	store := newTestStore(t)
+	store.Add(200)
+	n := newTestNotifier(t, config{}, store)
+	n.bot = tg.bot(t)
+	r := &commandRouter{bot: n.bot, store: store, notifier: n}
+
+	r.cmdAdd(command(100, "/add 200"))
+
+	if ids, _ := store.Subscribers(); !slices.Contains(ids, 200) {
+		t.Error("/add of a subscribed chat it can't write to removed it")
+	}
+}
+
+func TestCmdAddChatWelcomes(t *testing.T) {
+	tg := newFakeTelegram(t)
+	store := newTestStore(t)
+	n := newTestNotifier(t, config{}, store)
+	n.bot = tg.bot(t)
+	r := newCommandRouter(n.bot, store, n, n.usage, config{})
+
+	if resp := r.adminCommands["addchat"](command(100, "/addchat x")); resp.Text != "Использование: /addchat <ID чата>" {
+		t.Errorf("usage = %q", resp.Text)
+	}
+	r.adminCommands["addchat"](command(100, "/addchat 200"))
+	if ids, _ := store.Subscribers(); !slices.Equal(ids, []int64{200}) {
+		t.Errorf("subscribers = %v, want [200]", ids)
+	}
+	if sent := tg.sentTo(); !slices.Equal(sent, []int64{200}) {
+		t.Errorf("sent to %v, want the welcome to 200", sent)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPreviewDoesNotSubscribe(t *testing.T) {
+	store := newTestStore(t)
+	n := newTestNotifier(t, config{}, store)
+	r := newCommandRouter(nil, store, n, n.usage, config{})
+
+	name, cmd := r.route(command(1, "/preview"))
+	if name != "preview" {
+		t.Fatalf("/preview routed to %q", name)
+	}
+	resp := cmd(command(1, "/preview"))
+
+	if count, _ := store.Count(); count != 0 {
+		t.Errorf("/preview subscribed the chat, %d subscribers", count)
+	}
+	if !strings.Contains(resp.Text, "12345") {
+		t.Errorf("preview = %q, want a notification about the sample block", resp.Text)
+	}
+}
+
+func TestPoolsListsConfiguredPools(t *testing.T) {
+	conf := config{Pools: []poolConfig{
+		{Name: "mini", URL: "http://mini.invalid/api/pool/blocks"},
+		{Name: "main", URL: "http://main.invalid/api/pool/blocks"},
+	}}
+	store := newTestStore(t)
+	n := newTestNotifier(t, conf, store)
+	n.lastBlocks.setLastBlock("mini", block{height: 3000001, ts: time.Now().Add(-5 * time.Minute)})
+	r := newCommandRouter(nil, store, n, n.usage, conf)
+
+	text := r.cmdPools(command(1, "/pools")).Text
+
+	for _, want := range []string{"mini — высота 3000001, последний блок 5m", "main — нет данных"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("/pools = %q, lacks %q", text, want)
+		}
+	}
+	if strings.Index(text, "mini") > strings.Index(text, "main") {
+		t.Errorf("/pools = %q, want the pools in config order", text)
+	}
+}
+
+func TestSubscribeCommands(t *testing.T) {
+	tests := []struct {
+		name           string
+		subscribed     bool
+		text           string
+		wantSubscribed bool
+	}{
+		{"start", false, "/start", true},
+		{"start with deep link", false, "/start reddit", true},
+		{"subscribe", false, "/subscribe", true},
+		{"unsubscribe", true, "/unsubscribe", false},
+		{"unsubscribe when not subscribed", false, "/unsubscribe", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t)
+			if tt.subscribed {
+				store.Add(1)
+			}
+			n := newTestNotifier(t, config{}, store)
+			r := newCommandRouter(nil, store, n, n.usage, config{})
+
+			msg := command(1, tt.text)
+			_, cmd := r.route(msg)
+			cmd(msg)
+
+			ids, _ := store.Subscribers()
+			if got := slices.Contains(ids, 1); got != tt.wantSubscribed {
+				t.Errorf("subscribed = %v, want %v", got, tt.wantSubscribed)
+			}
+		})
+	}
+}
+
+func TestNetworkCommandsDoNotHoldUpUpdates(t *testing.T) {
+	release := make(chan struct{})
+	stats := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		fmt.Fprint(w, `{"pool_statistics":{"hashRate":1000,"miners":3}}`)
+	}))
+	defer stats.Close()
+
+	tg := newFakeTelegram(t)
+	conf := config{Pools: []poolConfig{{Name: "mini", URL: "http://mini.invalid/api/pool/blocks", StatsURL: stats.URL}}}
+	store := newTestStore(t)
+	n := newTestNotifier(t, conf, store)
+	n.bot = tg.bot(t)
+	r := newCommandRouter(n.bot, store, n, n.usage, conf)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.handle(command(1, "/hashrate"))
+		r.handle(command(2, "/pools"))
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("a slow /hashrate held up the next update")
+	}
+	if texts := tg.waitForTexts(t, 1); !strings.Contains(texts[0], "mini") {
+		t.Errorf("first reply = %q, want /pools", texts[0])
+	}
+
+	close(release)
+	if texts := tg.waitForTexts(t, 2); !strings.Contains(texts[1], "Хешрейт пула mini") {
+		t.Errorf("second reply = %q, want /hashrate", texts[1])
+	}
+}
+
+func TestMissed(t *testing.T) {
+	pool := poolConfig{Name: defaultPoolName}
+	tg := newFakeTelegram(t)
+	store := newTestStore(t)
+	store.Add(1)
+	store.Add(2)
+	n := newTestNotifier(t, config{}, store)
+	n.bot = tg.bot(t)
+	r := newCommandRouter(nil, store, n, n.usage, config{})
+
+	notify := func(height int) {
+		t.Helper()
+		b := block{height: height, ts: time.Now()}
+		n.recordHistory(pool.Name, []block{b})
+		if err := n.broadcast(context.Background(), pool, []block{b}, func(int64) string { return "block" }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	notify(100)
+	// Chat 2 leaves and misses the next two blocks.
+	store.Remove(2)
+	notify(101)
+	notify(102)
+
+	tests := []struct {
+		chat int64
+		want string
+	}{
+		{1, "вы ничего не пропустили, последний блок 102"},
+		{2, "пропущено блоков: 2 (100 → 102)"},
+		{3, "уведомлений вам ещё не отправлялось"},
+	}
+	for _, tt := range tests {
+		if got := r.cmdMissed(command(tt.chat, "/missed")).Text; got != pool.Name+": "+tt.want+"\n" {
+			t.Errorf("/missed for chat %d = %q, want %q", tt.chat, got, tt.want)
+		}
+	}
+}
+
+func TestMissedBeyondHistory(t *testing.T) {
+	tests := []struct {
+		name    string
+		history []int
+		want    string
+	}{
+		{"older than the history", []int{201, 200}, "пропущено не меньше 2 блоков с 100"},
+		{"no history yet", nil, noHistoryYet},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t)
+			n := newTestNotifier(t, config{}, store)
+			r := newCommandRouter(nil, store, n, n.usage, config{})
+			n.recordDelivered(context.Background(), poolConfig{Name: defaultPoolName}, 100, []int64{1})
+			var blocks []block
+			for _, h := range tt.history {
+				blocks = append(blocks, block{height: h})
+			}
+			n.recordHistory(defaultPoolName, blocks)
+
+			if got := r.cmdMissed(command(1, "/missed")).Text; !strings.Contains(got, tt.want) {
+				t.Errorf("/missed = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,602 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultReseedMargin   = 5
+	defaultDeferredMaxAge = 6 * time.Hour
+)
+
+var errNoAPIKey = errors.New("no API key configured: set APIKey or APIKeyFile")
+
+type config struct {
+	ApiKey          string `toml:"APIKey"`
+	ApiKeyFile      string `toml:"APIKeyFile"`
+	Storage         string `toml:"Storage"`
+	SubscribersFile string `toml:"SubscribersFile"`
+	NotifyDuration  string `toml:"NotifyDuration"`
+	TimeFormat      string `toml:"TimeFormat"`
+	MessageTemplate string `toml:"MessageTemplate"`
+	BatchWindow     string `toml:"BatchWindow"`
+
+	// SkipFirstBlockOnStartup records the first block seen of a pool with
+	// no saved last block without announcing it. On by default.
+	SkipFirstBlockOnStartup *bool `toml:"SkipFirstBlockOnStartup"`
+	// NotificationFilters pick the blocks worth announcing.
+	NotificationFilters []filterConfig `toml:"NotificationFilters"`
+	// DisableJitter polls right away on startup and exactly every
+	// NotifyDuration, e.g. for tests.
+	DisableJitter bool `toml:"DisableJitter"`
+	// AutoTuneInterval polls less often than NotifyDuration when the pool
+	// API caches responses for longer, up to AutoTuneCeiling (2m by
+	// default).
+	AutoTuneInterval bool   `toml:"AutoTuneInterval"`
+	AutoTuneCeiling  string `toml:"AutoTuneCeiling"`
+	// StaleBlockAfter is the age of the newest block past which admins
+	// are told the API may be stuck.
+	StaleBlockAfter string `toml:"StaleBlockAfter"`
+	// InitialBroadcastDelay holds back announcements until this long after
+	// startup; off when unset.
+	InitialBroadcastDelay string `toml:"InitialBroadcastDelay"`
+	// DeferredMaxAge is the age past which blocks deferred during a pause
+	// are no longer announced on resume, 6h by default.
+	DeferredMaxAge string `toml:"DeferredMaxAge"`
+	// ReseedMargin is how many blocks the API tip may fall behind the
+	// stored last block before the bot starts over from the tip.
+	ReseedMargin int `toml:"ReseedMargin"`
+	// SkipAPIStartupCheck starts the bot even if a pool API is down.
+	SkipAPIStartupCheck bool `toml:"SkipAPIStartupCheck"`
+
+	// SubscribeRetries is how many times a failed subscription write is
+	// retried before the user is told about it.
+	SubscribeRetries *int `toml:"SubscribeRetries"`
+
+	Pools []poolConfig `toml:"Pools"`
+	// APIHeaders are sent with every request to the pools' block APIs.
+	APIHeaders map[string]string `toml:"APIHeaders"`
+	// CommandAliases maps extra command names to real commands, e.g.
+	// "help" = "pools", on top of the built-in aliases.
+	CommandAliases map[string]string `toml:"CommandAliases"`
+
+	// UnsubscribeRetention is how long the settings of a chat are kept
+	// after it unsubscribes, 30 days by default.
+	UnsubscribeRetention string `toml:"UnsubscribeRetention"`
+
+	// LinkPreviews lets Telegram expand links in notifications, off to
+	// keep them compact.
+	LinkPreviews bool `toml:"LinkPreviews"`
+	// DisableAckButtons sends notifications without the acknowledgment
+	// button.
+	DisableAckButtons bool `toml:"DisableAckButtons"`
+	// SeenButtons puts a "👍 Seen" button counting presses under
+	// notifications in channels instead of the acknowledgment button.
+	SeenButtons bool `toml:"SeenButtons"`
+
+	// ChannelOnlyMode sends notifications only to ChannelIDs and points
+	// everyone who tries to subscribe to ChannelName, e.g. "@p2pool_blocks".
+	ChannelOnlyMode bool    `toml:"ChannelOnlyMode"`
+	ChannelIDs      []int64 `toml:"ChannelIDs"`
+	ChannelName     string  `toml:"ChannelName"`
+
+	// OutboundLogPath is a file every message the bot sends is recorded
+	// in, as JSON lines; off when unset.
+	OutboundLogPath string `toml:"OutboundLogPath"`
+
+	// MQTT publishes found blocks and pool stats to a broker; off when the
+	// section is missing.
+	MQTT *mqttConfig `toml:"MQTT"`
+	// DisableChatOnboarding ignores the bot being added to groups and
+	// channels: no greeting, and channels aren't subscribed.
+	DisableChatOnboarding bool `toml:"DisableChatOnboarding"`
+	// AllowedUpdates overrides the Telegram update types to receive,
+	// derived from the features above by default.
+	AllowedUpdates []string `toml:"AllowedUpdates"`
+
+	// LogLevel is debug, info, warn or error; info by default.
+	LogLevel string `toml:"LogLevel"`
+	// LogRedactChatIDs replaces chat and user IDs in the log, the audit log
+	// and the outbound log with salted hashes.
+	LogRedactChatIDs bool `toml:"LogRedactChatIDs"`
+	// DebugPayloadFile keeps the last pool API response that failed to
+	// decode.
+	DebugPayloadFile string `toml:"DebugPayloadFile"`
+	// UserAgentSuffix is appended to the User-Agent of pool API requests.
+	UserAgentSuffix string `toml:"UserAgentSuffix"`
+	// PreferIPv6 makes the pool API client connect over IPv6 first.
+	PreferIPv6 bool `toml:"PreferIPv6"`
+
+	PruneInterval string `toml:"PruneInterval"`
+	// PruneAfterFailures is how many delivery failures in a row, counted
+	// across blocks and prune checks, drop a subscriber. 1 by default.
+	PruneAfterFailures int `toml:"PruneAfterFailures"`
+
+	// MilestoneHeights are the found block counts announced with a special
+	// message, defaultMilestones when unset. An empty list turns them off.
+	MilestoneHeights []int `toml:"MilestoneHeights"`
+
+	// MaintenanceWindows hold back alerts, like /maintenance does, between
+	// the given times.
+	MaintenanceWindows []maintenanceConfig `toml:"MaintenanceWindows"`
+
+	AdminIDs          []int64 `toml:"AdminIDs"`
+	StateFile         string  `toml:"StateFile"`
+	DisableUsageStats bool    `toml:"DisableUsageStats"`
+	RecoveryReport    bool    `toml:"RecoveryReport"`
+
+	// StartSources are the deep-link payloads, t.me/<bot>?start=<source>,
+	// counted by name in /usage. Others count as "other".
+	StartSources []string `toml:"StartSources"`
+
+	Webhooks           []string `toml:"Webhooks"`
+	WebhookTimeout     string   `toml:"WebhookTimeout"`
+	WebhookConcurrency int      `toml:"WebhookConcurrency"`
+
+	MoneroNodeURL  string `toml:"MoneroNodeURL"`
+	MoneroNodeUser string `toml:"MoneroNodeUser"`
+	MoneroNodePass string `toml:"MoneroNodePass"`
+
+	// DailyRollupTime is the local time, e.g. "06:00", to post the daily
+	// rollup to StatsChannelID at.
+	DailyRollupTime string `toml:"DailyRollupTime"`
+	StatsChannelID  int64  `toml:"StatsChannelID"`
+
+	HealthAddr        string `toml:"HealthAddr"`
+	HealthMaxFetchAge string `toml:"HealthMaxFetchAge"`
+	// PushAPIToken enables POST /api/notify on HealthAddr for requests
+	// bearing it.
+	PushAPIToken string `toml:"PushAPIToken"`
+
+	// MetricsTextfilePath, if set, receives the /metrics output after
+	// every poll, for the node_exporter textfile collector.
+	MetricsTextfilePath string `toml:"MetricsTextfilePath"`
+
+	// DonationAddress enables /donate when set.
+	DonationAddress string `toml:"DonationAddress"`
+}
+
+type poolConfig struct {
+	Name string `toml:"Name"`
+	URL  string `toml:"URL"`
+	// StatsURL is the pool stats endpoint for /hashrate, derived from URL
+	// for p2pool.io style APIs.
+	StatsURL string `toml:"StatsURL"`
+	// CrossCheckURLs are other APIs of the same pool in the same format.
+	// Every new block is looked up there too, and admins are alerted if a
+	// hash differs.
+	CrossCheckURLs []string `toml:"CrossCheckURLs"`
+
+	// Method, Body and ContentType (application/json when Body is set)
+	// make the blocks request for APIs that want more than a GET, e.g.
+	// GraphQL. BlocksPath, like "data.blocks", finds the blocks in an
+	// object response.
+	Method      string `toml:"Method"`
+	Body        string `toml:"Body"`
+	ContentType string `toml:"ContentType"`
+	BlocksPath  string `toml:"BlocksPath"`
+}
+
+// pools returns the configured pools, falling back to p2pool mini.
+func (c config) pools() []poolConfig {
+	if len(c.Pools) == 0 {
+		return []poolConfig{{Name: defaultPoolName, URL: blocksURL}}
+	}
+	return c.Pools
+}
+
+// timeFormat is the Go layout used for timestamps in notifications.
+func (c config) timeFormat() string {
+	if c.TimeFormat == "" {
+		return time.RFC850
+	}
+	return c.TimeFormat
+}
+
+// batchWindow returns zero, batching disabled, unless BatchWindow is set.
+func (c config) batchWindow() time.Duration {
+	window, _ := time.ParseDuration(c.BatchWindow)
+	return window
+}
+
+func (c config) skipFirstBlockOnStartup() bool {
+	return c.SkipFirstBlockOnStartup == nil || *c.SkipFirstBlockOnStartup
+}
+
+// notificationFilters returns the parsed NotificationFilters, which
+// validate has checked.
+func (c config) notificationFilters() []notificationFilter {
+	filters := make([]notificationFilter, 0, len(c.NotificationFilters))
+	for _, fc := range c.NotificationFilters {
+		if f, err := parseFilter(fc); err == nil {
+			filters = append(filters, f)
+		}
+	}
+	return filters
+}
+
+func (c config) logLevel() (slog.Level, error) {
+	var level slog.Level
+	if c.LogLevel == "" {
+		return slog.LevelInfo, nil
+	}
+	err := level.UnmarshalText([]byte(c.LogLevel))
+	return level, err
+}
+
+func (c config) autoTuneCeiling() time.Duration {
+	if c.AutoTuneCeiling == "" {
+		return defaultAutoTuneCeiling
+	}
+	d, _ := time.ParseDuration(c.AutoTuneCeiling)
+	return d
+}
+
+func (c config) pruneAfterFailures() int {
+	if c.PruneAfterFailures == 0 {
+		return 1
+	}
+	return c.PruneAfterFailures
+}
+
+func (c config) unsubscribeRetention() time.Duration {
+	if c.UnsubscribeRetention == "" {
+		return defaultUnsubscribeRetention
+	}
+	d, _ := time.ParseDuration(c.UnsubscribeRetention)
+	return d
+}
+
+func (c config) milestoneHeights() []int {
+	if c.MilestoneHeights == nil {
+		return defaultMilestones
+	}
+	return c.MilestoneHeights
+}
+
+func (c config) reseedMargin() int {
+	if c.ReseedMargin == 0 {
+		return defaultReseedMargin
+	}
+	return c.ReseedMargin
+}
+
+func (c config) deferredMaxAge() time.Duration {
+	if c.DeferredMaxAge == "" {
+		return defaultDeferredMaxAge
+	}
+	d, _ := time.ParseDuration(c.DeferredMaxAge)
+	return d
+}
+
+func (c config) subscribeAttempts() int {
+	if c.SubscribeRetries == nil {
+		return defaultSubscribeAttempts
+	}
+	return *c.SubscribeRetries + 1
+}
+
+// validStartSource reports whether Telegram allows source as a deep-link
+// payload.
+func validStartSource(source string) bool {
+	if len(source) == 0 || len(source) > 64 {
+		return false
+	}
+	for _, r := range source {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+func readConfig(configPath string) (config, error) {
+	file, err := os.Open(configPath)
+	if err != nil {
+		return config{}, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return config{}, err
+	}
+
+	var conf config
+	if err := configDecoder(configPath)(data, &conf); err != nil {
+		return config{}, err
+	}
+
+	return conf, nil
+}
+
+// resolveAPIKey returns the bot API key, preferring the contents of
+// APIKeyFile (e.g. a mounted Docker secret) over the inline APIKey.
+func resolveAPIKey(conf config) (string, error) {
+	if conf.ApiKeyFile != "" {
+		data, err := os.ReadFile(conf.ApiKeyFile)
+		if err != nil {
+			return "", err
+		}
+
+		apiKey := strings.TrimSpace(string(data))
+		if apiKey == "" {
+			return "", errNoAPIKey
+		}
+
+		return apiKey, nil
+	}
+
+	if conf.ApiKey == "" {
+		return "", errNoAPIKey
+	}
+
+	return conf.ApiKey, nil
+}
+
+// validate reports every problem found in the config rather than stopping
+// at the first one, so operators can fix them all in one go.
+func (c config) validate() []error {
+	var problems []error
+
+	if _, err := resolveAPIKey(c); err != nil {
+		problems = append(problems, err)
+	}
+
+	if _, ok := backendRegistry[backendName(c)]; !ok {
+		problems = append(problems, fmt.Errorf("unknown storage backend %q, see -list-backends", backendName(c)))
+	}
+
+	if c.SubscribersFile == "" {
+		problems = append(problems, errors.New("SubscribersFile is not set"))
+	}
+
+	if c.SubscribeRetries != nil && *c.SubscribeRetries < 0 {
+		problems = append(problems, errors.New("SubscribeRetries must not be negative"))
+	}
+
+	if c.ReseedMargin < 0 {
+		problems = append(problems, errors.New("ReseedMargin must not be negative"))
+	}
+
+	notifyDuration, err := time.ParseDuration(c.NotifyDuration)
+	if err != nil {
+		problems = append(problems, fmt.Errorf("NotifyDuration: %w", err))
+	} else if notifyDuration <= 0 {
+		problems = append(problems, errors.New("NotifyDuration must be positive"))
+	}
+
+	if c.PruneInterval != "" {
+		if d, err := time.ParseDuration(c.PruneInterval); err != nil {
+			problems = append(problems, fmt.Errorf("PruneInterval: %w", err))
+		} else if d <= 0 {
+			problems = append(problems, errors.New("PruneInterval must be positive"))
+		}
+	}
+
+	if c.MQTT != nil {
+		problems = append(problems, c.MQTT.validate()...)
+	}
+
+	if c.ChannelOnlyMode {
+		if len(c.ChannelIDs) == 0 {
+			problems = append(problems, errors.New("ChannelOnlyMode is set but ChannelIDs is empty"))
+		}
+		if c.ChannelName == "" {
+			problems = append(problems, errors.New("ChannelOnlyMode is set but ChannelName is not"))
+		}
+	}
+
+	for i, w := range c.MaintenanceWindows {
+		start, err := time.Parse(time.RFC3339, w.Start)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("MaintenanceWindows[%d].Start: %w", i, err))
+			continue
+		}
+		end, err := time.Parse(time.RFC3339, w.End)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("MaintenanceWindows[%d].End: %w", i, err))
+			continue
+		}
+		if !end.After(start) {
+			problems = append(problems, fmt.Errorf("MaintenanceWindows[%d] must end after it starts", i))
+		}
+	}
+
+	for _, h := range c.MilestoneHeights {
+		if h <= 0 {
+			problems = append(problems, fmt.Errorf("MilestoneHeights: %d is not positive", h))
+		}
+	}
+
+	if c.PruneAfterFailures < 0 {
+		problems = append(problems, errors.New("PruneAfterFailures must not be negative"))
+	}
+
+	problems = append(problems, c.validateAllowedUpdates()...)
+
+	if _, err := c.logLevel(); err != nil {
+		problems = append(problems, fmt.Errorf("LogLevel: %w", err))
+	}
+
+	if c.AutoTuneCeiling != "" {
+		if d, err := time.ParseDuration(c.AutoTuneCeiling); err != nil {
+			problems = append(problems, fmt.Errorf("AutoTuneCeiling: %w", err))
+		} else if d <= 0 {
+			problems = append(problems, errors.New("AutoTuneCeiling must be positive"))
+		}
+	}
+
+	if c.UnsubscribeRetention != "" {
+		if d, err := time.ParseDuration(c.UnsubscribeRetention); err != nil {
+			problems = append(problems, fmt.Errorf("UnsubscribeRetention: %w", err))
+		} else if d < 0 {
+			problems = append(problems, errors.New("UnsubscribeRetention must not be negative"))
+		}
+	}
+
+	if c.StaleBlockAfter != "" {
+		if d, err := time.ParseDuration(c.StaleBlockAfter); err != nil {
+			problems = append(problems, fmt.Errorf("StaleBlockAfter: %w", err))
+		} else if d <= 0 {
+			problems = append(problems, errors.New("StaleBlockAfter must be positive"))
+		}
+	}
+
+	if c.DeferredMaxAge != "" {
+		if d, err := time.ParseDuration(c.DeferredMaxAge); err != nil {
+			problems = append(problems, fmt.Errorf("DeferredMaxAge: %w", err))
+		} else if d <= 0 {
+			problems = append(problems, errors.New("DeferredMaxAge must be positive"))
+		}
+	}
+
+	if c.InitialBroadcastDelay != "" {
+		if d, err := time.ParseDuration(c.InitialBroadcastDelay); err != nil {
+			problems = append(problems, fmt.Errorf("InitialBroadcastDelay: %w", err))
+		} else if d < 0 {
+			problems = append(problems, errors.New("InitialBroadcastDelay must not be negative"))
+		}
+	}
+
+	if c.BatchWindow != "" {
+		if d, err := time.ParseDuration(c.BatchWindow); err != nil {
+			problems = append(problems, fmt.Errorf("BatchWindow: %w", err))
+		} else if d < 0 {
+			problems = append(problems, errors.New("BatchWindow must not be negative"))
+		}
+	}
+
+	// A layout without any time elements formats every time to itself.
+	sample := time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+	if c.TimeFormat != "" && sample.Format(c.TimeFormat) == c.TimeFormat {
+		problems = append(problems, fmt.Errorf("TimeFormat %q contains no time elements", c.TimeFormat))
+	}
+
+	if c.MessageTemplate != "" {
+		if _, err := parseMessageTemplate(c.MessageTemplate); err != nil {
+			problems = append(problems, fmt.Errorf("MessageTemplate: %w", err))
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i, pool := range c.Pools {
+		if pool.Name == "" || pool.URL == "" {
+			problems = append(problems, fmt.Errorf("Pools[%d]: Name and URL are required", i))
+		}
+		if seen[pool.Name] {
+			problems = append(problems, fmt.Errorf("Pools[%d]: duplicate pool name %q", i, pool.Name))
+		}
+		seen[pool.Name] = true
+		switch strings.ToUpper(pool.Method) {
+		case "", http.MethodGet, http.MethodPost:
+		default:
+			problems = append(problems, fmt.Errorf("Pools[%d]: Method must be GET or POST", i))
+		}
+		if pool.Body != "" && !strings.EqualFold(pool.Method, http.MethodPost) {
+			problems = append(problems, fmt.Errorf("Pools[%d]: a Body needs Method = \"POST\"", i))
+		}
+	}
+
+	for name := range c.APIHeaders {
+		if strings.TrimSpace(name) == "" {
+			problems = append(problems, errors.New("APIHeaders: empty header name"))
+		}
+	}
+
+	for i, fc := range c.NotificationFilters {
+		if _, err := parseFilter(fc); err != nil {
+			problems = append(problems, fmt.Errorf("NotificationFilters[%d]: %w", i, err))
+		}
+	}
+
+	for _, source := range c.StartSources {
+		if !validStartSource(source) {
+			problems = append(problems, fmt.Errorf("StartSources: %q must be 1-64 characters of A-Z, a-z, 0-9, _ and -", source))
+		}
+		if source == sourceOther {
+			problems = append(problems, fmt.Errorf("StartSources: %q is reserved", source))
+		}
+	}
+
+	if c.WebhookTimeout != "" {
+		if _, err := time.ParseDuration(c.WebhookTimeout); err != nil {
+			problems = append(problems, fmt.Errorf("WebhookTimeout: %w", err))
+		}
+	}
+
+	if c.WebhookConcurrency < 0 {
+		problems = append(problems, errors.New("WebhookConcurrency must not be negative"))
+	}
+
+	if c.MoneroNodeUser != "" && c.MoneroNodeURL == "" {
+		problems = append(problems, errors.New("MoneroNodeUser is set but MoneroNodeURL is not"))
+	}
+
+	if c.DailyRollupTime != "" {
+		if _, err := time.Parse(rollupTimeLayout, c.DailyRollupTime); err != nil {
+			problems = append(problems, fmt.Errorf("DailyRollupTime: want HH:MM, got %q", c.DailyRollupTime))
+		}
+		if c.StatsChannelID == 0 {
+			problems = append(problems, errors.New("DailyRollupTime is set but StatsChannelID is not"))
+		}
+	}
+
+	if c.PushAPIToken != "" && c.HealthAddr == "" {
+		problems = append(problems, errors.New("PushAPIToken is set but HealthAddr is not"))
+	}
+
+	if c.DonationAddress != "" {
+		if err := validateMoneroAddress(c.DonationAddress); err != nil {
+			problems = append(problems, fmt.Errorf("DonationAddress: %w", err))
+		}
+	}
+
+	if c.HealthMaxFetchAge != "" {
+		if _, err := time.ParseDuration(c.HealthMaxFetchAge); err != nil {
+			problems = append(problems, fmt.Errorf("HealthMaxFetchAge: %w", err))
+		}
+	}
+
+	return problems
+}
+
+// runValidate implements the `validate` subcommand: it loads and validates
+// the config without connecting to Telegram and returns the exit code.
+func runValidate(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	flags.SetOutput(out)
+	configPath := flags.String("config", defaultConfigPath, "path to the config file")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	conf, err := readConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return 1
+	}
+
+	problems := conf.validate()
+	if len(problems) == 0 {
+		for _, w := range lint(conf) {
+			fmt.Fprintf(out, "warning: %s\n", w)
+		}
+		fmt.Fprintln(out, "OK")
+		return 0
+	}
+
+	for _, problem := range problems {
+		fmt.Fprintf(out, "- %s\n", problem)
+	}
+
+	return 1
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveAPIKey(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "api_key")
+	if err := os.WriteFile(keyFile, []byte("  from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	emptyFile := filepath.Join(dir, "empty")
+	if err := os.WriteFile(emptyFile, []byte("\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		conf    config
+		want    string
+		wantErr error
+	}{
+		{name: "from config", conf: config{ApiKey: "from-config"}, want: "from-config"},
+		{name: "from file", conf: config{ApiKeyFile: keyFile}, want: "from-file"},
+		{name: "file wins", conf: config{ApiKey: "from-config", ApiKeyFile: keyFile}, want: "from-file"},
+		{name: "neither", conf: config{}, wantErr: errNoAPIKey},
+		{name: "empty file", conf: config{ApiKeyFile: emptyFile}, wantErr: errNoAPIKey},
+		{name: "missing file", conf: config{ApiKeyFile: filepath.Join(dir, "missing")}, wantErr: os.ErrNotExist},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveAPIKey(tt.conf)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("resolveAPIKey = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		wantCode int
+		wantOut  string
+	}{
+		{"valid", "APIKey = \"key\"\nSubscribersFile = \"subscribers.txt\"\nNotifyDuration = \"30s\"\n", 0, "OK"},
+		{"no api key", "NotifyDuration = \"30s\"\n", 1, errNoAPIKey.Error()},
+		{"bad duration", "APIKey = \"key\"\nNotifyDuration = \"soon\"\n", 1, "NotifyDuration"},
+		{"not toml", "APIKey = \n", 1, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.toml")
+			if err := os.WriteFile(path, []byte(tt.config), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			var out strings.Builder
+			code := runValidate([]string{"-config", path}, &out)
+			if code != tt.wantCode {
+				t.Errorf("exit code = %d, want %d\n%s", code, tt.wantCode, out.String())
+			}
+			if !strings.Contains(out.String(), tt.wantOut) {
+				t.Errorf("output %q lacks %q", out.String(), tt.wantOut)
+			}
+		})
+	}
+}
+
+func TestRunValidateMissingFile(t *testing.T) {
+	var out strings.Builder
+	if code := runValidate([]string{"-config", filepath.Join(t.TempDir(), "missing.toml")}, &out); code != 1 {
+		t.Errorf("exit code = %d, want 1", code)
+	}
+}
+
+func TestTimeFormat(t *testing.T) {
+	ts := time.Date(2024, 3, 5, 14, 7, 9, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		layout  string
+		want    string
+		invalid bool
+	}{
+		{name: "default", want: ts.Format(time.RFC850)},
+		{name: "iso", layout: "2006-01-02 15:04:05 MST", want: "2024-03-05 14:07:09 UTC"},
+		{name: "no time elements", layout: "hello", invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := config{ApiKey: "key", TimeFormat: tt.layout}
+			if invalid := hasProblem(conf.validate(), "TimeFormat"); invalid != tt.invalid {
+				t.Fatalf("TimeFormat problem = %v, want %v", invalid, tt.invalid)
+			}
+			if tt.invalid {
+				return
+			}
+
+			n := newTestNotifier(t, conf, newTestStore(t))
+			text := n.blockMessage(poolConfig{Name: defaultPoolName}, block{height: 1, ts: ts})
+			if !strings.Contains(text, "время: "+tt.want) {
+				t.Errorf("message = %q, want the time as %q", text, tt.want)
+			}
+		})
+	}
+}
+
+// hasProblem reports whether one of problems mentions field.
+func hasProblem(problems []error, field string) bool {
+	for _, p := range problems {
+		if strings.Contains(p.Error(), field) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestStartSources(t *testing.T) {
+	tests := []struct {
+		source  string
+		invalid bool
+	}{
+		{source: "site"},
+		{source: "pinned_post-2"},
+		{source: strings.Repeat("a", 64)},
+		{source: strings.Repeat("a", 65), invalid: true},
+		{source: "", invalid: true},
+		{source: "форум", invalid: true},
+		{source: "a b", invalid: true},
+		{source: sourceOther, invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			conf := config{ApiKey: "key", StartSources: []string{tt.source}}
+			if invalid := hasProblem(conf.validate(), "StartSources"); invalid != tt.invalid {
+				t.Errorf("StartSources problem = %v, want %v", invalid, tt.invalid)
+			}
+		})
+	}
+}
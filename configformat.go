@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+type configDecodeFunc func(data []byte, conf *config) error
+
+// configDecoders decode the config by file extension. Keys are the same in
+// every format, e.g. NotifyDuration, so a config converts one to one.
+var configDecoders = map[string]configDecodeFunc{
+	".toml": decodeTOMLConfig,
+	".json": decodeJSONConfig,
+	".yaml": decodeYAMLConfig,
+	".yml":  decodeYAMLConfig,
+}
+
+// configDecoder picks the decoder for path, TOML for unknown extensions.
+func configDecoder(path string) configDecodeFunc {
+	if decode, ok := configDecoders[strings.ToLower(filepath.Ext(path))]; ok {
+		return decode
+	}
+	return decodeTOMLConfig
+}
+
+func decodeTOMLConfig(data []byte, conf *config) error {
+	_, err := toml.Decode(string(data), conf)
+	return err
+}
+
+// decodeJSONConfig relies on encoding/json matching keys to field names
+// case-insensitively, so the toml tags need no json twins.
+func decodeJSONConfig(data []byte, conf *config) error {
+	return json.Unmarshal(data, conf)
+}
+
+// decodeYAMLConfig goes through JSON: yaml.v3 only matches lowercased field
+// names, which would make the keys differ from the other formats.
+func decodeYAMLConfig(data []byte, conf *config) error {
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, conf)
+}
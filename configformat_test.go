@@ -0,0 +1,140 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadConfigFormats(t *testing.T) {
+	skip := false
+	want := config{
+		ApiKey:                  "key",
+		NotifyDuration:          "5m",
+		AdminIDs:                []int64{7, 8},
+		SkipFirstBlockOnStartup: &skip,
+		Pools: []poolConfig{
+			{Name: "mini", URL: "https://mini.p2pool.io/api/pool/blocks"},
+			{Name: "main", URL: "https://p2pool.io/api/pool/blocks", CrossCheckURLs: []string{"https://mirror.invalid/api/pool/blocks"}},
+		},
+		MaintenanceWindows: []maintenanceConfig{{Start: "2024-01-01T00:00:00Z", End: "2024-01-01T02:00:00Z"}},
+	}
+
+	tests := []struct {
+		file string
+		data string
+	}{
+		{"config.toml", `
+APIKey = "key"
+NotifyDuration = "5m"
+AdminIDs = [7, 8]
+SkipFirstBlockOnStartup = false
+
+[[Pools]]
+Name = "mini"
+URL = "https://mini.p2pool.io/api/pool/blocks"
+
+[[Pools]]
+Name = "main"
+URL = "https://p2pool.io/api/pool/blocks"
+CrossCheckURLs = ["https://mirror.invalid/api/pool/blocks"]
+
+[[MaintenanceWindows]]
+Start = "2024-01-01T00:00:00Z"
+End = "2024-01-01T02:00:00Z"
+`},
+		{"config.json", `{
+	"APIKey": "key",
+	"NotifyDuration": "5m",
+	"AdminIDs": [7, 8],
+	"SkipFirstBlockOnStartup": false,
+	"Pools": [
+		{"Name": "mini", "URL": "https://mini.p2pool.io/api/pool/blocks"},
+		{"Name": "main", "URL": "https://p2pool.io/api/pool/blocks", "CrossCheckURLs": ["https://mirror.invalid/api/pool/blocks"]}
+	],
+	"MaintenanceWindows": [{"Start": "2024-01-01T00:00:00Z", "End": "2024-01-01T02:00:00Z"}]
+}`},
+		{"config.yaml", `
+APIKey: key
+NotifyDuration: 5m
+AdminIDs: [7, 8]
+SkipFirstBlockOnStartup: false
+Pools:
+  - Name: mini
+    URL: https://mini.p2pool.io/api/pool/blocks
+  - Name: main
+    URL: https://p2pool.io/api/pool/blocks
+    CrossCheckURLs:
+      - https://mirror.invalid/api/pool/blocks
+MaintenanceWindows:
+  - Start: "2024-01-01T00:00:00Z"
+    End: "2024-01-01T02:00:00Z"
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.data), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := readConfig(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("config = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestConfigDecoder(t *testing.T) {
+	tests := []struct {
+		path string
+		data string
+		want string
+	}{
+		{"config.toml", `APIKey = "toml"`, "toml"},
+		{"CONFIG.JSON", `{"APIKey": "json"}`, "json"},
+		{"config.yml", `APIKey: yaml`, "yaml"},
+		{"config.YAML", `APIKey: yaml`, "yaml"},
+		{"config", `APIKey = "toml"`, "toml"},
+		{"config.conf", `APIKey = "toml"`, "toml"},
+		{"empty.yaml", ``, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			var conf config
+			if err := configDecoder(tt.path)([]byte(tt.data), &conf); err != nil {
+				t.Fatal(err)
+			}
+			if conf.ApiKey != tt.want {
+				t.Errorf("APIKey = %q, want %q", conf.ApiKey, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigDecoderErrors(t *testing.T) {
+	tests := []struct {
+		path string
+		data string
+	}{
+		{"config.toml", `APIKey = `},
+		{"config.json", `{"APIKey": }`},
+		{"config.yaml", "APIKey: [unclosed"},
+		// A config in one format isn't read as another.
+		{"config.json", `APIKey = "toml"`},
+	}
+
+	for _, tt := range tests {
+		var conf config
+		if err := configDecoder(tt.path)([]byte(tt.data), &conf); err == nil {
+			t.Errorf("%s: no error decoding %q", tt.path, tt.data)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const redacted = "<redacted>"
+
+// diagnostics is the dump /debug sends.
+type diagnostics struct {
+	TakenAt          time.Time                  `json:"taken_at"`
+	Version          string                     `json:"version"`
+	StartTime        time.Time                  `json:"start_time"`
+	LastBlockChecked map[string]savedBlock      `json:"last_block_checked"`
+	Fetches          map[string]poolFetchStatus `json:"fetches"`
+	Counters         map[string]int64           `json:"counters"`
+	Subscribers      *int                       `json:"subscribers"`
+	State            json.RawMessage            `json:"state"`
+	Config           config                     `json:"config"`
+}
+
+// censor returns conf without its secrets, for dumps.
+func censor(conf config) config {
+	redact := func(s *string) {
+		if *s != "" {
+			*s = redacted
+		}
+	}
+	redact(&conf.ApiKey)
+	redact(&conf.MoneroNodePass)
+	redact(&conf.PushAPIToken)
+	if conf.MQTT != nil {
+		mqtt := *conf.MQTT
+		redact(&mqtt.Password)
+		conf.MQTT = &mqtt
+	}
+
+	headers := make(map[string]string, len(conf.APIHeaders))
+	for name := range conf.APIHeaders {
+		headers[name] = redacted
+	}
+	conf.APIHeaders = headers
+
+	// Webhook URLs often carry a token.
+	webhooks := make([]string, len(conf.Webhooks))
+	for i := range webhooks {
+		webhooks[i] = redacted
+	}
+	conf.Webhooks = webhooks
+	return conf
+}
+
+// collectDiagnostics dumps what the bot knows right now as indented JSON.
+func (n *Notifier) collectDiagnostics(conf config) ([]byte, error) {
+	s := n.snapshot()
+	d := diagnostics{
+		TakenAt:          s.takenAt,
+		Version:          version,
+		StartTime:        s.startedAt,
+		LastBlockChecked: make(map[string]savedBlock, len(s.lastBlocks)),
+		Fetches:          make(map[string]poolFetchStatus, len(s.fetches)),
+		Counters: map[string]int64{
+			"blocks_found":        n.stats.blocksFound.Load(),
+			"notifications_sent":  n.stats.notificationsSent.Load(),
+			"notification_errors": n.stats.notificationErrors.Load(),
+			"polls":               n.stats.polls.Load(),
+			"failed_in_a_row":     n.stats.failedInARow.Load(),
+			"audit_failures":      n.audit.failed.Load(),
+		},
+		Subscribers: subscriberCount(n.store),
+		Config:      censor(conf),
+	}
+	for name, b := range s.lastBlocks {
+		d.LastBlockChecked[name] = newSavedBlock(name, b)
+	}
+	for name, f := range s.fetches {
+		d.Fetches[name] = f.status()
+	}
+
+	var err error
+	n.state.view(func(st state) { d.State, err = json.Marshal(st) })
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// cmdDebug implements /debug: it sends the admin a diagnostic dump as a
+// file, which unlike a message has no length limit.
+func (r *commandRouter) cmdDebug(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	data, err := r.notifier.collectDiagnostics(r.conf)
+	if err != nil {
+		log.Printf("error: collect diagnostics: %s", err.Error())
+		return reply(msg, "Не удалось собрать диагностику")
+	}
+
+	name := fmt.Sprintf("p2pool-notifier-debug-%d.json", time.Now().Unix())
+	doc := tgbotapi.NewDocument(msg.From.ID, tgbotapi.FileBytes{Name: name, Bytes: data})
+	if _, err := r.bot.Send(doc); err != nil {
+		log.Printf("error: send diagnostics to %s: %s", chatRef(msg.From.ID), err.Error())
+		return reply(msg, "Не удалось отправить диагностику, напишите боту в личные сообщения и повторите")
+	}
+	return reply(msg, "Диагностика отправлена в личные сообщения")
+}
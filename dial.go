@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// ipv6HeadStart is how long IPv6 addresses get to connect before IPv4 ones
+// are tried as well.
+const ipv6HeadStart = 500 * time.Millisecond
+
+// lookupIPAddr resolves hosts for dialPreferIPv6.
+var lookupIPAddr = net.DefaultResolver.LookupIPAddr
+
+// dialPreferIPv6 connects to IPv6 addresses of the host first and only
+// falls back to IPv4 once IPv6 failed or had ipv6HeadStart to connect,
+// after the Happy Eyeballs idea. Go's own dialer follows the resolver's
+// order, which favours IPv4 on some systems.
+func dialPreferIPv6(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := lookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var v6, v4 []string
+	for _, addr := range addrs {
+		if addr.IP.To4() == nil {
+			v6 = append(v6, net.JoinHostPort(addr.String(), port))
+		} else {
+			v4 = append(v4, net.JoinHostPort(addr.String(), port))
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	results := make(chan result)
+	dialAll := func(addrs []string) {
+		var d net.Dialer
+		var err error
+		for _, addr := range addrs {
+			var conn net.Conn
+			conn, err = d.DialContext(ctx, network, addr)
+			if err == nil {
+				select {
+				case results <- result{conn: conn}:
+				case <-ctx.Done():
+					conn.Close()
+				}
+				return
+			}
+		}
+		if err == nil {
+			err = errors.New("no addresses")
+		}
+		select {
+		case results <- result{err: err}:
+		case <-ctx.Done():
+		}
+	}
+
+	pending := 0
+	if len(v6) > 0 {
+		go dialAll(v6)
+		pending++
+	}
+
+	headStart := time.NewTimer(ipv6HeadStart)
+	defer headStart.Stop()
+	if len(v6) == 0 {
+		headStart.Reset(0)
+	}
+
+	var firstErr error
+	v4Started := len(v4) == 0
+	for pending > 0 || !v4Started {
+		select {
+		case <-headStart.C:
+			if !v4Started {
+				go dialAll(v4)
+				pending++
+				v4Started = true
+			}
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				return res.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = res.err
+			}
+			if !v4Started {
+				go dialAll(v4)
+				pending++
+				v4Started = true
+			}
+		}
+	}
+
+	if firstErr == nil {
+		firstErr = errors.New("no addresses for " + host)
+	}
+	return nil, firstErr
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDialPreferIPv6(t *testing.T) {
+	v4, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v4.Close()
+	port := strconv.Itoa(v4.Addr().(*net.TCPAddr).Port)
+
+	v6, err := net.Listen("tcp", net.JoinHostPort("::1", port))
+	if err != nil {
+		t.Skipf("no IPv6 loopback: %s", err)
+	}
+	defer v6.Close()
+
+	loopback4 := net.IPAddr{IP: net.ParseIP("127.0.0.1")}
+	loopback6 := net.IPAddr{IP: net.ParseIP("::1")}
+	closedPort := func() string {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		l.Close()
+		return strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+	}()
+
+	tests := []struct {
+		name    string
+		addrs   []net.IPAddr
+		port    string
+		want    string
+		wantErr bool
+	}{
+		{name: "dual stack", addrs: []net.IPAddr{loopback4, loopback6}, port: port, want: "::1"},
+		{name: "IPv4 only", addrs: []net.IPAddr{loopback4}, port: port, want: "127.0.0.1"},
+		{name: "IPv6 only", addrs: []net.IPAddr{loopback6}, port: port, want: "::1"},
+		{name: "nothing listens", addrs: []net.IPAddr{loopback4, loopback6}, port: closedPort, wantErr: true},
+		{name: "no addresses", port: port, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookupIPAddr = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+				return tt.addrs, nil
+			}
+			t.Cleanup(func() { lookupIPAddr = net.DefaultResolver.LookupIPAddr })
+
+			conn, err := dialPreferIPv6(context.Background(), "tcp", net.JoinHostPort("pool.test", tt.port))
+			if tt.wantErr {
+				if err == nil {
+					conn.Close()
+					t.Fatal("no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			if got := conn.RemoteAddr().(*net.TCPAddr).IP.String(); got != tt.want {
+				t.Errorf("connected to %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialPreferIPv6FallsBackQuickly(t *testing.T) {
+	v4, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v4.Close()
+	port := strconv.Itoa(v4.Addr().(*net.TCPAddr).Port)
+	if l, err := net.Listen("tcp", net.JoinHostPort("::1", port)); err != nil {
+		t.Skipf("no IPv6 loopback: %s", err)
+	} else {
+		l.Close()
+	}
+
+	lookupIPAddr = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		return []net.IPAddr{{IP: net.ParseIP("::1")}, {IP: net.ParseIP("127.0.0.1")}}, nil
+	}
+	t.Cleanup(func() { lookupIPAddr = net.DefaultResolver.LookupIPAddr })
+
+	// IPv6 is refused at once, so IPv4 needn't wait out the head start.
+	start := time.Now()
+	conn, err := dialPreferIPv6(context.Background(), "tcp", net.JoinHostPort("pool.test", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if took := time.Since(start); took >= ipv6HeadStart {
+		t.Errorf("dial took %s, want IPv4 tried as soon as IPv6 failed", took)
+	}
+	if got := conn.RemoteAddr().(*net.TCPAddr).IP.String(); got != "127.0.0.1" {
+		t.Errorf("connected to %s, want 127.0.0.1", got)
+	}
+}
+
+func TestDialPreferIPv6LookupError(t *testing.T) {
+	errLookup := errors.New("no such host")
+	lookupIPAddr = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		return nil, errLookup
+	}
+	t.Cleanup(func() { lookupIPAddr = net.DefaultResolver.LookupIPAddr })
+
+	if _, err := dialPreferIPv6(context.Background(), "tcp", "pool.test:443"); !errors.Is(err, errLookup) {
+		t.Errorf("error = %v, want %v", err, errLookup)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const moneroBase58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+var errInvalidMoneroAddress = errors.New("not a Monero mainnet address")
+
+// validateMoneroAddress checks the shape of a mainnet address: a standard
+// address or subaddress (95 characters, starting with 4 or 8) or an
+// integrated address (106 characters, starting with 4). The checksum isn't
+// verified, it needs Keccak.
+func validateMoneroAddress(addr string) error {
+	switch {
+	case len(addr) == 95 && (addr[0] == '4' || addr[0] == '8'):
+	case len(addr) == 106 && addr[0] == '4':
+	default:
+		return errInvalidMoneroAddress
+	}
+
+	for _, r := range addr {
+		if !strings.ContainsRune(moneroBase58Alphabet, r) {
+			return errInvalidMoneroAddress
+		}
+	}
+	return nil
+}
+
+// cmdDonate implements /donate. It is only registered when the operator
+// set DonationAddress, which can't be changed from Telegram.
+func (r *commandRouter) cmdDonate(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	resp := reply(msg, fmt.Sprintf("Бот работает за счёт оператора. Поддержать его можно переводом в Monero:\n\n<code>%s</code>\n\nmonero:%s",
+		r.donationAddress, r.donationAddress))
+	resp.ParseMode = tgbotapi.ModeHTML
+	return resp
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// filterConfig is a [[NotificationFilters]] entry, e.g.
+// Condition = "effort > 150", Action = "suppress".
+type filterConfig struct {
+	Condition string `toml:"Condition"`
+	Action    string `toml:"Action"`
+}
+
+// notificationFilter decides whether a block is announced. Effort is in
+// percent and reward in XMR, as they are shown in notifications.
+type notificationFilter struct {
+	field    string
+	op       string
+	value    float64
+	suppress bool
+}
+
+var filterOperators = []string{">=", "<=", "==", ">", "<"}
+
+var filterFields = map[string]func(b block) (float64, bool){
+	"height": func(b block) (float64, bool) { return float64(b.height), true },
+	"effort": func(b block) (float64, bool) { return b.effort * 100, b.effort != 0 },
+	"reward": func(b block) (float64, bool) { return float64(b.reward) / 1e12, b.reward != 0 },
+	"difficulty": func(b block) (float64, bool) {
+		return float64(b.difficulty), b.difficulty != 0
+	},
+}
+
+func parseFilter(fc filterConfig) (notificationFilter, error) {
+	var f notificationFilter
+	switch fc.Action {
+	case "suppress":
+		f.suppress = true
+	case "send":
+	default:
+		return f, fmt.Errorf("action %q: want suppress or send", fc.Action)
+	}
+
+	var err error
+	f.field, f.op, f.value, err = parseCondition(fc.Condition)
+	return f, err
+}
+
+// parseCondition splits "<field> <operator> <number>", spaces optional.
+func parseCondition(cond string) (field, op string, value float64, err error) {
+	rest := strings.TrimSpace(cond)
+
+	i := 0
+	for i < len(rest) && (rest[i] >= 'a' && rest[i] <= 'z' || rest[i] == '_') {
+		i++
+	}
+	field, rest = rest[:i], strings.TrimSpace(rest[i:])
+	if _, ok := filterFields[field]; !ok {
+		return "", "", 0, fmt.Errorf("condition %q: unknown field %q", cond, field)
+	}
+
+	for _, candidate := range filterOperators {
+		if strings.HasPrefix(rest, candidate) {
+			op = candidate
+			break
+		}
+	}
+	if op == "" {
+		return "", "", 0, fmt.Errorf("condition %q: want one of > < >= <= == after %s", cond, field)
+	}
+
+	value, err = strconv.ParseFloat(strings.TrimSpace(rest[len(op):]), 64)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("condition %q: %w", cond, err)
+	}
+	return field, op, value, nil
+}
+
+// matches is false when b lacks the field, e.g. effort of the first block.
+func (f notificationFilter) matches(b block) bool {
+	v, ok := filterFields[f.field](b)
+	if !ok {
+		return false
+	}
+
+	switch f.op {
+	case ">":
+		return v > f.value
+	case "<":
+		return v < f.value
+	case ">=":
+		return v >= f.value
+	case "<=":
+		return v <= f.value
+	default:
+		return v == f.value
+	}
+}
+
+// passFilters reports whether b is announced: no suppress filter matches
+// it, and if there are send filters, one of them does.
+func passFilters(filters []notificationFilter, b block) bool {
+	hasSend, sendMatched := false, false
+	for _, f := range filters {
+		if f.suppress {
+			if f.matches(b) {
+				return false
+			}
+			continue
+		}
+		hasSend = true
+		sendMatched = sendMatched || f.matches(b)
+	}
+	return !hasSend || sendMatched
+}
@@ -0,0 +1,89 @@
+package main
+
+import "testing"
+
+func TestFilterOperators(t *testing.T) {
+	b := block{height: 100, effort: 1.5}
+
+	tests := []struct {
+		condition string
+		want      bool
+	}{
+		{"effort > 149", true},
+		{"effort > 150", false},
+		{"effort < 151", true},
+		{"effort < 150", false},
+		{"effort >= 150", true},
+		{"effort >= 151", false},
+		{"effort <= 150", true},
+		{"effort <= 149", false},
+		{"effort == 150", true},
+		{"effort == 149", false},
+		{"height>=100", true},
+		{"  height  <  100 ", false},
+		// The block has no reward, so no reward condition matches.
+		{"reward >= 0", false},
+	}
+
+	for _, tt := range tests {
+		f, err := parseFilter(filterConfig{Condition: tt.condition, Action: "suppress"})
+		if err != nil {
+			t.Errorf("%q: %s", tt.condition, err)
+			continue
+		}
+		if got := f.matches(b); got != tt.want {
+			t.Errorf("%q matches = %v, want %v", tt.condition, got, tt.want)
+		}
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []filterConfig{
+		{Condition: "effort > 150", Action: "drop"},
+		{Condition: "luck > 150", Action: "send"},
+		{Condition: "effort != 150", Action: "send"},
+		{Condition: "effort > lots", Action: "send"},
+		{Condition: "effort >", Action: "send"},
+		{Condition: "", Action: "send"},
+	}
+
+	for _, fc := range tests {
+		if _, err := parseFilter(fc); err == nil {
+			t.Errorf("parseFilter(%+v) accepted it", fc)
+		}
+	}
+}
+
+func TestPassFilters(t *testing.T) {
+	lucky := block{height: 1, effort: 0.5}
+	unlucky := block{height: 2, effort: 2}
+
+	filter := func(cond, action string) notificationFilter {
+		f, err := parseFilter(filterConfig{Condition: cond, Action: action})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+
+	tests := []struct {
+		name        string
+		filters     []notificationFilter
+		wantLucky   bool
+		wantUnlucky bool
+	}{
+		{"none", nil, true, true},
+		{"suppress unlucky", []notificationFilter{filter("effort > 150", "suppress")}, true, false},
+		{"send only lucky", []notificationFilter{filter("effort < 100", "send")}, true, false},
+		{"suppress wins over send", []notificationFilter{filter("height > 0", "send"), filter("effort < 100", "suppress")}, false, true},
+	}
+
+	for _, tt := range tests {
+		if got := passFilters(tt.filters, lucky); got != tt.wantLucky {
+			t.Errorf("%s: lucky block passes = %v, want %v", tt.name, got, tt.wantLucky)
+		}
+		if got := passFilters(tt.filters, unlucky); got != tt.wantUnlucky {
+			t.Errorf("%s: unlucky block passes = %v, want %v", tt.name, got, tt.wantUnlucky)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// newFixtureAPIServer serves the *.json files in dir as the pool blocks
+// API, one file per request in name order, starting over after the last.
+// It lets CI run the bot without reaching p2pool.io.
+func newFixtureAPIServer(dir string) (*httptest.Server, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no *.json fixtures in %s", dir)
+	}
+	sort.Strings(files)
+
+	var (
+		mu   sync.Mutex
+		next int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		file := files[next%len(files)]
+		next++
+		mu.Unlock()
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}))
+
+	return srv, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const fixturesDir = "testdata/fixtures"
+
+func TestFixtureAPIServer(t *testing.T) {
+	srv, err := newFixtureAPIServer(fixturesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	var tips []int
+	for i := 0; i < 6; i++ {
+		blocks, err := fetchRecentBlocks(srv.URL)
+		if err != nil {
+			t.Fatalf("request %d: %s", i+1, err)
+		}
+		tips = append(tips, blocks[0].height)
+	}
+	// One file per request in name order, starting over after the last.
+	want := []int{4500111, 4500111, 4500148, 4500185, 4500259, 4500111}
+	if !slices.Equal(tips, want) {
+		t.Errorf("tips = %v, want %v", tips, want)
+	}
+}
+
+func TestFixtureAPIServerEmptyDir(t *testing.T) {
+	if _, err := newFixtureAPIServer(t.TempDir()); err == nil {
+		t.Error("no error for a directory without fixtures")
+	}
+}
+
+// TestFixturesAnnounced runs the notifier against the fixtures as CI does
+// with -test-api-fixtures.
+func TestFixturesAnnounced(t *testing.T) {
+	srv, err := newFixtureAPIServer(fixturesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	tg := newFakeTelegram(t)
+	store := newTestStore(t)
+	store.Add(1)
+	pool := poolConfig{Name: defaultPoolName, URL: srv.URL}
+	n := newTestNotifier(t, config{Pools: []poolConfig{pool}}, store)
+	n.bot = tg.bot(t)
+
+	for i := 0; i < 5; i++ {
+		if err := n.tryNotifyIfNewBlock(context.Background(), pool); err != nil {
+			t.Fatalf("poll %d: %s", i+1, err)
+		}
+	}
+
+	// The first tip is only recorded and the second poll sees it again.
+	texts := tg.sentTexts()
+	wantHeights := []int{4500148, 4500185, 4500259}
+	if len(texts) != len(wantHeights) {
+		t.Fatalf("sent %d notifications, want %d: %q", len(texts), len(wantHeights), texts)
+	}
+	for i, height := range wantHeights {
+		if !strings.Contains(texts[i], strconv.Itoa(height)) {
+			t.Errorf("notification %d = %q, want block %d", i+1, texts[i], height)
+		}
+	}
+}
@@ -1,8 +1,16 @@
 module p2pool-tgbot
 
-go 1.20
+go 1.21
 
 require (
-	github.com/BurntSushi/toml v1.2.0 // indirect
-	github.com/go-telegram-bot-api/telegram-bot-api/v5 v5.5.1 // indirect
+	github.com/BurntSushi/toml v1.2.0
+	github.com/eclipse/paho.mqtt.golang v1.5.0
+	github.com/go-telegram-bot-api/telegram-bot-api/v5 v5.5.1
+	golang.org/x/sync v0.10.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/gorilla/websocket v1.5.3 // indirect
+	golang.org/x/net v0.27.0 // indirect
 )
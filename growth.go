@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// wasSubscribed tells whether id is a subscriber, so that a repeated /start
+// or /unsubscribe isn't taken for a new one. Errors count as not knowing.
+func wasSubscribed(store Storer, id int64) (bool, bool) {
+	ids, err := store.Subscribers()
+	if err != nil {
+		log.Printf("error: check subscription of %s: %s", chatRef(id), err.Error())
+		return false, false
+	}
+	return slices.Contains(ids, id), true
+}
+
+// weekGrowth is the subscription events of one week.
+type weekGrowth struct {
+	subscribed, unsubscribed int
+}
+
+func (w weekGrowth) net() int {
+	return w.subscribed - w.unsubscribed
+}
+
+// computeGrowth splits events into the last seven days before now and the
+// seven days before those.
+func computeGrowth(events []subscriptionEvent, now time.Time) (thisWeek, lastWeek weekGrowth) {
+	weekAgo := now.AddDate(0, 0, -7)
+	for _, e := range events {
+		week := &thisWeek
+		if e.OccurredAt.Before(weekAgo) {
+			week = &lastWeek
+		}
+		switch e.Type {
+		case eventSubscribe:
+			week.subscribed++
+		case eventUnsubscribe:
+			week.unsubscribed++
+		}
+	}
+	return thisWeek, lastWeek
+}
+
+// cmdGrowth implements /growth: subscribers gained this week against last
+// week.
+func (r *commandRouter) cmdGrowth(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	now := time.Now()
+	events, err := r.store.GetEvents(now.AddDate(0, 0, -14))
+	if err != nil {
+		log.Printf("error: get subscription events: %s", err.Error())
+		return reply(msg, "Не удалось получить историю подписок")
+	}
+
+	thisWeek, lastWeek := computeGrowth(events, now)
+	return reply(msg, fmt.Sprintf("За последние 7 дней: +%d −%d, итого %+d\nЗа 7 дней до этого: +%d −%d, итого %+d",
+		thisWeek.subscribed, thisWeek.unsubscribed, thisWeek.net(),
+		lastWeek.subscribed, lastWeek.unsubscribed, lastWeek.net()))
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+type healthResponse struct {
+	Status              string         `json:"status"`
+	LastHeights         map[string]int `json:"last_heights"`
+	StartedAt           *time.Time     `json:"started_at"`
+	LastSuccessfulFetch *time.Time     `json:"last_successful_fetch"`
+	LastBlockSeenAt     *time.Time     `json:"last_block_seen_at"`
+	LastFetchError      string         `json:"last_fetch_error,omitempty"`
+	// Pools has the same fields for every pool on its own.
+	Pools            map[string]poolFetchStatus `json:"pools"`
+	UpdateQueueDepth int                        `json:"update_queue_depth"`
+	DroppedUpdates   int64                      `json:"dropped_updates"`
+	// SubscriberCount is null if the store couldn't be read.
+	SubscriberCount *int `json:"subscriber_count"`
+	// PollIntervals is the effective polling interval of each pool in
+	// seconds, see AutoTuneInterval.
+	PollIntervals map[string]float64 `json:"poll_interval_seconds"`
+	// StoreReadOnly is set while the subscribers can't be written;
+	// notifications still go out, so the status is "degraded" but the
+	// response is 200.
+	StoreReadOnly bool `json:"store_read_only,omitempty"`
+}
+
+// poolFetchStatus is how polling one pool went, for /healthz and /debug.
+type poolFetchStatus struct {
+	LastSuccessfulFetch *time.Time `json:"last_successful_fetch"`
+	LastBlockSeenAt     *time.Time `json:"last_block_seen_at"`
+	LastFetchError      string     `json:"last_fetch_error,omitempty"`
+}
+
+func (f poolFetch) status() poolFetchStatus {
+	s := poolFetchStatus{
+		LastSuccessfulFetch: timeOrNil(f.lastSuccess),
+		LastBlockSeenAt:     timeOrNil(f.lastBlockSeenAt),
+	}
+	if f.lastError != nil {
+		s.LastFetchError = f.lastError.Error()
+	}
+	return s
+}
+
+// healthHandler reports 503 only when a pool API has not been reached for
+// longer than maxFetchAge. Block age is informational: a long round means the
+// pool is unlucky, not that the bot is broken.
+func healthHandler(n *Notifier, maxFetchAge time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := n.snapshot().healthResponse(maxFetchAge)
+		resp.SubscriberCount = subscriberCount(n.store)
+		resp.UpdateQueueDepth = n.updates.depth()
+		resp.DroppedUpdates = n.updates.droppedCount()
+		resp.PollIntervals = make(map[string]float64)
+		for name, d := range n.tuner.intervals() {
+			resp.PollIntervals[name] = d.Seconds()
+		}
+
+		resp.StoreReadOnly = n.storeReadOnly()
+		if resp.StoreReadOnly && resp.Status == "ok" {
+			resp.Status = "degraded"
+		}
+
+		code := http.StatusOK
+		if resp.Status == "unhealthy" {
+			code = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// httpMux routes every HTTP endpoint of the bot: /healthz, /metrics and,
+// if pushToken is set, /api/notify.
+func httpMux(n *Notifier, maxFetchAge time.Duration, pushToken string) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", healthHandler(n, maxFetchAge))
+	mux.Handle("/metrics", metricsHandler(n))
+	if pushToken != "" {
+		mux.Handle("/api/notify", pushHandler(n, pushToken))
+	}
+	return mux
+}
+
+// subscriberCount returns nil if the store can't be read.
+func subscriberCount(store Storer) *int {
+	count, err := store.Count()
+	if err != nil {
+		log.Printf("error: health: count subscribers: %s", err.Error())
+		return nil
+	}
+	return &count
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
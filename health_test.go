@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthzSubscriberCount(t *testing.T) {
+	tests := []struct {
+		name        string
+		subscribers int
+		brokenStore bool
+		want        *int
+	}{
+		{name: "none", subscribers: 0, want: intPtr(0)},
+		{name: "five", subscribers: 5, want: intPtr(5)},
+		{name: "unreadable store", brokenStore: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t)
+			if tt.brokenStore {
+				// A directory can't be read as the subscribers file.
+				store = newFileStore(t.TempDir())
+				t.Cleanup(func() { store.Close() })
+			}
+			for id := int64(1); id <= int64(tt.subscribers); id++ {
+				if err := store.Add(id); err != nil {
+					t.Fatal(err)
+				}
+			}
+			n := newTestNotifier(t, config{}, store)
+			srv := httptest.NewServer(httpMux(n, time.Minute, ""))
+			defer srv.Close()
+
+			res, err := http.Get(srv.URL + "/healthz")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			var health healthResponse
+			if err := json.NewDecoder(res.Body).Decode(&health); err != nil {
+				t.Fatal(err)
+			}
+
+			switch {
+			case tt.want == nil && health.SubscriberCount != nil:
+				t.Errorf("subscriber_count = %d, want null", *health.SubscriberCount)
+			case tt.want != nil && (health.SubscriberCount == nil || *health.SubscriberCount != *tt.want):
+				t.Errorf("subscriber_count = %v, want %d", health.SubscriberCount, *tt.want)
+			}
+		})
+	}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// newTestStore returns a file store in a fresh temporary directory.
+func newTestStore(t *testing.T) *fileStore {
+	t.Helper()
+	s := newFileStore(filepath.Join(t.TempDir(), "subscribers.txt"))
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// newTestNotifier returns a notifier without a bot and with an in-memory
+// state, for tests that don't talk to Telegram.
+func newTestNotifier(t *testing.T, conf config, store Storer) *Notifier {
+	t.Helper()
+	st, err := loadState("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newNotifier(nil, store, conf, &usageStats{state: st, disabled: true}, st, nil, nil)
+}
+
+// fakeTelegram is a Bot API server that accepts every call and records
+// the chats messages were sent to. Sends to the chats in fail get a 403,
+// to the ones in migrated a 400 naming the supergroup, to the ones in
+// throttled a 429. Every call about a chat in gone gets a 400 Chat not
+// found.
+type fakeTelegram struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	sent  []int64
+	texts []string
+	fail  map[int64]bool
+	// migrated maps group chats to the supergroups they became.
+	migrated map[int64]int64
+	// calls are the Bot API methods called, in order.
+	calls []string
+	// markups are the reply markups of editMessageReplyMarkup calls.
+	markups []string
+	// admins are the administrators of group chats.
+	admins map[int64][]int64
+	// throttled is how many more sends to a chat get a 429.
+	throttled map[int64]int
+	gone      map[int64]bool
+	// previews records for every sent message whether links were allowed
+	// to expand.
+	previews []bool
+}
+
+func newFakeTelegram(t *testing.T) *fakeTelegram {
+	t.Helper()
+	f := &fakeTelegram{fail: make(map[int64]bool), migrated: make(map[int64]int64), admins: make(map[int64][]int64), throttled: make(map[int64]int), gone: make(map[int64]bool)}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeTelegram) serve(w http.ResponseWriter, r *http.Request) {
+	method := path.Base(r.URL.Path)
+	chatID, _ := strconv.ParseInt(r.FormValue("chat_id"), 10, 64)
+
+	f.mu.Lock()
+	f.calls = append(f.calls, method)
+	if method == "editMessageReplyMarkup" {
+		f.markups = append(f.markups, r.FormValue("reply_markup"))
+	}
+	failed := f.fail[chatID]
+	gone := f.gone[chatID]
+	migratedTo := f.migrated[chatID]
+	admins := f.admins[chatID]
+	throttled := method == "sendMessage" && f.throttled[chatID] > 0
+	if throttled {
+		f.throttled[chatID]--
+	}
+	if method == "sendMessage" && !failed && !gone && migratedTo == 0 && !throttled {
+		f.sent = append(f.sent, chatID)
+		f.texts = append(f.texts, r.FormValue("text"))
+		f.previews = append(f.previews, r.FormValue("disable_web_page_preview") != "true")
+	}
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case method == "getMe":
+		fmt.Fprint(w, `{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"test","username":"test_bot"}}`)
+	case gone:
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`)
+	case throttled:
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 1","parameters":{"retry_after":1}}`)
+	case failed:
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"ok":false,"error_code":403,"description":"Forbidden: bot was blocked by the user"}`)
+	case migratedTo != 0:
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"ok":false,"error_code":400,"description":"Bad Request: group chat was upgraded to a supergroup chat","parameters":{"migrate_to_chat_id":%d}}`, migratedTo)
+	case method == "getChatAdministrators":
+		members := make([]string, len(admins))
+		for i, id := range admins {
+			members[i] = fmt.Sprintf(`{"status":"administrator","user":{"id":%d}}`, id)
+		}
+		fmt.Fprintf(w, `{"ok":true,"result":[%s]}`, strings.Join(members, ","))
+	case method == "getChat":
+		fmt.Fprintf(w, `{"ok":true,"result":{"id":%d,"type":"private"}}`, chatID)
+	case method == "sendMessage":
+		fmt.Fprintf(w, `{"ok":true,"result":{"message_id":%d,"date":0,"chat":{"id":%d,"type":"private"}}}`, time.Now().UnixNano()%1000000, chatID)
+	default:
+		fmt.Fprint(w, `{"ok":true,"result":true}`)
+	}
+}
+
+// bot returns a client of f.
+func (f *fakeTelegram) bot(t *testing.T) *tgbotapi.BotAPI {
+	t.Helper()
+	bot, err := tgbotapi.NewBotAPIWithAPIEndpoint("token", f.URL+"/bot%s/%s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bot
+}
+
+func (f *fakeTelegram) sentTo() []int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]int64(nil), f.sent...)
+}
+
+// sentTexts returns the texts of the messages sent, in order.
+func (f *fakeTelegram) sentTexts() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.texts...)
+}
+
+// sentPreviews reports for every message sent, in order, whether its
+// links may expand.
+func (f *fakeTelegram) sentPreviews() []bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]bool(nil), f.previews...)
+}
+
+// waitForTexts waits for count messages to be sent, for sends made in
+// the background, and returns their texts.
+func (f *fakeTelegram) waitForTexts(t *testing.T, count int) []string {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		texts := f.sentTexts()
+		if len(texts) >= count {
+			return texts
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d messages sent, want %d: %q", len(texts), count, texts)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func (f *fakeTelegram) editedMarkups() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.markups...)
+}
+
+func (f *fakeTelegram) setGroupAdmins(chatID int64, ids ...int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.admins[chatID] = ids
+}
+
+// throttle makes the next times sends to id get a 429.
+func (f *fakeTelegram) throttle(id int64, times int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.throttled[id] = times
+}
+
+func (f *fakeTelegram) failFor(id int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fail[id] = true
+}
+
+// remove makes every call about the chats ids fail as if they were deleted.
+func (f *fakeTelegram) remove(ids ...int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, id := range ids {
+		f.gone[id] = true
+	}
+}
+
+func (f *fakeTelegram) migrate(from, to int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.migrated[from] = to
+}
+
+// command returns a message sending text from user id in a private chat.
+func command(id int64, text string) *tgbotapi.Message {
+	name, _, _ := strings.Cut(strings.TrimPrefix(text, "/"), " ")
+	return &tgbotapi.Message{
+		MessageID: 1,
+		From:      &tgbotapi.User{ID: id},
+		Chat:      &tgbotapi.Chat{ID: id, Type: "private"},
+		Text:      text,
+		Entities:  []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(name) + 1}},
+	}
+}
+
+// groupCommand is command sent by userID in the group chatID.
+func groupCommand(chatID, userID int64, text string) *tgbotapi.Message {
+	msg := command(userID, text)
+	msg.Chat = &tgbotapi.Chat{ID: chatID, Type: "supergroup"}
+	return msg
+}
+
+// newPoolAPI serves body as the pool blocks API, or fails with status if it
+// isn't 200.
+func newPoolAPI(t *testing.T, status int, body string) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+// blocksJSON is a pool API response with the blocks at heights, newest
+// first, found a minute apart.
+func blocksJSON(heights ...int) string {
+	parts := make([]string, len(heights))
+	for i, h := range heights {
+		parts[i] = fmt.Sprintf(`{"height":%d,"hash":"%064x","difficulty":1000,"totalHashes":%d,"ts":%d}`,
+			h, h, 1000*(len(heights)-i), int64(1700000000000)-int64(i)*60000)
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
@@ -0,0 +1,82 @@
+package main
+
+import "sync"
+
+const historySize = 200
+
+// ringBuffer keeps the most recent blocks of a pool, every one the pool
+// found rather than only the tips polled, so that stats such as /luck
+// don't have to re-fetch them. It is safe for concurrent use.
+type ringBuffer struct {
+	mu         sync.Mutex
+	data       []block
+	head, tail int
+	size       int
+}
+
+func newRingBuffer(capacity int) *ringBuffer {
+	return &ringBuffer{data: make([]block, capacity)}
+}
+
+// Push adds b, overwriting the oldest block once the buffer is full.
+func (r *ringBuffer) Push(b block) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.data[r.tail] = b
+	r.tail = (r.tail + 1) % len(r.data)
+	if r.size == len(r.data) {
+		r.head = (r.head + 1) % len(r.data)
+	} else {
+		r.size++
+	}
+}
+
+// Newest returns the last block pushed, false if there is none.
+func (r *ringBuffer) Newest() (block, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.size == 0 {
+		return block{}, false
+	}
+	return r.data[(r.tail-1+len(r.data))%len(r.data)], true
+}
+
+// All returns a copy of the buffered blocks, oldest first.
+func (r *ringBuffer) All() []block {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	blocks := make([]block, r.size)
+	for i := range blocks {
+		blocks[i] = r.data[(r.head+i)%len(r.data)]
+	}
+	return blocks
+}
+
+// replace swaps the contents of r for those of other, which must not be in
+// use elsewhere.
+func (r *ringBuffer) replace(other *ringBuffer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.data, r.head, r.tail, r.size = other.data, other.head, other.tail, other.size
+}
+
+// recordHistory adds the blocks of pool newer than its history. blocks are
+// newest first, as the pool API returns them.
+func (n *Notifier) recordHistory(pool string, blocks []block) {
+	h := n.history[pool]
+	newest, _ := h.Newest()
+	for i := len(blocks) - 1; i >= 0; i-- {
+		if blocks[i].height > newest.height {
+			h.Push(blocks[i])
+		}
+	}
+}
+
+// recentBlocks returns the history of pool newest first, like the pool API.
+func (n *Notifier) recentBlocks(pool string) []block {
+	return newestFirst(n.history[pool].All())
+}
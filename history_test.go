@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRingBufferWrapAround(t *testing.T) {
+	tests := []struct {
+		name   string
+		pushed int
+		want   []int
+	}{
+		{"empty", 0, []int{}},
+		{"partly full", 2, []int{1, 2}},
+		{"exactly full", 3, []int{1, 2, 3}},
+		{"wrapped once", 4, []int{2, 3, 4}},
+		{"wrapped twice", 7, []int{5, 6, 7}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRingBuffer(3)
+			for height := 1; height <= tt.pushed; height++ {
+				r.Push(block{height: height})
+			}
+
+			if got := heights(r.All()); !slices.Equal(got, tt.want) {
+				t.Errorf("All = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRingBufferConcurrent(t *testing.T) {
+	r := newRingBuffer(historySize)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				r.Push(block{height: i})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				if blocks := r.All(); len(blocks) > historySize {
+					t.Errorf("All returned %d blocks, more than the capacity", len(blocks))
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := len(r.All()); got != historySize {
+		t.Errorf("size = %d, want %d", got, historySize)
+	}
+}
+
+func TestRingBufferReplace(t *testing.T) {
+	r := newRingBuffer(3)
+	r.Push(block{height: 1})
+	other := newRingBuffer(3)
+	other.Push(block{height: 7})
+	other.Push(block{height: 8})
+
+	r.replace(other)
+
+	if got := heights(r.All()); !slices.Equal(got, []int{7, 8}) {
+		t.Errorf("All = %v, want [7 8]", got)
+	}
+}
+
+func TestHistoryHasEveryBlock(t *testing.T) {
+	var body atomic.Value
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, body.Load())
+	}))
+	defer api.Close()
+
+	pool := poolConfig{Name: defaultPoolName, URL: api.URL}
+	n := newTestNotifier(t, config{Pools: []poolConfig{pool}}, newTestStore(t))
+
+	// Each poll sees the API's recent blocks, newest first.
+	polls := []struct {
+		name string
+		api  []int
+		want []int
+	}{
+		{"first poll", []int{102, 101, 100}, []int{100, 101, 102}},
+		{"nothing new", []int{102, 101, 100}, []int{100, 101, 102}},
+		{"two found between polls", []int{104, 103, 102, 101}, []int{100, 101, 102, 103, 104}},
+		{"API lagging", []int{103, 102}, []int{100, 101, 102, 103, 104}},
+	}
+
+	for _, p := range polls {
+		body.Store(blocksJSON(p.api...))
+		n.tryNotifyIfNewBlock(context.Background(), pool)
+
+		if got := heights(n.history[pool.Name].All()); !slices.Equal(got, p.want) {
+			t.Errorf("%s: history = %v, want %v", p.name, got, p.want)
+		}
+	}
+}
+
+func TestHistoryFilledAfterRestart(t *testing.T) {
+	pool := poolConfig{Name: defaultPoolName, URL: newPoolAPI(t, http.StatusOK, blocksJSON(202, 201, 200))}
+	n := newTestNotifier(t, config{Pools: []poolConfig{pool}}, newTestStore(t))
+	// The saved last block is the tip, so the poll finds nothing new.
+	n.lastBlocks.setLastBlock(pool.Name, block{height: 202})
+
+	if err := n.tryNotifyIfNewBlock(context.Background(), pool); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := heights(n.recentBlocks(pool.Name)); !slices.Equal(got, []int{202, 201, 200}) {
+		t.Errorf("recent blocks = %v, want [202 201 200]", got)
+	}
+}
+
+func heights(blocks []block) []int {
+	hs := make([]int, len(blocks))
+	for i, b := range blocks {
+		hs[i] = b.height
+	}
+	return hs
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	httpReadTimeout = 10 * time.Second
+	// httpWriteTimeout leaves room for /api/notify, which answers once
+	// every subscriber was sent the text.
+	httpWriteTimeout    = 2 * time.Minute
+	httpShutdownTimeout = 10 * time.Second
+)
+
+// serveHTTP serves handler on addr until ctx is done, then lets in-flight
+// requests finish for up to httpShutdownTimeout before closing them.
+func serveHTTP(ctx context.Context, addr string, handler http.Handler) error {
+	srv := &http.Server{
+		Addr:         addr,
+		Handler:      logRequests(recoverPanics(handler)),
+		ReadTimeout:  httpReadTimeout,
+		WriteTimeout: httpWriteTimeout,
+	}
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+		defer cancel()
+
+		err := srv.Shutdown(shutdownCtx)
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Printf("warning: http: requests still running after %s, closing them", httpShutdownTimeout)
+			err = srv.Close()
+		}
+		shutdownErr <- err
+	}()
+
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return <-shutdownErr
+}
+
+// recoverPanics turns a panicking handler into a 500 instead of a dropped
+// connection.
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if p := recover(); p != nil {
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+				log.Printf("error: http: panic serving %s %s: %v", r.Method, r.URL.Path, p)
+				http.Error(w, "internal error", http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		slog.Debug("http request", "method", r.Method, "path", r.URL.Path, "remote", r.RemoteAddr, "duration", time.Since(start))
+	})
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// importAnnounceInterval spaces out the messages of import --announce to
+// stay below the Telegram rate limits.
+const importAnnounceInterval = 100 * time.Millisecond
+
+// importEntry is one chat ID candidate and where it came from, for the
+// report.
+type importEntry struct {
+	where string
+	raw   string
+}
+
+// parseImport reads chat IDs from a JSON array, a CSV file with the IDs in
+// the chat_id or id column (the first column without a header) or a file
+// with one ID per line, telling them apart by their contents.
+func parseImport(data []byte) ([]importEntry, string, error) {
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		entries, err := parseImportJSON(trimmed)
+		return entries, "json", err
+	case bytes.ContainsAny(trimmed, ",;"):
+		entries, err := parseImportCSV(trimmed)
+		return entries, "csv", err
+	default:
+		return parseImportLines(trimmed), "lines", nil
+	}
+}
+
+func parseImportJSON(data []byte) ([]importEntry, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, err
+	}
+
+	entries := make([]importEntry, 0, len(items))
+	for i, item := range items {
+		raw := string(item)
+		var s string
+		if json.Unmarshal(item, &s) == nil {
+			raw = s
+		}
+		entries = append(entries, importEntry{where: fmt.Sprintf("item %d", i+1), raw: raw})
+	}
+	return entries, nil
+}
+
+func parseImportCSV(data []byte) ([]importEntry, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	if bytes.Count(data, []byte(";")) > bytes.Count(data, []byte(",")) {
+		r.Comma = ';'
+	}
+	r.FieldsPerRecord = -1
+
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	column, first := 0, 0
+	if _, err := strconv.ParseInt(strings.TrimSpace(records[0][0]), 10, 64); err != nil {
+		first = 1
+		for i, name := range records[0] {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "chat_id" || name == "id" {
+				column = i
+				break
+			}
+		}
+	}
+
+	entries := make([]importEntry, 0, len(records)-first)
+	for i, record := range records[first:] {
+		raw := ""
+		if column < len(record) {
+			raw = record[column]
+		}
+		entries = append(entries, importEntry{where: fmt.Sprintf("line %d", i+first+1), raw: raw})
+	}
+	return entries, nil
+}
+
+func parseImportLines(data []byte) []importEntry {
+	var entries []importEntry
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, importEntry{where: fmt.Sprintf("line %d", i+1), raw: line})
+	}
+	return entries
+}
+
+// runImport implements the import subcommand: it adds the chat IDs of a
+// file to the configured store and reports what happened to each.
+func runImport(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.SetOutput(out)
+	configPath := flags.String("config", defaultConfigPath, "path to the config file")
+	announce := flags.Bool("announce", false, "tell every imported chat it was moved to this bot")
+	archive := flags.Bool("archive", false, "rename the file to <file>.imported-<unix time> once every entry is in the store")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(out, "usage: import [-config path] [-announce] [-archive] <file>")
+		return 2
+	}
+
+	conf, err := readConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return 1
+	}
+	if problems := conf.validate(); len(problems) > 0 {
+		fmt.Fprintln(out, errors.Join(problems...))
+		return 1
+	}
+	if *archive && backendName(conf) == "file" && sameFile(flags.Arg(0), conf.SubscribersFile) {
+		fmt.Fprintln(out, "refusing to archive the subscribers file of the configured store")
+		return 1
+	}
+
+	data, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return 1
+	}
+	entries, format, err := parseImport(data)
+	if err != nil {
+		fmt.Fprintf(out, "%s: %s\n", flags.Arg(0), err)
+		return 1
+	}
+
+	store, err := openStore(conf)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return 1
+	}
+	if closer, ok := store.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	existing, err := store.Subscribers()
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return 1
+	}
+	known := make(map[int64]bool, len(existing))
+	for _, id := range existing {
+		known[id] = true
+	}
+
+	fmt.Fprintf(out, "reading %s as %s\n", flags.Arg(0), format)
+
+	var added []int64
+	var duplicates, invalid, failed int
+	for _, e := range entries {
+		id, err := strconv.ParseInt(strings.TrimSpace(e.raw), 10, 64)
+		if err == nil {
+			err = validateChatID(id)
+		}
+		switch {
+		case err != nil:
+			fmt.Fprintf(out, "%s: %q invalid\n", e.where, e.raw)
+			invalid++
+		case known[id]:
+			fmt.Fprintf(out, "%s: %d duplicate\n", e.where, id)
+			duplicates++
+		default:
+			if err := store.Add(id); err != nil {
+				fmt.Fprintf(out, "%s: %d failed: %s\n", e.where, id, err)
+				failed++
+				continue
+			}
+			fmt.Fprintf(out, "%s: %d added\n", e.where, id)
+			known[id] = true
+			added = append(added, id)
+		}
+	}
+
+	fmt.Fprintf(out, "added %d, duplicate %d, invalid %d, failed %d\n", len(added), duplicates, invalid, failed)
+
+	audit := newAuditLog(auditPath(conf))
+	audit.record(0, "import", map[string]string{"file": flags.Arg(0), "added": strconv.Itoa(len(added))})
+	audit.close()
+
+	if *announce && len(added) > 0 {
+		if err := announceImport(conf, added, out); err != nil {
+			fmt.Fprintln(out, err)
+			return 1
+		}
+	}
+
+	if failed > 0 {
+		return 1
+	}
+
+	if *archive {
+		archived := fmt.Sprintf("%s.imported-%d", flags.Arg(0), time.Now().Unix())
+		if err := os.Rename(flags.Arg(0), archived); err != nil {
+			fmt.Fprintln(out, err)
+			return 1
+		}
+		fmt.Fprintf(out, "archived %s as %s\n", flags.Arg(0), archived)
+	}
+	return 0
+}
+
+// sameFile reports whether a and b name the same existing file.
+func sameFile(a, b string) bool {
+	fa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	fb, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(fa, fb)
+}
+
+func announceImport(conf config, ids []int64, out io.Writer) error {
+	apiKey, err := resolveAPIKey(conf)
+	if err != nil {
+		return err
+	}
+	bot, err := newBot(conf, apiKey)
+	if err != nil {
+		return err
+	}
+
+	throttle := time.NewTicker(importAnnounceInterval)
+	defer throttle.Stop()
+
+	sent := 0
+	for _, id := range ids {
+		<-throttle.C
+		msg := tgbotapi.NewMessage(id, "Этот чат перенесён в бота уведомлений о блоках p2pool. Отписаться: /unsubscribe")
+		if _, err := bot.Send(msg); err != nil {
+			fmt.Fprintf(out, "announce to %d failed: %s\n", id, err)
+			continue
+		}
+		sent++
+	}
+
+	fmt.Fprintf(out, "announced to %d of %d imported chats\n", sent, len(ids))
+	return nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseImport(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		wantFormat string
+		want       []string
+	}{
+		{"lines", "1\n# comment\n\n-1001\n", "lines", []string{"1", "-1001"}},
+		{"json", `[1, -1001]`, "json", []string{"1", "-1001"}},
+		{"csv with header", "name,chat_id\nbob,1\ngroup,-1001\n", "csv", []string{"1", "-1001"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, format, err := parseImport([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range entries {
+				got = append(got, e.raw)
+			}
+			if format != tt.wantFormat || !slices.Equal(got, tt.want) {
+				t.Errorf("parseImport = %v as %s, want %v as %s", got, format, tt.want, tt.wantFormat)
+			}
+		})
+	}
+}
+
+// writeImportConfig writes a config using a file store in dir with the
+// given subscribers.
+func writeImportConfig(t *testing.T, dir string, subscribers ...int64) (configPath, subscribersPath string) {
+	t.Helper()
+	subscribersPath = filepath.Join(dir, "subscribers.txt")
+	var lines strings.Builder
+	for _, id := range subscribers {
+		fmt.Fprintf(&lines, "%d\n", id)
+	}
+	if err := os.WriteFile(subscribersPath, []byte(lines.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configPath = filepath.Join(dir, "config.toml")
+	conf := fmt.Sprintf("APIKey = \"key\"\nNotifyDuration = \"30s\"\nSubscribersFile = %q\n", subscribersPath)
+	if err := os.WriteFile(configPath, []byte(conf), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return configPath, subscribersPath
+}
+
+func TestImportMergesLegacyFile(t *testing.T) {
+	dir := t.TempDir()
+	configPath, subscribersPath := writeImportConfig(t, dir, 1, 2)
+	legacy := filepath.Join(dir, "legacy.txt")
+	if err := os.WriteFile(legacy, []byte("2\n3\n3\nnot-an-id\n4\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Running it again changes nothing.
+	for run := 1; run <= 2; run++ {
+		var out strings.Builder
+		if code := runImport([]string{"-config", configPath, legacy}, &out); code != 0 {
+			t.Fatalf("run %d: exit code %d\n%s", run, code, out.String())
+		}
+		wantSummary := "added 2, duplicate 2, invalid 1, failed 0"
+		if run == 2 {
+			wantSummary = "added 0, duplicate 4, invalid 1, failed 0"
+		}
+		if !strings.Contains(out.String(), wantSummary) {
+			t.Errorf("run %d: output lacks %q:\n%s", run, wantSummary, out.String())
+		}
+
+		s := newFileStore(subscribersPath)
+		ids, err := s.Subscribers()
+		s.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		slices.Sort(ids)
+		if !slices.Equal(ids, []int64{1, 2, 3, 4}) {
+			t.Errorf("run %d: subscribers = %v, want [1 2 3 4]", run, ids)
+		}
+	}
+
+	// The imported chats count as new subscribers in /growth.
+	s := newFileStore(subscribersPath)
+	defer s.Close()
+	events, err := s.GetEvents(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Errorf("%d subscription events, want 2", len(events))
+	}
+}
+
+func TestImportArchive(t *testing.T) {
+	tests := []struct {
+		name         string
+		ownFile      bool
+		wantCode     int
+		wantArchived bool
+	}{
+		{name: "legacy file", wantArchived: true},
+		{name: "own subscribers file", ownFile: true, wantCode: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			configPath, subscribersPath := writeImportConfig(t, dir, 1)
+			file := filepath.Join(dir, "legacy.txt")
+			if err := os.WriteFile(file, []byte("5\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.ownFile {
+				file = subscribersPath
+			}
+
+			var out strings.Builder
+			if code := runImport([]string{"-config", configPath, "-archive", file}, &out); code != tt.wantCode {
+				t.Fatalf("exit code = %d, want %d\n%s", code, tt.wantCode, out.String())
+			}
+
+			_, err := os.Stat(file)
+			archived, _ := filepath.Glob(file + ".imported-*")
+			if gone := os.IsNotExist(err) && len(archived) == 1; gone != tt.wantArchived {
+				t.Errorf("archived = %v, want %v", gone, tt.wantArchived)
+			}
+		})
+	}
+}
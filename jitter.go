@@ -0,0 +1,28 @@
+package main
+
+import (
+	"crypto/rand"
+	"log"
+	"math/big"
+	"time"
+)
+
+// randomDuration returns a random duration in [0, max). crypto/rand keeps
+// instances restarted together from drawing the same sequence.
+func randomDuration(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		log.Printf("error: draw jitter: %s", err.Error())
+		return 0
+	}
+	return time.Duration(n.Int64())
+}
+
+// jittered returns d moved randomly by up to 10% either way.
+func jittered(d time.Duration) time.Duration {
+	spread := d / 10
+	return d - spread + randomDuration(2*spread+1)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRandomDuration(t *testing.T) {
+	const max = time.Hour
+
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 100; i++ {
+		d := randomDuration(max)
+		if d < 0 || d >= max {
+			t.Fatalf("randomDuration(%s) = %s, out of range", max, d)
+		}
+		seen[d] = true
+	}
+	if len(seen) < 95 {
+		t.Errorf("only %d distinct draws out of 100", len(seen))
+	}
+
+	for _, max := range []time.Duration{0, -time.Second} {
+		if d := randomDuration(max); d != 0 {
+			t.Errorf("randomDuration(%s) = %s, want 0", max, d)
+		}
+	}
+}
+
+func TestJittered(t *testing.T) {
+	const d = 30 * time.Second
+	for i := 0; i < 100; i++ {
+		if got := jittered(d); got < 27*time.Second || got > 33*time.Second {
+			t.Fatalf("jittered(%s) = %s, more than 10%% off", d, got)
+		}
+	}
+}
+
+// TestStartupJitter starts two bots at once. With jitter their first polls
+// are spread over NotifyDuration, an hour here, so neither polls right away.
+func TestStartupJitter(t *testing.T) {
+	tests := []struct {
+		name          string
+		disableJitter bool
+		wantPolls     int32
+	}{
+		{"jitter", false, 0},
+		{"no jitter", true, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var polls atomic.Int32
+			api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				polls.Add(1)
+				w.Write([]byte(blocksJSON(100)))
+			}))
+			defer api.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+			defer cancel()
+			pool := poolConfig{Name: defaultPoolName, URL: api.URL}
+			conf := config{Pools: []poolConfig{pool}, NotifyDuration: "1h", DisableJitter: tt.disableJitter}
+
+			var wg sync.WaitGroup
+			for i := 0; i < 2; i++ {
+				n := newTestNotifier(t, conf, newTestStore(t))
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					n.poolWorker(ctx, pool)
+				}()
+			}
+			time.Sleep(200 * time.Millisecond)
+
+			if got := polls.Load(); got != tt.wantPolls {
+				t.Errorf("%d polls right after startup, want %d", got, tt.wantPolls)
+			}
+			cancel()
+			if tt.disableJitter {
+				// Without jitter the workers sleep out the interval; don't
+				// wait for them.
+				return
+			}
+			wg.Wait()
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const latencySamples = 50
+
+// deliveryLatency is how long after a block was found its notification
+// reached the first and the last subscriber.
+type deliveryLatency struct {
+	first, last time.Duration
+}
+
+// latencyTracker keeps the delivery latency of the most recent
+// notifications. It is safe for concurrent use.
+type latencyTracker struct {
+	mu      sync.Mutex
+	samples []deliveryLatency
+}
+
+func (t *latencyTracker) record(l deliveryLatency) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.samples = append(t.samples, l)
+	if len(t.samples) > latencySamples {
+		t.samples = t.samples[len(t.samples)-latencySamples:]
+	}
+}
+
+type latencySummary struct {
+	samples            int
+	firstP50, firstP95 time.Duration
+	lastP50, lastP95   time.Duration
+}
+
+func (t *latencyTracker) summary() latencySummary {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	first := make([]time.Duration, len(t.samples))
+	last := make([]time.Duration, len(t.samples))
+	for i, l := range t.samples {
+		first[i], last[i] = l.first, l.last
+	}
+
+	return latencySummary{
+		samples:  len(t.samples),
+		firstP50: percentile(first, 50),
+		firstP95: percentile(first, 95),
+		lastP50:  percentile(last, 50),
+		lastP95:  percentile(last, 95),
+	}
+}
+
+func (s latencySummary) String() string {
+	if s.samples == 0 {
+		return "Задержка уведомлений: пока нет данных"
+	}
+	return fmt.Sprintf("Задержка уведомлений за последние %d блоков (от времени блока):\n"+
+		"первому подписчику: p50 %s, p95 %s\nпоследнему подписчику: p50 %s, p95 %s",
+		s.samples, s.firstP50.Round(time.Second), s.firstP95.Round(time.Second),
+		s.lastP50.Round(time.Second), s.lastP95.Round(time.Second))
+}
+
+// percentile returns the nearest-rank p-th percentile of d, sorting it.
+func percentile(d []time.Duration, p int) time.Duration {
+	if len(d) == 0 {
+		return 0
+	}
+	sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+
+	rank := (p*len(d) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return d[rank-1]
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// lintWarning is a config value that is allowed but likely a mistake.
+type lintWarning struct {
+	Field   string
+	Message string
+}
+
+func (w lintWarning) String() string {
+	return fmt.Sprintf("%s: %s", w.Field, w.Message)
+}
+
+const (
+	minSensibleNotifyDuration = 30 * time.Second
+	exampleAPIKey             = "YOUR_TG_API_KEY"
+)
+
+// botTokenPattern is the shape of the tokens BotFather hands out,
+// "<bot id>:<35 characters>".
+var botTokenPattern = regexp.MustCompile(`^[0-9]+:[A-Za-z0-9_-]{35}$`)
+
+// lint returns the soft problems of c. Unlike validate, none of them stop
+// the bot. It expects c to pass validate.
+func lint(c config) []lintWarning {
+	var warnings []lintWarning
+
+	if d, err := time.ParseDuration(c.NotifyDuration); err == nil && d < minSensibleNotifyDuration {
+		warnings = append(warnings, lintWarning{"NotifyDuration", fmt.Sprintf("polling every %s is aggressive, the pool API may rate limit the bot", d)})
+	}
+
+	if key, err := resolveAPIKey(c); err == nil {
+		switch {
+		case key == exampleAPIKey:
+			warnings = append(warnings, lintWarning{"APIKey", "still the placeholder from config.example.toml"})
+		case !botTokenPattern.MatchString(key):
+			warnings = append(warnings, lintWarning{"APIKey", "doesn't look like a token from BotFather"})
+		}
+	}
+
+	if strings.ContainsAny(c.SubscribersFile, " \t") {
+		warnings = append(warnings, lintWarning{"SubscribersFile", "path contains spaces, which trips up some tools and service managers"})
+	}
+
+	if c.MessageTemplate != "" && !strings.Contains(c.MessageTemplate, ".Height") {
+		warnings = append(warnings, lintWarning{"MessageTemplate", "doesn't show the block height"})
+	}
+
+	return warnings
+}
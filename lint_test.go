@@ -0,0 +1,44 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	const token = "123456789:AAbbCCddEEffGGhhIIjjKKllMMnnOOppQQr"
+	good := config{ApiKey: token, NotifyDuration: "30s", SubscribersFile: "./subscribers.txt"}
+
+	tests := []struct {
+		name   string
+		change func(c *config)
+		want   []string
+	}{
+		{"clean", func(c *config) {}, nil},
+		{"fast polling", func(c *config) { c.NotifyDuration = "5s" }, []string{"NotifyDuration"}},
+		{"placeholder key", func(c *config) { c.ApiKey = exampleAPIKey }, []string{"APIKey"}},
+		{"malformed key", func(c *config) { c.ApiKey = "not-a-token" }, []string{"APIKey"}},
+		{"spaces in path", func(c *config) { c.SubscribersFile = "./my subscribers.txt" }, []string{"SubscribersFile"}},
+		{"template without height", func(c *config) { c.MessageTemplate = "Block on {{.Pool}}" }, []string{"MessageTemplate"}},
+		{"template with height", func(c *config) { c.MessageTemplate = "Block {{.Height}}" }, nil},
+		{"several", func(c *config) {
+			c.NotifyDuration = "1s"
+			c.SubscribersFile = "a b"
+		}, []string{"NotifyDuration", "SubscribersFile"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := good
+			tt.change(&c)
+
+			var fields []string
+			for _, w := range lint(c) {
+				fields = append(fields, w.Field)
+			}
+			if !slices.Equal(fields, tt.want) {
+				t.Errorf("warnings for %v, want %v", fields, tt.want)
+			}
+		})
+	}
+}
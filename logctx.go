@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync/atomic"
+)
+
+// newLogHandler logs at level and up. At debug level every line also
+// carries the file and line that logged it, which costs a runtime.Callers
+// per line and so is off otherwise.
+func newLogHandler(w io.Writer, level slog.Level) slog.Handler {
+	return slog.NewTextHandler(w, &slog.HandlerOptions{
+		Level:     level,
+		AddSource: level <= slog.LevelDebug,
+	})
+}
+
+type loggerKey struct{}
+
+var pollCounter atomic.Uint64
+
+// withPollLogger returns a context whose logger tags every line with a new
+// request ID, so all log lines of one poll cycle can be told apart.
+func withPollLogger(ctx context.Context, pool string) context.Context {
+	logger := slog.With("request_id", pollCounter.Add(1), "pool", pool)
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// logger returns the logger carried by ctx, or the default one.
+func logger(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
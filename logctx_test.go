@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLogHandlerSource(t *testing.T) {
+	tests := []struct {
+		level      slog.Level
+		wantSource bool
+	}{
+		{slog.LevelDebug, true},
+		{slog.LevelInfo, false},
+		{slog.LevelWarn, false},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		slog.New(newLogHandler(&buf, tt.level)).Warn("something happened")
+
+		if got := strings.Contains(buf.String(), "source=") && strings.Contains(buf.String(), "logctx_test.go:"); got != tt.wantSource {
+			t.Errorf("%s: source in %q = %v, want %v", tt.level, buf.String(), got, tt.wantSource)
+		}
+	}
+}
+
+func TestLogHandlerLevel(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(newLogHandler(&buf, slog.LevelInfo))
+	l.Debug("hidden")
+	l.Info("shown")
+
+	if strings.Contains(buf.String(), "hidden") || !strings.Contains(buf.String(), "shown") {
+		t.Errorf("info level logged %q", buf.String())
+	}
+}
+
+// parseLogLine splits a line of the slog text handler into its attributes.
+func parseLogLine(line string) map[string]string {
+	attrs := make(map[string]string)
+	for line != "" {
+		key, rest, ok := strings.Cut(line, "=")
+		if !ok {
+			break
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				break
+			}
+			value, _ = strconv.Unquote(quoted)
+			rest = rest[len(quoted):]
+		} else {
+			value, rest, _ = strings.Cut(rest, " ")
+		}
+		attrs[key] = value
+		line = strings.TrimLeft(rest, " ")
+	}
+	return attrs
+}
+
+// logRecords parses the slog lines in logs, skipping any other output.
+func logRecords(logs *bytes.Buffer) []map[string]string {
+	var records []map[string]string
+	for _, line := range strings.Split(logs.String(), "\n") {
+		if attrs := parseLogLine(line); attrs["msg"] != "" {
+			records = append(records, attrs)
+		}
+	}
+	return records
+}
+
+func TestParseLogLine(t *testing.T) {
+	var buf bytes.Buffer
+	slog.New(newLogHandler(&buf, slog.LevelInfo)).Info("send failed", "chat", "c1", "err", `Forbidden: "blocked" by user`, "n", 3)
+
+	got := parseLogLine(strings.TrimSpace(buf.String()))
+	want := map[string]string{"level": "INFO", "msg": "send failed", "chat": "c1", "err": `Forbidden: "blocked" by user`, "n": "3"}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%s = %q, want %q in %q", key, got[key], value, buf.String())
+		}
+	}
+}
+
+// TestBroadcastCorrelationID checks that the detection, every send and the
+// completion of one broadcast can be told apart from other log lines.
+func TestBroadcastCorrelationID(t *testing.T) {
+	const reachable, blocked = 1, 2
+
+	logs := captureLogs(t)
+	tg := newFakeTelegram(t)
+	tg.failFor(blocked)
+	store := newTestStore(t)
+	store.Add(reachable)
+	store.Add(blocked)
+	pool := poolConfig{Name: defaultPoolName, URL: newPoolAPI(t, 200, blocksJSON(100))}
+	skipFirst := false
+	n := newTestNotifier(t, config{Pools: []poolConfig{pool}, SkipFirstBlockOnStartup: &skipFirst}, store)
+	n.bot = tg.bot(t)
+
+	if err := n.tryNotifyIfNewBlock(withPollLogger(context.Background(), pool.Name), pool); err != nil {
+		t.Fatal(err)
+	}
+
+	var requestID, broadcastID string
+	seen := make(map[string]bool)
+	for _, r := range logRecords(logs) {
+		if r["msg"] == "new block" {
+			requestID = r["request_id"]
+		}
+		if r["broadcast"] == "" {
+			continue
+		}
+		if broadcastID == "" {
+			broadcastID = r["broadcast"]
+		}
+		if r["broadcast"] != broadcastID || r["request_id"] != requestID {
+			t.Errorf("%q logged with broadcast %s of request %s, want %s of %s", r["msg"], r["broadcast"], r["request_id"], broadcastID, requestID)
+		}
+		seen[r["msg"]] = true
+	}
+	if requestID == "" {
+		t.Fatalf("block detection not logged:\n%s", logs)
+	}
+	for _, msg := range []string{"broadcast started", "sent", "send failed", "broadcast done"} {
+		if !seen[msg] {
+			t.Errorf("no %q line with the broadcast ID:\n%s", msg, logs)
+		}
+	}
+}
+
+// TestPollRequestID checks that the fetch and the notification lines of a
+// poll carry its request ID, and the next poll gets another one.
+func TestPollRequestID(t *testing.T) {
+	logs := captureLogs(t)
+	tg := newFakeTelegram(t)
+	store := newTestStore(t)
+	store.Add(1)
+	pool := poolConfig{Name: defaultPoolName, URL: newPoolAPI(t, 200, blocksJSON(100))}
+	skipFirst := false
+	n := newTestNotifier(t, config{Pools: []poolConfig{pool}, SkipFirstBlockOnStartup: &skipFirst}, store)
+	n.bot = tg.bot(t)
+
+	for i := 0; i < 2; i++ {
+		if err := n.tryNotifyIfNewBlock(withPollLogger(context.Background(), pool.Name), pool); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	msgs := make(map[string][]string)
+	for _, r := range logRecords(logs) {
+		if id := r["request_id"]; id != "" {
+			msgs[id] = append(msgs[id], r["msg"])
+		}
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("%d request IDs logged, want one per poll:\n%s", len(msgs), logs)
+	}
+	var withSend int
+	for id, m := range msgs {
+		if !slices.Contains(m, "fetched last block") {
+			t.Errorf("request %s: fetch not logged, got %q", id, m)
+		}
+		if slices.Contains(m, "sent") {
+			withSend++
+		}
+	}
+	// Only the first poll finds a new block.
+	if withSend != 1 {
+		t.Errorf("%d requests logged a send, want 1: %v", withSend, msgs)
+	}
+}
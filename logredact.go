@@ -0,0 +1,53 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"strconv"
+)
+
+// chatIDSalt keys the hashes of chat IDs in logs; nil unless
+// LogRedactChatIDs is set. main sets it before anything logs a chat ID.
+var chatIDSalt []byte
+
+// chatRef is how logs and audit records refer to a chat or user: the ID
+// itself, or with LogRedactChatIDs a salted hash of it. The salt lives in
+// the state, so the same chat gets the same hash across restarts and can
+// still be followed through the logs of one deployment.
+func chatRef(id int64) string {
+	if chatIDSalt == nil {
+		return strconv.FormatInt(id, 10)
+	}
+	mac := hmac.New(sha256.New, chatIDSalt)
+	mac.Write([]byte(strconv.FormatInt(id, 10)))
+	return "chat-" + hex.EncodeToString(mac.Sum(nil)[:6])
+}
+
+// enableChatIDRedaction turns on hashing in chatRef with the salt kept in
+// st, creating one on first use.
+func enableChatIDRedaction(st *stateStore) error {
+	var salt string
+	st.view(func(st state) { salt = st.LogSalt })
+	if salt == "" {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		salt = hex.EncodeToString(b)
+		if err := st.update(func(st *state) { st.LogSalt = salt }); err != nil {
+			return err
+		}
+	}
+
+	chatIDSalt = []byte(salt)
+	return nil
+}
+
+// botDebug reports whether the Telegram client should log its requests and
+// responses. They carry raw chat IDs, so never with LogRedactChatIDs.
+func botDebug(conf config, level slog.Level) bool {
+	return level <= slog.LevelDebug && !conf.LogRedactChatIDs
+}
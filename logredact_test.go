@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestBotDebug(t *testing.T) {
+	tests := []struct {
+		name   string
+		level  slog.Level
+		redact bool
+		want   bool
+	}{
+		{"info", slog.LevelInfo, false, false},
+		{"debug", slog.LevelDebug, false, true},
+		{"debug with redaction", slog.LevelDebug, true, false},
+	}
+
+	for _, tt := range tests {
+		if got := botDebug(config{LogRedactChatIDs: tt.redact}, tt.level); got != tt.want {
+			t.Errorf("%s: botDebug = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// captureLogs sends the standard, slog and Telegram client logs to a buffer
+// for the rest of the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defaultSlog := slog.Default()
+	slog.SetDefault(slog.New(newLogHandler(&buf, slog.LevelDebug)))
+	tgbotapi.SetLogger(log.New(&buf, "", 0))
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		slog.SetDefault(defaultSlog)
+		tgbotapi.SetLogger(log.New(os.Stderr, "", log.LstdFlags))
+	})
+	return &buf
+}
+
+func TestRedactedLogsHaveNoChatIDs(t *testing.T) {
+	const reachable, blocked, added = 987654321, 876543219, 765432198
+
+	chatIDSalt = []byte("salt")
+	t.Cleanup(func() { chatIDSalt = nil })
+	logs := captureLogs(t)
+
+	conf := config{LogLevel: "debug", LogRedactChatIDs: true}
+	tg := newFakeTelegram(t)
+	tg.failFor(blocked)
+	store := newTestStore(t)
+	store.Add(reachable)
+	store.Add(blocked)
+	n := newTestNotifier(t, conf, store)
+	n.bot = tg.bot(t)
+	n.bot.Debug = botDebug(conf, slog.LevelDebug)
+
+	blocks := []block{{height: 100, ts: time.Now()}}
+	if err := n.broadcast(context.Background(), poolConfig{Name: defaultPoolName}, blocks, func(int64) string { return "block" }); err != nil {
+		t.Fatal(err)
+	}
+	r := &commandRouter{bot: n.bot, store: store, notifier: n}
+	r.cmdAdd(command(1, "/add "+strconv.Itoa(added)))
+
+	if logs.Len() == 0 {
+		t.Fatal("nothing logged")
+	}
+	for _, id := range []int64{reachable, blocked, added} {
+		if raw := strconv.FormatInt(id, 10); strings.Contains(logs.String(), raw) {
+			t.Errorf("log has chat ID %s:\n%s", raw, logs)
+		}
+		if ref := chatRef(id); !strings.Contains(logs.String(), ref) {
+			t.Errorf("log lacks %s for chat %d", ref, id)
+		}
+	}
+}
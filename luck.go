@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const luckRecentRounds = 10
+
+// roundEffort is the share of the block difficulty the pool hashed between
+// prev and cur, 1 being exactly 100%. Notifications and /luck both use it.
+func roundEffort(prev, cur block) float64 {
+	if cur.difficulty == 0 || cur.totalHashes <= prev.totalHashes {
+		return 0
+	}
+	return float64(cur.totalHashes-prev.totalHashes) / float64(cur.difficulty)
+}
+
+func formatEffort(effort float64) string {
+	return fmt.Sprintf("%.1f%%", effort*100)
+}
+
+type luckWindow struct {
+	days    int
+	average float64
+	rounds  int
+	// partial is set when the history doesn't reach back the whole window.
+	partial bool
+}
+
+type luckStats struct {
+	recent      []block
+	windows     []luckWindow
+	streak      int
+	luckiest    block
+	unluckiest  block
+	historyFrom time.Time
+}
+
+// computeLuck summarizes blocks, newest first, as kept in the history of a
+// pool. Blocks with unknown effort are ignored.
+func computeLuck(blocks []block, now time.Time) luckStats {
+	var rounds []block
+	for _, b := range blocks {
+		if b.effort > 0 {
+			rounds = append(rounds, b)
+		}
+	}
+
+	var stats luckStats
+	if len(rounds) == 0 {
+		return stats
+	}
+
+	stats.historyFrom = rounds[len(rounds)-1].ts
+	stats.recent = rounds
+	if len(stats.recent) > luckRecentRounds {
+		stats.recent = stats.recent[:luckRecentRounds]
+	}
+
+	for _, days := range []int{7, 30} {
+		since := now.AddDate(0, 0, -days)
+		w := luckWindow{days: days, partial: stats.historyFrom.After(since)}
+
+		var total float64
+		for _, b := range rounds {
+			if b.ts.Before(since) {
+				break
+			}
+			total += b.effort
+			w.rounds++
+		}
+		if w.rounds > 0 {
+			w.average = total / float64(w.rounds)
+		}
+		stats.windows = append(stats.windows, w)
+	}
+
+	for _, b := range rounds {
+		if b.effort <= 1 {
+			break
+		}
+		stats.streak++
+	}
+
+	stats.luckiest, stats.unluckiest = rounds[0], rounds[0]
+	for _, b := range rounds {
+		if b.effort < stats.luckiest.effort {
+			stats.luckiest = b
+		}
+		if b.effort > stats.unluckiest.effort {
+			stats.unluckiest = b
+		}
+	}
+
+	return stats
+}
+
+func (s luckStats) String() string {
+	if len(s.recent) == 0 {
+		return "Нет данных об усилии раундов"
+	}
+
+	var sb strings.Builder
+	if len(s.recent) < luckRecentRounds {
+		fmt.Fprintf(&sb, "Последние раунды (известно только %d):\n", len(s.recent))
+	} else {
+		fmt.Fprintf(&sb, "Последние %d раундов:\n", luckRecentRounds)
+	}
+	for _, b := range s.recent {
+		fmt.Fprintf(&sb, "%d — %s\n", b.height, formatEffort(b.effort))
+	}
+
+	sb.WriteString("\n")
+	for _, w := range s.windows {
+		switch {
+		case w.rounds == 0:
+			fmt.Fprintf(&sb, "Среднее за %d дн.: нет раундов\n", w.days)
+		case w.partial:
+			fmt.Fprintf(&sb, "Среднее за %d дн.: %s (%d раундов, история неполная)\n", w.days, formatEffort(w.average), w.rounds)
+		default:
+			fmt.Fprintf(&sb, "Среднее за %d дн.: %s (%d раундов)\n", w.days, formatEffort(w.average), w.rounds)
+		}
+	}
+
+	fmt.Fprintf(&sb, "\nНеудачных раундов подряд (>100%%): %d\n", s.streak)
+	fmt.Fprintf(&sb, "Самый удачный: %d — %s\n", s.luckiest.height, formatEffort(s.luckiest.effort))
+	fmt.Fprintf(&sb, "Самый неудачный: %d — %s", s.unluckiest.height, formatEffort(s.unluckiest.effort))
+
+	return sb.String()
+}
@@ -1,82 +1,123 @@
 package main
 
 import (
-	"bufio"
 	"context"
-	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
-	"io/fs"
 	"log"
-	"net/http"
+	"log/slog"
 	"os"
-	"strconv"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
-	"github.com/BurntSushi/toml"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
-const (
-	blocksURL  = "https://p2pool.io/mini/api/pool/blocks"
-	configPath = "./config.toml"
-)
+const defaultConfigPath = "./config.toml"
 
-var (
-	lastBlockChecked       = block{}
-	errUnexpectedStructure = errors.New("unexpected response structure")
-)
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(runValidate(os.Args[2:], os.Stdout))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		os.Exit(runImport(os.Args[2:], os.Stdout))
+	}
 
-type block struct {
-	height int
-	ts     time.Time
-}
+	configPath := flag.String("config", defaultConfigPath, "path to the config file")
+	listBackends := flag.Bool("list-backends", false, "print the available storage backends and exit")
+	printVersion := flag.Bool("version", false, "print the version and exit")
+	exportMetrics := flag.Bool("export-metrics-once", false, "poll the pools once, write the metrics to MetricsTextfilePath and exit")
+	fixturesDir := flag.String("test-api-fixtures", "", "serve the pool API from the JSON files in this directory instead of p2pool.io")
+	flag.Parse()
 
-type config struct {
-	ApiKey          string `toml:"APIKey"`
-	SubscribersFile string `toml:"SubscribersFile"`
-	NotifyDuration  string `toml:"NotifyDuration"`
-}
+	if *printVersion {
+		fmt.Println(version)
+		return
+	}
+	if *listBackends {
+		printBackends(os.Stdout)
+		return
+	}
 
-func readConfig() (config, error) {
-	file, err := os.Open(configPath)
+	conf, err := readConfig(*configPath)
 	if err != nil {
-		return config{}, err
+		log.Fatal(err)
 	}
-	defer file.Close()
 
-	data, err := io.ReadAll(file)
-	if err != nil {
-		return config{}, err
+	if problems := conf.validate(); len(problems) > 0 {
+		log.Fatal(errors.Join(problems...))
+	}
+	level, _ := conf.logLevel()
+	slog.SetDefault(slog.New(newLogHandler(os.Stderr, level)))
+	for _, w := range lint(conf) {
+		slog.Info("config warning", "field", w.Field, "warning", w.Message)
 	}
 
-	var conf config
-	_, err = toml.Decode(string(data), &conf)
-	if err != nil {
-		return config{}, err
+	if *fixturesDir != "" {
+		srv, err := newFixtureAPIServer(*fixturesDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer srv.Close()
+
+		log.Printf("serving pool API fixtures from %s at %s", *fixturesDir, srv.URL)
+		conf.Pools = []poolConfig{{Name: defaultPoolName, URL: srv.URL}}
 	}
 
-	return conf, nil
-}
+	debugPayloadFile = conf.DebugPayloadFile
+	apiClient = newAPIClient(conf)
+	apiRequests = conf.apiRequests()
 
-func main() {
-	conf, err := readConfig()
+	if *exportMetrics {
+		if err := exportMetricsOnce(conf); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if !conf.SkipAPIStartupCheck {
+		if err := checkPoolAPIs(conf.pools(), apiStartupCheckTimeout); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	apiKey, err := resolveAPIKey(conf)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	bot, err := tgbotapi.NewBotAPI(conf.ApiKey)
+	store, err := openStore(conf)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	bot, err := newBot(conf, apiKey)
 	if err != nil {
 		log.Panic(err)
 	}
 
-	bot.Debug = true
+	bot.Debug = botDebug(conf, level)
 
 	log.Printf("Authorized on account %s", bot.Self.UserName)
 
+	// SIGINT or SIGTERM stops taking updates; main then waits for the HTTP
+	// server to drain before exiting.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		log.Printf("shutting down")
+		bot.StopReceivingUpdates()
+	}()
+
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
+	u.AllowedUpdates = conf.allowedUpdates()
+	log.Printf("receiving updates: %s", strings.Join(u.AllowedUpdates, ", "))
 
 	updates := bot.GetUpdatesChan(u)
 
@@ -85,151 +126,135 @@ func main() {
 		log.Fatal(err)
 	}
 
-	go worker(context.TODO(), bot, notifyDuration, conf.SubscribersFile)
-
-	for update := range updates {
-		if update.Message != nil {
-			log.Printf("[%s] %s", update.Message.From.UserName, update.Message.Text)
+	st, err := loadState(conf.StateFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if conf.LogRedactChatIDs {
+		if err := enableChatIDRedaction(st); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-			err := saveSubscriberID(update.Message.Chat.ID, conf.SubscribersFile)
-			var msg tgbotapi.MessageConfig
+	usage := &usageStats{state: st, disabled: conf.DisableUsageStats, sources: make(map[string]bool, len(conf.StartSources))}
+	for _, source := range conf.StartSources {
+		usage.sources[source] = true
+	}
+	var webhooks *webhookDispatcher
+	if len(conf.Webhooks) > 0 {
+		timeout := defaultWebhookTimeout
+		if conf.WebhookTimeout != "" {
+			timeout, err = time.ParseDuration(conf.WebhookTimeout)
 			if err != nil {
-				msg = tgbotapi.NewMessage(update.Message.Chat.ID, "Ошибка при попытке подписаться на уведомления :c")
-			} else {
-				msg = tgbotapi.NewMessage(update.Message.Chat.ID, "Вы успешно подписались на обновления! Теперь бот будет присылать вам сообщение с каждым найденным блоком пулом https://p2pool.io/mini/#pool c:")
+				log.Fatal(err)
 			}
+		}
 
-			msg.ReplyToMessageID = update.Message.MessageID
-
-			bot.Send(msg)
+		concurrency := defaultWebhookConcurrency
+		if conf.WebhookConcurrency > 0 {
+			concurrency = conf.WebhookConcurrency
 		}
-	}
-}
 
-func saveSubscriberID(tgid int64, subscribersFilePath string) error {
-	file, err := os.OpenFile(subscribersFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return err
+		webhooks = newWebhookDispatcher(conf.Webhooks, timeout, concurrency)
 	}
-	defer file.Close()
 
-	_, err = file.WriteString(strconv.FormatInt(tgid, 10) + "\n")
-	if err != nil {
-		return err
+	var monero *MoneroRPCClient
+	if conf.MoneroNodeURL != "" {
+		monero = newMoneroRPCClient(conf.MoneroNodeURL, conf.MoneroNodeUser, conf.MoneroNodePass)
 	}
 
-	return nil
-}
-
-func worker(ctx context.Context, bot *tgbotapi.BotAPI, interval time.Duration, subscribersFilePath string) {
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-			err := tryNotifyIfNewBlock(ctx, bot, subscribersFilePath)
-			if err != nil {
-				log.Printf("error: %s", err.Error())
-			}
-			time.Sleep(interval)
+	notifier := newNotifier(bot, store, conf, usage, st, webhooks, monero)
+	notifier.updates = newUpdateQueue(updates)
+	if conf.MQTT != nil {
+		notifier.mqtt, err = newMQTTPublisher(*conf.MQTT)
+		if err != nil {
+			log.Fatal(err)
 		}
-	}
-}
+		defer notifier.mqtt.close()
 
-func tryNotifyIfNewBlock(_ context.Context, bot *tgbotapi.BotAPI, subscribersFilePath string) error {
-	lastBlock, err := fetchLastBlock()
-	if err != nil {
-		return err
+		go notifier.mqttStatsWorker(ctx, conf.MQTT.statsInterval())
 	}
 
-	if lastBlock.height != lastBlockChecked.height {
-		lastBlockChecked = lastBlock
-		ids, err := getSubscribers(subscribersFilePath)
-		if err != nil {
-			return err
+	if conf.HealthAddr != "" {
+		maxFetchAge := 3 * notifyDuration
+		if conf.AutoTuneInterval {
+			// A tuned interval can be as long as the ceiling.
+			maxFetchAge = 3 * max(notifyDuration, conf.autoTuneCeiling())
 		}
-
-		for _, id := range ids {
-			msg := tgbotapi.NewMessage(id, fmt.Sprintf("Блок найден! Высота: %d, время: %s", lastBlock.height, lastBlock.ts.Format(time.RFC850)))
-			_, err := bot.Send(msg)
+		if conf.HealthMaxFetchAge != "" {
+			maxFetchAge, err = time.ParseDuration(conf.HealthMaxFetchAge)
 			if err != nil {
-				return err
+				log.Fatal(err)
 			}
 		}
-	}
 
-	return nil
-}
+		httpDone := make(chan struct{})
+		go func() {
+			defer close(httpDone)
+			if err := serveHTTP(ctx, conf.HealthAddr, httpMux(notifier, maxFetchAge, conf.PushAPIToken)); err != nil {
+				log.Fatal(err)
+			}
+		}()
+		defer func() { <-httpDone }()
+	}
 
-func getSubscribers(subscribersFilePath string) ([]int64, error) {
-	file, err := os.Open(subscribersFilePath)
-	if err != nil {
-		var pErr *fs.PathError
-		if errors.As(err, &pErr) {
-			log.Printf("no subscribers yet, skip")
-			return nil, nil
-		}
+	report := notifier.reconcile()
+	log.Print(report)
+	if conf.RecoveryReport || report.needsAttention() {
+		report.notifyAdmins(bot, conf.AdminIDs)
 	}
-	defer file.Close()
 
-	var ids []int64
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		id, err := strconv.ParseInt(scanner.Text(), 10, 64)
+	go notifier.worker(ctx)
+	go notifier.verifyWorker(ctx)
+	go notifier.purgeWorker(ctx, conf.unsubscribeRetention())
+	go notifier.maintenanceWorker(ctx)
+	go usage.flushWorker(ctx, usageFlushInterval)
+
+	if conf.PruneInterval != "" {
+		pruneInterval, err := time.ParseDuration(conf.PruneInterval)
 		if err != nil {
-			return nil, err
+			log.Fatal(err)
 		}
-		ids = append(ids, id)
-	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, err
+		go notifier.pruneWorker(ctx, pruneInterval)
 	}
 
-	return ids, nil
-}
-
-func fetchLastBlock() (block, error) {
-	res, err := http.Get(blocksURL)
-	if err != nil {
-		return block{}, err
-	}
-	defer res.Body.Close()
+	if conf.DailyRollupTime != "" {
+		at, err := time.Parse(rollupTimeLayout, conf.DailyRollupTime)
+		if err != nil {
+			log.Fatal(err)
+		}
 
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		return block{}, err
+		go notifier.rollupWorker(ctx, at, conf.StatsChannelID)
 	}
 
-	var blocks []map[string]interface{}
-	err = json.Unmarshal(body, &blocks)
-	if err != nil {
-		return block{}, err
-	}
+	router := newCommandRouter(bot, store, notifier, usage, conf)
 
-	if len(blocks) <= 0 {
-		return block{}, errUnexpectedStructure
-	}
+	for {
+		update, ok := notifier.updates.next()
+		if !ok {
+			break
+		}
 
-	if _, ok := blocks[0]["height"]; !ok {
-		return block{}, errUnexpectedStructure
-	}
+		if update.Message != nil {
+			log.Printf("[%s] %s", sanitize(update.Message.From.UserName), sanitize(update.Message.Text))
 
-	if _, ok := blocks[0]["height"].(float64); !ok {
-		return block{}, errUnexpectedStructure
-	}
+			router.handle(update.Message)
+		}
 
-	lastHeight := blocks[0]["height"].(float64)
+		if update.CallbackQuery != nil {
+			router.handleCallback(update.CallbackQuery)
+		}
 
-	lastTs, ok := blocks[0]["ts"].(float64)
-	if !ok {
-		return block{}, errUnexpectedStructure
+		if update.MyChatMember != nil && !conf.DisableChatOnboarding {
+			router.handleMembership(update.MyChatMember)
+		}
 	}
 
-	lastTime := time.UnixMilli(int64(lastTs))
-
-	return block{
-		height: int(lastHeight),
-		ts:     lastTime,
-	}, nil
+	usage.flush()
+	if closer, ok := store.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Printf("error: close store: %s", err.Error())
+		}
+	}
 }
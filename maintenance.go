@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maintenanceCheckInterval is how often the start and end of maintenance
+// windows are looked for.
+const maintenanceCheckInterval = time.Minute
+
+// maintenanceConfig is a planned maintenance window in RFC 3339.
+type maintenanceConfig struct {
+	Start string `toml:"Start"`
+	End   string `toml:"End"`
+}
+
+type maintenanceWindow struct {
+	start, end time.Time
+}
+
+// maintenanceWindows returns the parsed MaintenanceWindows, which
+// config.validate has checked.
+func (c config) maintenanceWindows() []maintenanceWindow {
+	windows := make([]maintenanceWindow, 0, len(c.MaintenanceWindows))
+	for _, w := range c.MaintenanceWindows {
+		start, _ := time.Parse(time.RFC3339, w.Start)
+		end, _ := time.Parse(time.RFC3339, w.End)
+		windows = append(windows, maintenanceWindow{start: start, end: end})
+	}
+	return windows
+}
+
+// maintenanceUntil returns the end of the maintenance at now, from
+// /maintenance or MaintenanceWindows, or false if there's none. Alerts are
+// held back until then; block notifications still go out.
+func (n *Notifier) maintenanceUntil(now time.Time) (time.Time, bool) {
+	var until time.Time
+	n.state.view(func(st state) {
+		if now.Before(st.MaintenanceUntil) {
+			until = st.MaintenanceUntil
+		}
+	})
+	for _, w := range n.maintenance {
+		if !now.Before(w.start) && now.Before(w.end) && w.end.After(until) {
+			until = w.end
+		}
+	}
+	return until, !until.IsZero()
+}
+
+func (n *Notifier) inMaintenance() bool {
+	_, ok := n.maintenanceUntil(time.Now())
+	return ok
+}
+
+// checkMaintenance tells subscribers once when maintenance starts or is
+// extended and once when it's over.
+func (n *Notifier) checkMaintenance(ctx context.Context) {
+	until, active := n.maintenanceUntil(time.Now())
+
+	var announced time.Time
+	n.state.view(func(st state) { announced = st.MaintenanceAnnounced })
+	if until.Equal(announced) {
+		return
+	}
+
+	err := n.state.update(func(st *state) { st.MaintenanceAnnounced = until })
+	if err != nil {
+		logger(ctx).Error("save maintenance notice", "err", err)
+		return
+	}
+
+	text := "✅ Технические работы на пуле завершены, предупреждения снова включены"
+	if active {
+		logger(ctx).Info("maintenance mode", "until", until)
+		text = fmt.Sprintf("🔧 Технические работы на пуле до %s. Предупреждения отключены, о найденных блоках бот по-прежнему сообщит", until.Format("15:04 MST"))
+	} else {
+		logger(ctx).Info("maintenance mode over")
+	}
+	if _, _, err := n.push(ctx, "", text); err != nil {
+		logger(ctx).Error("send maintenance notice", "err", err)
+	}
+}
+
+// maintenanceWorker announces maintenance windows as they start and end.
+func (n *Notifier) maintenanceWorker(ctx context.Context) {
+	ticker := time.NewTicker(maintenanceCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		n.checkMaintenance(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// cmdMaintenance implements /maintenance <duration> and /maintenance off.
+func (r *commandRouter) cmdMaintenance(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	arg := strings.TrimSpace(msg.CommandArguments())
+	var until time.Time
+	if arg != "off" {
+		d, err := time.ParseDuration(arg)
+		if err != nil || d <= 0 {
+			return reply(msg, "Использование: /maintenance <длительность, например 2h> или /maintenance off")
+		}
+		until = time.Now().Add(d)
+	}
+
+	if err := r.notifier.state.update(func(st *state) { st.MaintenanceUntil = until }); err != nil {
+		slog.Error("save maintenance", "err", err)
+		return reply(msg, "Не удалось сохранить режим обслуживания")
+	}
+	r.notifier.audit.record(actorOf(msg), "maintenance", map[string]string{"until": arg})
+	go r.notifier.checkMaintenance(context.Background())
+
+	if until.IsZero() {
+		return reply(msg, "Режим обслуживания, заданный командой, снят")
+	}
+	return reply(msg, fmt.Sprintf("Режим обслуживания до %s: предупреждения отключены", until.Format("15:04 MST")))
+}
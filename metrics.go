@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// metric is one family in the Prometheus text exposition format.
+type metric struct {
+	name string
+	help string
+	// typ is "gauge" or "counter".
+	typ     string
+	samples []metricSample
+}
+
+type metricSample struct {
+	labels map[string]string
+	value  float64
+}
+
+// metrics gathers every metric of the bot. Both /metrics and the textfile
+// export render its result, so the two always agree.
+func (n *Notifier) metrics() []metric {
+	s := n.snapshot()
+
+	heights := metric{name: "p2pool_notifier_last_block_height", help: "Height of the last block seen per pool.", typ: "gauge"}
+	times := metric{name: "p2pool_notifier_last_block_timestamp_seconds", help: "Time the last block seen per pool was found.", typ: "gauge"}
+	names := make([]string, 0, len(s.lastBlocks))
+	for name := range s.lastBlocks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b := s.lastBlocks[name]
+		labels := map[string]string{"pool": name}
+		heights.samples = append(heights.samples, metricSample{labels, float64(b.height)})
+		times.samples = append(times.samples, metricSample{labels, unixSeconds(b.ts)})
+	}
+
+	metrics := []metric{
+		gauge("p2pool_notifier_start_time_seconds", "Time the bot started.", unixSeconds(s.startedAt)),
+		heights,
+		times,
+	}
+	fetched := metric{name: "p2pool_notifier_last_successful_fetch_timestamp_seconds", help: "Time the API of a pool was last reached.", typ: "gauge"}
+	seen := metric{name: "p2pool_notifier_last_block_seen_timestamp_seconds", help: "Time a new block of a pool was last noticed.", typ: "gauge"}
+	failing := metric{name: "p2pool_notifier_last_fetch_failed", help: "1 if the last poll of a pool failed.", typ: "gauge"}
+	for _, name := range s.poolNames() {
+		f := s.fetches[name]
+		labels := map[string]string{"pool": name}
+		if !f.lastSuccess.IsZero() {
+			fetched.samples = append(fetched.samples, metricSample{labels, unixSeconds(f.lastSuccess)})
+		}
+		if !f.lastBlockSeenAt.IsZero() {
+			seen.samples = append(seen.samples, metricSample{labels, unixSeconds(f.lastBlockSeenAt)})
+		}
+		failed := 0.0
+		if f.lastError != nil {
+			failed = 1
+		}
+		failing.samples = append(failing.samples, metricSample{labels, failed})
+	}
+	metrics = append(metrics, fetched, seen, failing,
+		gauge("p2pool_notifier_update_queue_depth", "Telegram updates waiting for the router.", float64(n.updates.depth())),
+		counter("p2pool_notifier_dropped_updates_total", "Updates dropped while the update queue was full.", float64(n.updates.droppedCount())),
+		gauge("p2pool_notifier_send_queue_depth", "Messages waiting for a send slot.", float64(n.sends.depth())),
+	)
+	sent := metric{name: "p2pool_notifier_notifications_sent_total", help: "Block notifications sent per priority class.", typ: "counter"}
+	for _, p := range []sendPriority{priorityOperator, priorityBulk} {
+		labels := map[string]string{"priority": p.String()}
+		sent.samples = append(sent.samples, metricSample{labels, float64(n.stats.sentByPriority[p].Load())})
+	}
+	metrics = append(metrics, sent)
+	if l := n.latency.summary(); l.samples > 0 {
+		latency := metric{name: "p2pool_notifier_delivery_latency_seconds", help: "Time from a block being found to its notification reaching the first and the last subscriber.", typ: "gauge"}
+		for _, q := range []struct {
+			recipient, quantile string
+			value               time.Duration
+		}{
+			{"first", "0.5", l.firstP50},
+			{"first", "0.95", l.firstP95},
+			{"last", "0.5", l.lastP50},
+			{"last", "0.95", l.lastP95},
+		} {
+			labels := map[string]string{"recipient": q.recipient, "quantile": q.quantile}
+			latency.samples = append(latency.samples, metricSample{labels, q.value.Seconds()})
+		}
+		metrics = append(metrics, latency)
+	}
+	if count := subscriberCount(n.store); count != nil {
+		metrics = append(metrics, gauge("p2pool_notifier_subscribers", "Number of subscribed chats.", float64(*count)))
+	}
+	return metrics
+}
+
+func gauge(name, help string, value float64) metric {
+	return metric{name: name, help: help, typ: "gauge", samples: []metricSample{{value: value}}}
+}
+
+func counter(name, help string, value float64) metric {
+	return metric{name: name, help: help, typ: "counter", samples: []metricSample{{value: value}}}
+}
+
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixMilli()) / 1000
+}
+
+func writeMetrics(w io.Writer, metrics []metric) error {
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		fmt.Fprintf(bw, "# HELP %s %s\n", m.name, m.help)
+		fmt.Fprintf(bw, "# TYPE %s %s\n", m.name, m.typ)
+		for _, s := range m.samples {
+			fmt.Fprintf(bw, "%s%s %g\n", m.name, formatLabels(s.labels), s.value)
+		}
+	}
+	return bw.Flush()
+}
+
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, escape.Replace(labels[name])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func metricsHandler(n *Notifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		writeMetrics(w, n.metrics())
+	}
+}
+
+// writeMetricsTextfile replaces path with the current metrics for the
+// node_exporter textfile collector. The file is written next to path and
+// renamed over it, so the collector never reads a partial file.
+func (n *Notifier) writeMetricsTextfile(path string) {
+	if err := writePrometheusTextFile(path, n.textfileMetrics()); err != nil {
+		log.Printf("error: write metrics textfile: %s", err.Error())
+	}
+}
+
+// textfileMetrics are the metrics plus when the file was written, which
+// tells a stale file apart.
+func (n *Notifier) textfileMetrics() []metric {
+	return append(n.metrics(), gauge("p2pool_notifier_textfile_timestamp_seconds", "Time this file was written.", unixSeconds(time.Now())))
+}
+
+func writePrometheusTextFile(path string, metrics []metric) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = writeMetrics(tmp, metrics)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		// CreateTemp makes the file 0600, the collector may run as
+		// another user.
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	return err
+}
+
+// exportMetricsOnce polls every pool once and writes the metrics to
+// MetricsTextfilePath, for hosts that run the bot from cron instead of
+// keeping it and its HTTP server up.
+func exportMetricsOnce(conf config) error {
+	if conf.MetricsTextfilePath == "" {
+		return errors.New("-export-metrics-once needs MetricsTextfilePath")
+	}
+
+	store, err := openStore(conf)
+	if err != nil {
+		return err
+	}
+	if closer, ok := store.(io.Closer); ok {
+		defer closer.Close()
+	}
+	st, err := loadState(conf.StateFile)
+	if err != nil {
+		return err
+	}
+
+	n := newNotifier(nil, store, conf, &usageStats{state: st, disabled: true}, st, nil, nil)
+	for _, pool := range n.pools {
+		b, err := fetchLastBlock(pool.URL)
+		n.recordFetch(pool.Name, err)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pool.Name, err)
+		}
+		n.lastBlocks.setLastBlock(pool.Name, b)
+	}
+
+	return writePrometheusTextFile(conf.MetricsTextfilePath, n.textfileMetrics())
+}
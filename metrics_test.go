@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestExportMetricsOnce(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		noPath    bool
+		wantErr   bool
+		wantLines []string
+	}{
+		{
+			name:   "writes the textfile",
+			status: 200,
+			body:   blocksJSON(3000002, 3000001),
+			wantLines: []string{
+				"# TYPE p2pool_notifier_last_block_height gauge",
+				`p2pool_notifier_last_block_height{pool="mini"} 3.000002e+06`,
+				`p2pool_notifier_last_fetch_failed{pool="mini"} 0`,
+				"p2pool_notifier_subscribers 0",
+				"# TYPE p2pool_notifier_textfile_timestamp_seconds gauge",
+			},
+		},
+		{name: "pool down", status: 500, body: "oops", wantErr: true},
+		{name: "no path", status: 200, body: blocksJSON(1), noPath: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "p2pool.prom")
+			conf := config{
+				Pools:           []poolConfig{{Name: "mini", URL: newPoolAPI(t, tt.status, tt.body)}},
+				SubscribersFile: filepath.Join(dir, "subscribers.json"),
+			}
+			if !tt.noPath {
+				conf.MetricsTextfilePath = path
+			}
+
+			err := exportMetricsOnce(conf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("exportMetricsOnce() = %v, want an error %v", err, tt.wantErr)
+			}
+
+			data, readErr := os.ReadFile(path)
+			if tt.wantErr {
+				if readErr == nil {
+					t.Errorf("textfile written after a failure:\n%s", data)
+				}
+				return
+			}
+			if readErr != nil {
+				t.Fatal(readErr)
+			}
+			for _, want := range tt.wantLines {
+				if !strings.Contains(string(data), want+"\n") {
+					t.Errorf("textfile lacks %q:\n%s", want, data)
+				}
+			}
+		})
+	}
+}
+
+func TestWritePrometheusTextFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "p2pool.prom")
+	if err := os.WriteFile(path, []byte("stale\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := []metric{
+		gauge("up", "Whether it's up.", 1),
+		{name: "blocks", help: "Blocks per pool.", typ: "counter", samples: []metricSample{
+			{labels: map[string]string{"pool": `mi"ni`}, value: 3},
+		}},
+	}
+	if err := writePrometheusTextFile(path, metrics); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# HELP up Whether it's up.\n# TYPE up gauge\nup 1\n" +
+		"# HELP blocks Blocks per pool.\n# TYPE blocks counter\nblocks{pool=\"mi\\\"ni\"} 3\n"
+	if string(data) != want {
+		t.Errorf("textfile =\n%s\nwant\n%s", data, want)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0644 {
+		t.Errorf("mode = %o, want 644", mode)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("%d files in the directory, want only the textfile", len(entries))
+	}
+}
+
+// metricValue returns the first sample of the metric called name.
+func metricValue(t *testing.T, metrics []metric, name string) float64 {
+	t.Helper()
+	for _, m := range metrics {
+		if m.name == name && len(m.samples) > 0 {
+			return m.samples[0].value
+		}
+	}
+	t.Fatalf("no metric %s", name)
+	return 0
+}
+
+// TestQueueDepthMetricsSoak floods both queues and checks that the depth
+// gauges follow them up and back down to zero.
+func TestQueueDepthMetricsSoak(t *testing.T) {
+	const updates, senders = 500, 200
+
+	n := newTestNotifier(t, config{}, newTestStore(t))
+	n.sends = newSendQueue(time.Hour)
+
+	ch := make(chan tgbotapi.Update, updates)
+	for i := 0; i < updates; i++ {
+		ch <- tgbotapi.Update{UpdateID: i, Message: textMessage(int64(i), "/status")}
+	}
+	n.updates = newUpdateQueue(ch)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n.sends.wait(ctx)
+		}()
+	}
+	// The first sender gets its slot right away, the rest wait an hour.
+	deadline := time.Now().Add(2 * time.Second)
+	for n.sends.depth() != senders-1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	m := n.metrics()
+	if got := metricValue(t, m, "p2pool_notifier_update_queue_depth"); got != updates {
+		t.Errorf("update queue depth = %g, want %d", got, updates)
+	}
+	if got := metricValue(t, m, "p2pool_notifier_send_queue_depth"); got != senders-1 {
+		t.Errorf("send queue depth = %g, want %d", got, senders-1)
+	}
+
+	close(ch)
+	handled := 0
+	for {
+		if _, ok := n.updates.next(); !ok {
+			break
+		}
+		handled++
+	}
+	cancel()
+	wg.Wait()
+
+	m = n.metrics()
+	if handled != updates {
+		t.Errorf("%d updates handled, want %d", handled, updates)
+	}
+	for _, name := range []string{"p2pool_notifier_update_queue_depth", "p2pool_notifier_send_queue_depth", "p2pool_notifier_dropped_updates_total"} {
+		if got := metricValue(t, m, name); got != 0 {
+			t.Errorf("%s = %g after draining, want 0", name, got)
+		}
+	}
+}
+
+func TestLatencyMetrics(t *testing.T) {
+	n := newTestNotifier(t, config{}, newTestStore(t))
+	var out strings.Builder
+	writeMetrics(&out, n.metrics())
+	if strings.Contains(out.String(), "p2pool_notifier_delivery_latency_seconds") {
+		t.Errorf("latency exported before any broadcast:\n%s", out.String())
+	}
+
+	for i := 1; i <= 20; i++ {
+		n.latency.record(deliveryLatency{first: time.Duration(i) * time.Second, last: time.Duration(10*i) * time.Second})
+	}
+	out.Reset()
+	writeMetrics(&out, n.metrics())
+	for _, want := range []string{
+		"# TYPE p2pool_notifier_delivery_latency_seconds gauge",
+		`p2pool_notifier_delivery_latency_seconds{quantile="0.5",recipient="first"} 10`,
+		`p2pool_notifier_delivery_latency_seconds{quantile="0.95",recipient="first"} 19`,
+		`p2pool_notifier_delivery_latency_seconds{quantile="0.5",recipient="last"} 100`,
+		`p2pool_notifier_delivery_latency_seconds{quantile="0.95",recipient="last"} 190`,
+	} {
+		if !strings.Contains(out.String(), want+"\n") {
+			t.Errorf("metrics lack %q:\n%s", want, out.String())
+		}
+	}
+}
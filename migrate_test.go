@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	groupID      = -4001
+	supergroupID = -1004001
+)
+
+func TestMigrationUpdateMovesSubscription(t *testing.T) {
+	tg := newFakeTelegram(t)
+	store := newTestStore(t)
+	store.Add(groupID)
+	n := newTestNotifier(t, config{}, store)
+	n.bot = tg.bot(t)
+	r := newCommandRouter(n.bot, store, n, n.usage, config{})
+
+	r.handle(&tgbotapi.Message{
+		Chat:            &tgbotapi.Chat{ID: groupID, Type: "group"},
+		MigrateToChatID: supergroupID,
+	})
+
+	ids, _ := store.Subscribers()
+	if !slices.Equal(ids, []int64{supergroupID}) {
+		t.Errorf("subscribers = %v, want [%d]", ids, supergroupID)
+	}
+	if sent := tg.sentTo(); len(sent) != 0 {
+		t.Errorf("replied to a migration update: %v", sent)
+	}
+}
+
+func TestSendFollowsMigration(t *testing.T) {
+	tg := newFakeTelegram(t)
+	tg.migrate(groupID, supergroupID)
+	store := newTestStore(t)
+	store.Add(groupID)
+	n := newTestNotifier(t, config{}, store)
+	n.bot = tg.bot(t)
+
+	if _, err := n.send(context.Background(), tgbotapi.NewMessage(groupID, "block")); err != nil {
+		t.Fatal(err)
+	}
+
+	ids, _ := store.Subscribers()
+	if !slices.Equal(ids, []int64{supergroupID}) {
+		t.Errorf("subscribers = %v, want [%d]", ids, supergroupID)
+	}
+	if sent := tg.sentTo(); !slices.Equal(sent, []int64{supergroupID}) {
+		t.Errorf("sent to %v, want the supergroup", sent)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// defaultMilestones are the found block counts announced unless
+// MilestoneHeights says otherwise.
+var defaultMilestones = []int{100, 500, 1000, 5000, 10000}
+
+// milestoneSet holds the found block counts worth a special announcement.
+type milestoneSet map[int]bool
+
+func newMilestoneSet(counts []int) milestoneSet {
+	m := make(milestoneSet, len(counts))
+	for _, c := range counts {
+		m[c] = true
+	}
+	return m
+}
+
+// reached returns the highest milestone count has passed that isn't in
+// announced, and the unannounced ones below it, which are skipped. Found
+// counts jump by more than one when blocks are found between checks, so an
+// exact match would miss milestones.
+func (m milestoneSet) reached(count int, announced []int) (int, []int) {
+	highest := 0
+	var passed []int
+	for c := range m {
+		if c > count || slices.Contains(announced, c) {
+			continue
+		}
+		passed = append(passed, c)
+		highest = max(highest, c)
+	}
+	if highest != 0 && highest < slices.Max(append([]int{0}, announced...)) {
+		// A later milestone was announced already.
+		return 0, passed
+	}
+	return highest, passed
+}
+
+func formatMilestoneMessage(count int) string {
+	return fmt.Sprintf("🎉🎉🎉 P2Pool нашёл свой %d-й блок!!! Поздравляем всех майнеров пула! 🎉🎉🎉", count)
+}
+
+// checkMilestone looks up how many blocks pool has found and announces the
+// milestone to every subscriber once, even across restarts.
+func (n *Notifier) checkMilestone(ctx context.Context, pool poolConfig) {
+	url, ok := pool.statsURL()
+	if !ok || len(n.milestones) == 0 {
+		return
+	}
+
+	stats, err := fetchPoolStats(url)
+	if err != nil {
+		logger(ctx).Error("fetch stats for milestones", "err", err)
+		return
+	}
+	count := stats.TotalBlocksFound
+
+	milestone := 0
+	err = n.state.update(func(st *state) {
+		var passed []int
+		milestone, passed = n.milestones.reached(count, st.Milestones[pool.Name])
+		if len(passed) == 0 {
+			return
+		}
+		if st.Milestones == nil {
+			st.Milestones = make(map[string][]int)
+		}
+		st.Milestones[pool.Name] = append(st.Milestones[pool.Name], passed...)
+		slices.Sort(st.Milestones[pool.Name])
+	})
+	if err != nil {
+		logger(ctx).Error("save milestone", "err", err)
+		return
+	}
+	if milestone == 0 {
+		return
+	}
+
+	logger(ctx).Info("milestone", "milestone", milestone, "blocks_found", count)
+	if _, _, err := n.push(ctx, "", formatMilestoneMessage(milestone)); err != nil {
+		logger(ctx).Error("announce milestone", "milestone", milestone, "err", err)
+	}
+}
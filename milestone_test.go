@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestMilestoneReached(t *testing.T) {
+	m := newMilestoneSet([]int{100, 500, 1000})
+
+	tests := []struct {
+		name       string
+		count      int
+		announced  []int
+		want       int
+		wantPassed []int
+	}{
+		{"below first", 99, nil, 0, nil},
+		{"exact", 100, nil, 100, []int{100}},
+		{"jumped past", 103, nil, 100, []int{100}},
+		{"already announced", 104, []int{100}, 0, nil},
+		{"next one", 502, []int{100}, 500, []int{500}},
+		{"several passed", 1001, nil, 1000, []int{100, 500, 1000}},
+		{"later one announced", 1001, []int{1000}, 0, []int{100, 500}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, passed := m.reached(tt.count, tt.announced)
+			slices.Sort(passed)
+			if got != tt.want || !slices.Equal(passed, tt.wantPassed) {
+				t.Errorf("reached(%d, %v) = %d, %v, want %d, %v", tt.count, tt.announced, got, passed, tt.want, tt.wantPassed)
+			}
+		})
+	}
+}
+
+func TestCheckMilestoneAnnouncesOnce(t *testing.T) {
+	tg := newFakeTelegram(t)
+	store := newTestStore(t)
+	store.Add(1)
+	n := newTestNotifier(t, config{}, store)
+	n.bot = tg.bot(t)
+
+	// Blocks are found between checks, so the count skips the milestone.
+	for _, count := range []int{99, 101, 102, 103} {
+		pool := poolConfig{Name: defaultPoolName, StatsURL: newPoolAPI(t, http.StatusOK, fmt.Sprintf(`{"pool_statistics":{"totalBlocksFound":%d}}`, count))}
+		n.checkMilestone(context.Background(), pool)
+	}
+
+	if got := len(tg.sentTo()); got != 1 {
+		t.Errorf("announced %d times, want 1", got)
+	}
+	n.state.view(func(st state) {
+		if got := st.Milestones[defaultPoolName]; !slices.Equal(got, []int{100}) {
+			t.Errorf("announced milestones = %v, want [100]", got)
+		}
+	})
+}
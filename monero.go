@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// MoneroRPCClient talks to a monerod JSON-RPC endpoint, e.g.
+// http://127.0.0.1:18081/json_rpc. Credentials are sent with HTTP digest
+// authentication, as monerod's --rpc-login expects.
+type MoneroRPCClient struct {
+	url        string
+	user, pass string
+	client     *http.Client
+}
+
+type nodeInfo struct {
+	Height       uint64 `json:"height"`
+	TargetHeight uint64 `json:"target_height"`
+	Difficulty   uint64 `json:"difficulty"`
+	Synchronized bool   `json:"synchronized"`
+	Status       string `json:"status"`
+}
+
+type blockHeader struct {
+	Hash         string `json:"hash"`
+	Height       uint64 `json:"height"`
+	Timestamp    int64  `json:"timestamp"`
+	Reward       uint64 `json:"reward"`
+	Difficulty   uint64 `json:"difficulty"`
+	Depth        uint64 `json:"depth"`
+	OrphanStatus bool   `json:"orphan_status"`
+}
+
+type rpcRequest struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      string      `json:"id"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("monero rpc error %d: %s", e.Code, e.Message)
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+func newMoneroRPCClient(url, user, pass string) *MoneroRPCClient {
+	return &MoneroRPCClient{
+		url:    url,
+		user:   user,
+		pass:   pass,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (c *MoneroRPCClient) GetInfo() (nodeInfo, error) {
+	var info nodeInfo
+	err := c.call("get_info", nil, &info)
+	return info, err
+}
+
+func (c *MoneroRPCClient) GetBlockHeaderByHash(hash string) (blockHeader, error) {
+	var result struct {
+		BlockHeader blockHeader `json:"block_header"`
+		Status      string      `json:"status"`
+	}
+	err := c.call("get_block_header_by_hash", map[string]string{"hash": hash}, &result)
+	return result.BlockHeader, err
+}
+
+func (c *MoneroRPCClient) call(method string, params, result interface{}) error {
+	body, err := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: "0", Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+
+	res, err := c.post(body, "")
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode == http.StatusUnauthorized && c.user != "" {
+		challenge := res.Header.Get("WWW-Authenticate")
+		res.Body.Close()
+
+		auth, err := c.digestAuthorization(challenge)
+		if err != nil {
+			return err
+		}
+
+		res, err = c.post(body, auth)
+		if err != nil {
+			return err
+		}
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("monero rpc %s: unexpected status %s", method, res.Status)
+	}
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	var resp rpcResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	return json.Unmarshal(resp.Result, result)
+}
+
+func (c *MoneroRPCClient) post(body []byte, authorization string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	return c.client.Do(req)
+}
+
+var digestParamRe = regexp.MustCompile(`(\w+)=(?:"([^"]*)"|([^,\s]*))`)
+
+// digestAuthorization answers an RFC 7616 MD5 digest challenge.
+func (c *MoneroRPCClient) digestAuthorization(challenge string) (string, error) {
+	if !strings.HasPrefix(challenge, "Digest ") {
+		return "", errors.New("monero rpc: node did not offer digest authentication")
+	}
+
+	params := make(map[string]string)
+	for _, m := range digestParamRe.FindAllStringSubmatch(challenge, -1) {
+		params[strings.ToLower(m[1])] = m[2] + m[3]
+	}
+
+	u, err := url.Parse(c.url)
+	if err != nil {
+		return "", err
+	}
+	uri := u.RequestURI()
+
+	ha1 := md5Hex(c.user + ":" + params["realm"] + ":" + c.pass)
+	ha2 := md5Hex(http.MethodPost + ":" + uri)
+
+	header := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", algorithm=MD5`,
+		c.user, params["realm"], params["nonce"], uri)
+
+	if strings.Contains(params["qop"], "auth") {
+		cnonce, err := randomHex(8)
+		if err != nil {
+			return "", err
+		}
+		const nc = "00000001"
+		response := md5Hex(ha1 + ":" + params["nonce"] + ":" + nc + ":" + cnonce + ":auth:" + ha2)
+		header += fmt.Sprintf(`, qop=auth, nc=%s, cnonce="%s", response="%s"`, nc, cnonce, response)
+	} else {
+		header += fmt.Sprintf(`, response="%s"`, md5Hex(ha1+":"+params["nonce"]+":"+ha2))
+	}
+
+	if opaque, ok := params["opaque"]; ok {
+		header += fmt.Sprintf(`, opaque="%s"`, opaque)
+	}
+
+	return header, nil
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
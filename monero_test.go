@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newMoneroNode serves monerod JSON-RPC results by method. With user set it
+// demands digest authentication first.
+func newMoneroNode(t *testing.T, user, pass string, results map[string]string) string {
+	t.Helper()
+	const realm, nonce = "monero-rpc", "abc123"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user != "" && !validDigest(r, user, pass, realm, nonce) {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest qop="auth",realm="%s",nonce="%s",algorithm=MD5`, realm, nonce))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var req rpcRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.JSONRPC != "2.0" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		result, ok := results[req.Method]
+		if !ok {
+			fmt.Fprint(w, `{"jsonrpc":"2.0","id":"0","error":{"code":-32601,"message":"Method not found"}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":"0","result":%s}`, result)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL + "/json_rpc"
+}
+
+func validDigest(r *http.Request, user, pass, realm, nonce string) bool {
+	auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Digest ")
+	if !ok {
+		return false
+	}
+	params := make(map[string]string)
+	for _, m := range digestParamRe.FindAllStringSubmatch(auth, -1) {
+		params[m[1]] = m[2] + m[3]
+	}
+	ha1 := md5Hex(user + ":" + realm + ":" + pass)
+	ha2 := md5Hex(r.Method + ":" + params["uri"])
+	want := md5Hex(ha1 + ":" + nonce + ":" + params["nc"] + ":" + params["cnonce"] + ":auth:" + ha2)
+	return params["username"] == user && params["uri"] == r.URL.RequestURI() && params["response"] == want
+}
+
+func TestMoneroRPCGetInfo(t *testing.T) {
+	tests := []struct {
+		name       string
+		serverUser string
+		clientUser string
+		clientPass string
+		wantErr    bool
+	}{
+		{name: "no auth"},
+		{name: "digest auth", serverUser: "rpc", clientUser: "rpc", clientPass: "secret"},
+		{name: "wrong password", serverUser: "rpc", clientUser: "rpc", clientPass: "wrong", wantErr: true},
+		{name: "no credentials", serverUser: "rpc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url := newMoneroNode(t, tt.serverUser, "secret", map[string]string{
+				"get_info": `{"height":3100000,"target_height":0,"difficulty":300000000000,"synchronized":true,"status":"OK"}`,
+			})
+			c := newMoneroRPCClient(url, tt.clientUser, tt.clientPass)
+
+			info, err := c.GetInfo()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Height != 3100000 || info.Difficulty != 300000000000 || !info.Synchronized {
+				t.Errorf("info = %+v", info)
+			}
+		})
+	}
+}
+
+func TestMoneroRPCGetBlockHeaderByHash(t *testing.T) {
+	url := newMoneroNode(t, "", "", map[string]string{
+		"get_block_header_by_hash": `{"block_header":{"hash":"ab","height":3000000,"reward":600000000000,"depth":3},"status":"OK"}`,
+	})
+	c := newMoneroRPCClient(url, "", "")
+
+	header, err := c.GetBlockHeaderByHash("ab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Height != 3000000 || header.Reward != 600000000000 || header.Depth != 3 {
+		t.Errorf("header = %+v", header)
+	}
+}
+
+func TestMoneroRPCError(t *testing.T) {
+	c := newMoneroRPCClient(newMoneroNode(t, "", "", nil), "", "")
+
+	_, err := c.GetInfo()
+	var rpcErr *rpcError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != -32601 {
+		t.Errorf("error = %v, want the RPC error", err)
+	}
+}
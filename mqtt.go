@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	defaultMQTTTopicPrefix   = "p2pool"
+	defaultMQTTStatsInterval = 5 * time.Minute
+
+	// mqttMaxReconnectInterval caps the backoff between reconnects, which
+	// doubles from one second.
+	mqttMaxReconnectInterval = 2 * time.Minute
+	// mqttPublishTimeout bounds the wait for a publish to be confirmed.
+	mqttPublishTimeout = 10 * time.Second
+)
+
+// mqttConfig is the [MQTT] section. Without it nothing is published.
+type mqttConfig struct {
+	// Broker is the broker URL, e.g. tcp://localhost:1883 or
+	// ssl://broker:8883.
+	Broker   string `toml:"Broker"`
+	ClientID string `toml:"ClientID"`
+	Username string `toml:"Username"`
+	Password string `toml:"Password"`
+	// CAFile verifies an ssl:// broker against a private CA.
+	CAFile      string `toml:"CAFile"`
+	TopicPrefix string `toml:"TopicPrefix"`
+	QoS         byte   `toml:"QoS"`
+	// StatsInterval is how often pool stats are published.
+	StatsInterval string `toml:"StatsInterval"`
+}
+
+func (c mqttConfig) validate() []error {
+	var problems []error
+	if c.Broker == "" {
+		problems = append(problems, errors.New("MQTT: Broker is required"))
+	}
+	if c.QoS > 2 {
+		problems = append(problems, errors.New("MQTT: QoS must be 0, 1 or 2"))
+	}
+	if c.StatsInterval != "" {
+		if d, err := time.ParseDuration(c.StatsInterval); err != nil {
+			problems = append(problems, fmt.Errorf("MQTT: StatsInterval: %w", err))
+		} else if d <= 0 {
+			problems = append(problems, errors.New("MQTT: StatsInterval must be positive"))
+		}
+	}
+	return problems
+}
+
+func (c mqttConfig) topicPrefix() string {
+	if c.TopicPrefix == "" {
+		return defaultMQTTTopicPrefix
+	}
+	return c.TopicPrefix
+}
+
+func (c mqttConfig) statsInterval() time.Duration {
+	if c.StatsInterval == "" {
+		return defaultMQTTStatsInterval
+	}
+	d, _ := time.ParseDuration(c.StatsInterval)
+	return d
+}
+
+// mqttPublisher sends found blocks and pool stats to an MQTT broker, e.g.
+// for home automation. Publishing is fire and forget: a broker that is
+// down never holds up Telegram notifications.
+type mqttPublisher struct {
+	client mqtt.Client
+	prefix string
+	qos    byte
+}
+
+// mqttBlock is the retained message on <prefix>/block.
+type mqttBlock struct {
+	Pool   string    `json:"pool"`
+	Height int       `json:"height"`
+	Time   time.Time `json:"ts"`
+	Hash   string    `json:"hash,omitempty"`
+	// Effort is in percent, 0 if unknown.
+	Effort float64 `json:"effort,omitempty"`
+}
+
+// mqttStats is the message on <prefix>/stats.
+type mqttStats struct {
+	Pool             string    `json:"pool"`
+	Time             time.Time `json:"ts"`
+	Hashrate         float64   `json:"hashrate"`
+	Miners           int       `json:"miners"`
+	TotalBlocksFound int       `json:"total_blocks_found"`
+}
+
+// newMQTTPublisher starts connecting to the broker in the background; the
+// client reconnects on its own, backing off up to
+// mqttMaxReconnectInterval.
+func newMQTTPublisher(conf mqttConfig) (*mqttPublisher, error) {
+	opts := mqtt.NewClientOptions().
+		AddBroker(conf.Broker).
+		SetClientID(conf.ClientID).
+		SetUsername(conf.Username).
+		SetPassword(conf.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetMaxReconnectInterval(mqttMaxReconnectInterval).
+		SetOnConnectHandler(func(mqtt.Client) {
+			log.Printf("connected to MQTT broker %s", conf.Broker)
+		}).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			log.Printf("error: lost MQTT broker %s, reconnecting: %s", conf.Broker, err.Error())
+		})
+
+	if conf.CAFile != "" {
+		pem, err := os.ReadFile(conf.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("MQTT: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("MQTT: no certificates in %s", conf.CAFile)
+		}
+		opts.SetTLSConfig(&tls.Config{RootCAs: pool})
+	}
+
+	client := mqtt.NewClient(opts)
+	client.Connect()
+	return &mqttPublisher{client: client, prefix: conf.topicPrefix(), qos: conf.QoS}, nil
+}
+
+func (p *mqttPublisher) publish(topic string, retained bool, v any) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("error: encode MQTT message for %s: %s", topic, err.Error())
+		return
+	}
+
+	token := p.client.Publish(p.prefix+"/"+topic, p.qos, retained, payload)
+	if !token.WaitTimeout(mqttPublishTimeout) {
+		log.Printf("error: publish to MQTT %s/%s: timed out", p.prefix, topic)
+		return
+	}
+	if err := token.Error(); err != nil {
+		log.Printf("error: publish to MQTT %s/%s: %s", p.prefix, topic, err.Error())
+	}
+}
+
+// publishBlock publishes b as the retained message on <prefix>/block, so
+// devices connecting later still see the last block.
+func (p *mqttPublisher) publishBlock(pool poolConfig, b block) {
+	p.publish("block", true, mqttBlock{Pool: pool.Name, Height: b.height, Time: b.ts, Hash: b.hash, Effort: b.effort * 100})
+}
+
+func (p *mqttPublisher) close() {
+	p.client.Disconnect(250)
+}
+
+// mqttStatsWorker publishes the stats of every pool that has a stats
+// endpoint to <prefix>/stats every interval.
+func (n *Notifier) mqttStatsWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, pool := range n.pools {
+			url, ok := pool.statsURL()
+			if !ok {
+				continue
+			}
+			stats, err := fetchPoolStats(url)
+			if err != nil {
+				log.Printf("error: %s: fetch stats for MQTT: %s", pool.Name, err.Error())
+				continue
+			}
+			n.mqtt.publish("stats", false, mqttStats{
+				Pool:             pool.Name,
+				Time:             time.Now(),
+				Hashrate:         stats.HashRate,
+				Miners:           stats.Miners,
+				TotalBlocksFound: stats.TotalBlocksFound,
+			})
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// fakeMQTTClient records publishes instead of talking to a broker. Until
+// release is closed, publishes hang like those to a broker that is down.
+type fakeMQTTClient struct {
+	mqtt.Client
+
+	err     error
+	release chan struct{}
+
+	mu        sync.Mutex
+	published []fakeMQTTMessage
+	done      chan struct{}
+}
+
+type fakeMQTTMessage struct {
+	topic    string
+	qos      byte
+	retained bool
+	payload  []byte
+}
+
+func newFakeMQTTClient(err error, hung bool) *fakeMQTTClient {
+	c := &fakeMQTTClient{err: err, release: make(chan struct{}), done: make(chan struct{}, 1)}
+	if !hung {
+		close(c.release)
+	}
+	return c
+}
+
+func (c *fakeMQTTClient) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	c.mu.Lock()
+	c.published = append(c.published, fakeMQTTMessage{topic, qos, retained, payload.([]byte)})
+	c.mu.Unlock()
+	return fakeMQTTToken{c}
+}
+
+func (c *fakeMQTTClient) messages() []fakeMQTTMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]fakeMQTTMessage(nil), c.published...)
+}
+
+type fakeMQTTToken struct{ c *fakeMQTTClient }
+
+func (t fakeMQTTToken) Wait() bool { return t.WaitTimeout(0) }
+
+func (t fakeMQTTToken) WaitTimeout(time.Duration) bool {
+	<-t.c.release
+	t.c.done <- struct{}{}
+	return true
+}
+
+func (t fakeMQTTToken) Done() <-chan struct{} { return t.c.release }
+
+func (t fakeMQTTToken) Error() error { return t.c.err }
+
+func TestMQTTDoesNotHoldUpTelegram(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		hung bool
+	}{
+		{name: "published"},
+		{name: "publish fails", err: errors.New("not connected")},
+		{name: "broker hung", hung: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tg := newFakeTelegram(t)
+			store := newTestStore(t)
+			store.Add(1)
+			pool := poolConfig{Name: defaultPoolName, URL: newPoolAPI(t, http.StatusOK, blocksJSON(101, 100))}
+			n := newTestNotifier(t, config{Pools: []poolConfig{pool}}, store)
+			n.bot = tg.bot(t)
+			n.lastBlocks.setLastBlock(pool.Name, block{height: 100})
+			client := newFakeMQTTClient(tt.err, tt.hung)
+			n.mqtt = &mqttPublisher{client: client, prefix: "home/p2pool", qos: 1}
+
+			if err := n.tryNotifyIfNewBlock(context.Background(), pool); err != nil {
+				t.Fatal(err)
+			}
+			if texts := tg.sentTexts(); len(texts) != 1 || !strings.Contains(texts[0], "Высота: 101") {
+				t.Errorf("sent %q, want a notification about block 101", texts)
+			}
+
+			if tt.hung {
+				close(client.release)
+			}
+			select {
+			case <-client.done:
+			case <-time.After(time.Second):
+				t.Fatal("block not published")
+			}
+
+			msgs := client.messages()
+			if len(msgs) != 1 {
+				t.Fatalf("%d messages published, want 1", len(msgs))
+			}
+			msg := msgs[0]
+			if msg.topic != "home/p2pool/block" || msg.qos != 1 || !msg.retained {
+				t.Errorf("published to %s, QoS %d, retained %v; want home/p2pool/block, QoS 1, retained", msg.topic, msg.qos, msg.retained)
+			}
+			var b mqttBlock
+			if err := json.Unmarshal(msg.payload, &b); err != nil {
+				t.Fatal(err)
+			}
+			if b.Pool != defaultPoolName || b.Height != 101 || b.Effort <= 0 {
+				t.Errorf("published %+v, want block 101 of %s with its effort", b, defaultPoolName)
+			}
+		})
+	}
+}
+
+func TestMQTTConfigValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		conf mqttConfig
+		want []string
+	}{
+		{name: "valid", conf: mqttConfig{Broker: "tcp://localhost:1883", QoS: 2, StatsInterval: "1m"}},
+		{name: "no broker", conf: mqttConfig{}, want: []string{"Broker"}},
+		{name: "bad QoS", conf: mqttConfig{Broker: "tcp://b:1883", QoS: 3}, want: []string{"QoS"}},
+		{name: "bad interval", conf: mqttConfig{Broker: "tcp://b:1883", StatsInterval: "-1m"}, want: []string{"StatsInterval"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := tt.conf.validate()
+			if len(problems) != len(tt.want) {
+				t.Fatalf("problems = %v, want %d", problems, len(tt.want))
+			}
+			for _, field := range tt.want {
+				if !hasProblem(problems, field) {
+					t.Errorf("no problem with %s in %v", field, problems)
+				}
+			}
+		})
+	}
+}
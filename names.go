@@ -0,0 +1,126 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// chatName is how a chat shows up in /subscribers: @username where there
+// is one, else the name of the user or the title of the group.
+func chatName(chat *tgbotapi.Chat) string {
+	switch {
+	case chat.UserName != "":
+		return "@" + chat.UserName
+	case chat.Title != "":
+		return chat.Title
+	default:
+		return strings.TrimSpace(chat.FirstName + " " + chat.LastName)
+	}
+}
+
+// rememberName keeps the name of a chat we heard from for /subscribers
+// find, unless the chat opted out with /hidename.
+func (n *Notifier) rememberName(chat *tgbotapi.Chat) {
+	name := chatName(chat)
+	var known bool
+	n.state.view(func(st state) {
+		known = st.HiddenNames[chat.ID] || st.Names[chat.ID] == name
+	})
+	if known || name == "" {
+		return
+	}
+
+	err := n.state.update(func(st *state) {
+		if st.Names == nil {
+			st.Names = make(map[int64]string)
+		}
+		st.Names[chat.ID] = name
+	})
+	if err != nil {
+		log.Printf("error: save name of %s: %s", chatRef(chat.ID), err.Error())
+	}
+}
+
+// subscriberInfo is what /subscribers shows about a chat.
+type subscriberInfo struct {
+	name   string
+	hidden bool
+	since  time.Time
+	status string
+}
+
+func (n *Notifier) subscriberInfo(id int64) subscriberInfo {
+	var info subscriberInfo
+	n.state.view(func(st state) {
+		info.name = st.Names[id]
+		info.hidden = st.HiddenNames[id]
+		info.since = st.Witnessed[id].Since
+		switch failures := st.DeliveryFailures[id]; {
+		case failures > 0:
+			info.status = "ошибок доставки подряд: " + strconv.Itoa(failures)
+		case st.SkipNext[id]:
+			info.status = "пропустит следующий блок"
+		default:
+			info.status = "активен"
+		}
+	})
+	return info
+}
+
+// subscriberQuery returns a match for Storer.Find that reports the chats
+// whose ID is query or whose name contains it, ignoring case. Chats that hid
+// their name never match.
+func (n *Notifier) subscriberQuery(query string) func(id int64) bool {
+	query = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(query), "@"))
+	if query == "" {
+		return func(int64) bool { return false }
+	}
+
+	names := make(map[int64]string)
+	n.state.view(func(st state) {
+		for id, name := range st.Names {
+			if !st.HiddenNames[id] {
+				names[id] = strings.ToLower(strings.TrimPrefix(name, "@"))
+			}
+		}
+	})
+	return func(id int64) bool {
+		name, ok := names[id]
+		if !ok {
+			return false
+		}
+		return strconv.FormatInt(id, 10) == query || (name != "" && strings.Contains(name, query))
+	}
+}
+
+// cmdHideName implements /hidename: the bot forgets the name of the chat
+// and stops keeping it, or keeps it again when sent a second time.
+func (r *commandRouter) cmdHideName(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	var hidden bool
+	err := r.notifier.state.update(func(st *state) {
+		hidden = !st.HiddenNames[msg.Chat.ID]
+		if !hidden {
+			delete(st.HiddenNames, msg.Chat.ID)
+			return
+		}
+		if st.HiddenNames == nil {
+			st.HiddenNames = make(map[int64]bool)
+		}
+		st.HiddenNames[msg.Chat.ID] = true
+		delete(st.Names, msg.Chat.ID)
+	})
+	if err != nil {
+		log.Printf("error: save hidden name of %s: %s", chatRef(msg.Chat.ID), err.Error())
+		return reply(msg, "Не удалось сохранить настройку")
+	}
+
+	if hidden {
+		return reply(msg, "Бот больше не хранит имя этого чата, администраторы не найдут его в поиске. Вернуть: /hidename")
+	}
+	r.notifier.rememberName(msg.Chat)
+	return reply(msg, "Бот снова хранит имя этого чата")
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestChatName(t *testing.T) {
+	tests := []struct {
+		chat tgbotapi.Chat
+		want string
+	}{
+		{tgbotapi.Chat{UserName: "alice", FirstName: "Alice"}, "@alice"},
+		{tgbotapi.Chat{FirstName: "Bob", LastName: "Smith"}, "Bob Smith"},
+		{tgbotapi.Chat{FirstName: "Carol"}, "Carol"},
+		{tgbotapi.Chat{Title: "Miners"}, "Miners"},
+		{tgbotapi.Chat{}, ""},
+	}
+	for _, tt := range tests {
+		if got := chatName(&tt.chat); got != tt.want {
+			t.Errorf("chatName(%+v) = %q, want %q", tt.chat, got, tt.want)
+		}
+	}
+}
+
+func TestFindSubscribers(t *testing.T) {
+	n := newTestNotifier(t, config{}, newTestStore(t))
+	n.rememberName(&tgbotapi.Chat{ID: 1, UserName: "MoneroMiner"})
+	n.rememberName(&tgbotapi.Chat{ID: 2, Title: "Mining group"})
+	n.rememberName(&tgbotapi.Chat{ID: 3, UserName: "hidden_miner"})
+	n.rememberName(&tgbotapi.Chat{ID: 4, UserName: "unsubscribed_miner"})
+	n.state.update(func(st *state) {
+		st.HiddenNames = map[int64]bool{3: true}
+		delete(st.Names, 3)
+	})
+	for _, id := range []int64{1, 2, 3} {
+		if err := n.store.Add(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []int64
+	}{
+		{"miner", []int64{1}},
+		{"@moneromin", []int64{1}},
+		{"MINING", []int64{2}},
+		{"min", []int64{1, 2}},
+		{"2", []int64{2}},
+		{"3", nil},
+		{"hidden", nil},
+		{"unsubscribed", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		got, err := n.store.Find(n.subscriberQuery(tt.query))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Find(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestRememberNameRespectsHideName(t *testing.T) {
+	n := newTestNotifier(t, config{}, newTestStore(t))
+	n.state.update(func(st *state) { st.HiddenNames = map[int64]bool{1: true} })
+
+	n.rememberName(&tgbotapi.Chat{ID: 1, UserName: "alice"})
+	n.state.view(func(st state) {
+		if name, ok := st.Names[1]; ok {
+			t.Errorf("kept name %q of a chat that hid it", name)
+		}
+	})
+}
+
+func TestFormatSubscribers(t *testing.T) {
+	n := newTestNotifier(t, config{}, newTestStore(t))
+	since := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	n.state.update(func(st *state) {
+		st.ChatTypes = map[int64]string{1: "private", 2: "group", 3: "private"}
+		st.Names = map[int64]string{1: "@alice", 2: "Miners"}
+		st.HiddenNames = map[int64]bool{3: true}
+		st.Witnessed = map[int64]witnessStats{1: {Since: since}}
+		st.DeliveryFailures = map[int64]int{2: 2}
+	})
+	r := &commandRouter{notifier: n}
+
+	got := strings.Split(r.formatSubscribers([]int64{1, 2, 3}), "\n")
+	want := []string{
+		"1 @alice (private), с 2026-03-01, активен",
+		"2 Miners (group), ошибок доставки подряд: 2",
+		"3 (имя скрыто) (private), активен",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("formatSubscribers =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestFindSubscribersSplitsLongResults(t *testing.T) {
+	const subscribers = 200
+
+	tg := newFakeTelegram(t)
+	store := newTestStore(t)
+	n := newTestNotifier(t, config{}, store)
+	n.bot = tg.bot(t)
+	for id := int64(1); id <= subscribers; id++ {
+		if err := store.Add(id); err != nil {
+			t.Fatal(err)
+		}
+		n.rememberName(&tgbotapi.Chat{ID: id, Title: "Monero mining group " + strings.Repeat("x", 40)})
+		n.rememberChatType(id, "group")
+	}
+	r := &commandRouter{bot: n.bot, store: store, notifier: n}
+
+	resp := r.cmdSubscribers(command(1, "/subscribers find mining"))
+	parts := append(tg.sentTexts(), resp.Text)
+	if len(parts) < 2 {
+		t.Fatalf("got %d messages, want the results split", len(parts))
+	}
+	lines := 0
+	for _, part := range parts {
+		if length := len([]rune(part)); length > maxMessageLength {
+			t.Errorf("message of %d characters, over the limit of %d", length, maxMessageLength)
+		}
+		for _, line := range strings.Split(part, "\n") {
+			if line != "" {
+				lines++
+			}
+		}
+	}
+	// One line per subscriber plus the count.
+	if lines != subscribers+1 {
+		t.Errorf("%d lines in the results, want %d", lines, subscribers+1)
+	}
+}
@@ -0,0 +1,646 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Notifier polls the pool API and notifies subscribers about new blocks.
+type Notifier struct {
+	bot        *tgbotapi.BotAPI
+	store      Storer
+	pools      []poolConfig
+	timeFormat string
+	template   *template.Template
+	usage      *usageStats
+	state      *stateStore
+	admins     map[int64]bool
+
+	// skipFirstBlock records the first block of a pool without saved state
+	// instead of announcing it.
+	skipFirstBlock bool
+	// reseedMargin is how far the API tip may fall behind the stored
+	// block before the tip replaces it.
+	reseedMargin int
+	// forceAnnounce marks pools reset by /reset, whose first block is
+	// announced anyway. Guarded by mu.
+	forceAnnounce map[string]bool
+
+	// filters pick the blocks to announce, see passFilters.
+	filters []notificationFilter
+
+	// staleAfter is the block age past which admins are told the API may
+	// be stuck, zero to never. staleAlerted tracks the pools they were
+	// told about and is guarded by mu.
+	staleAfter   time.Duration
+	staleAlerted map[string]bool
+	// deferredMaxAge is the age past which deferred blocks aren't
+	// announced on resume.
+	deferredMaxAge time.Duration
+
+	// metricsTextfile is rewritten after every poll if set.
+	metricsTextfile string
+
+	ackButtons bool
+	// channelOnly sends notifications to channels only, bypassing the
+	// subscribers, see ChannelOnlyMode.
+	channelOnly bool
+	channels    []int64
+
+	// seenButtons puts a Seen counter under notifications in channels.
+	seenButtons bool
+	// linkPreviews lets Telegram expand links in notifications.
+	linkPreviews bool
+
+	stats runStats
+	tuner *intervalTuner
+	// jitter randomizes the polling times, see DisableJitter.
+	jitter bool
+
+	// audit records admin and destructive actions, see /audit.
+	audit *auditLog
+
+	// milestones are the found block counts announced to everyone, see
+	// MilestoneHeights.
+	milestones milestoneSet
+
+	// maintenance are the planned MaintenanceWindows; /maintenance adds
+	// one to the state.
+	maintenance []maintenanceWindow
+
+	// pruneAfter is the number of failed deliveries in a row that drop a
+	// subscriber.
+	pruneAfter int
+
+	webhooks *webhookDispatcher
+	monero   *MoneroRPCClient
+	batcher  *batchingNotifier
+
+	// mqtt is nil unless the MQTT section is configured.
+	mqtt *mqttPublisher
+
+	// sends paces the messages of broadcasts and pushes.
+	sends *sendQueue
+
+	lastBlocks *blockTracker
+	history    map[string]*ringBuffer
+	latency    latencyTracker
+
+	startedAt time.Time
+	// initialDelay is how long after startedAt the first broadcast may
+	// go out, see InitialBroadcastDelay.
+	initialDelay time.Duration
+
+	// updates is set by main once the bot receives updates.
+	updates *updateQueue
+
+	mu sync.Mutex
+	// fetches has an entry for every pool, see poolFetch.
+	fetches map[string]*poolFetch
+}
+
+// poolFetch is how polling one pool went lately. Its fields are guarded by
+// Notifier.mu.
+type poolFetch struct {
+	lastSuccess     time.Time
+	lastBlockSeenAt time.Time
+	lastError       error
+}
+
+func newNotifier(bot *tgbotapi.BotAPI, store Storer, conf config, usage *usageStats, st *stateStore, webhooks *webhookDispatcher, monero *MoneroRPCClient) *Notifier {
+	pools := conf.pools()
+	history := make(map[string]*ringBuffer, len(pools))
+	fetches := make(map[string]*poolFetch, len(pools))
+	for _, pool := range pools {
+		history[pool.Name] = newRingBuffer(historySize)
+		fetches[pool.Name] = &poolFetch{}
+	}
+
+	n := &Notifier{
+		bot:        bot,
+		store:      store,
+		pools:      pools,
+		timeFormat: conf.timeFormat(),
+		usage:      usage,
+		state:      st,
+		webhooks:   webhooks,
+		monero:     monero,
+		sends:      newSendQueue(sendInterval),
+		lastBlocks: newBlockTracker(),
+		history:    history,
+		fetches:    fetches,
+		admins:     make(map[int64]bool, len(conf.AdminIDs)),
+	}
+	for _, id := range conf.AdminIDs {
+		n.admins[id] = true
+	}
+	n.skipFirstBlock = conf.skipFirstBlockOnStartup()
+	n.startedAt = time.Now()
+	n.initialDelay, _ = time.ParseDuration(conf.InitialBroadcastDelay)
+	n.forceAnnounce = make(map[string]bool)
+	n.reseedMargin = conf.reseedMargin()
+	n.filters = conf.notificationFilters()
+	n.staleAfter, _ = time.ParseDuration(conf.StaleBlockAfter)
+	n.staleAlerted = make(map[string]bool)
+	n.deferredMaxAge = conf.deferredMaxAge()
+	n.metricsTextfile = conf.MetricsTextfilePath
+	n.ackButtons = !conf.DisableAckButtons
+	n.seenButtons = conf.SeenButtons
+	n.channelOnly = conf.ChannelOnlyMode
+	n.channels = conf.ChannelIDs
+	n.linkPreviews = conf.LinkPreviews
+	notifyDuration, _ := time.ParseDuration(conf.NotifyDuration)
+	n.tuner = newIntervalTuner(notifyDuration, conf)
+	n.jitter = !conf.DisableJitter
+	n.pruneAfter = conf.pruneAfterFailures()
+	n.audit = newAuditLog(auditPath(conf))
+	if d, ok := store.(degradable); ok {
+		d.onReadOnlyChange(n.alertReadOnly)
+	}
+	n.milestones = newMilestoneSet(conf.milestoneHeights())
+	n.maintenance = conf.maintenanceWindows()
+
+	st.view(func(st state) {
+		for pool, b := range st.LastBlocks {
+			n.lastBlocks.setLastBlock(pool, b.block())
+		}
+	})
+
+	if conf.MessageTemplate != "" {
+		// Checked by config.validate.
+		n.template, _ = parseMessageTemplate(conf.MessageTemplate)
+	}
+
+	if window := conf.batchWindow(); window > 0 {
+		n.batcher = newBatchingNotifier(n, window)
+	}
+
+	return n
+}
+
+// worker polls every configured pool in its own goroutine until ctx is done.
+func (n *Notifier) worker(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, pool := range n.pools {
+		wg.Add(1)
+		go func(pool poolConfig) {
+			defer wg.Done()
+			n.poolWorker(ctx, pool)
+		}(pool)
+	}
+	wg.Wait()
+}
+
+func (n *Notifier) poolWorker(ctx context.Context, pool poolConfig) {
+	var first time.Duration
+	if n.jitter {
+		// Spread out the first polls of bots restarted at the same time.
+		first = randomDuration(n.tuner.configured)
+	}
+	timer := time.NewTimer(first)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		pollCtx := withPollLogger(ctx, pool.Name)
+		started := time.Now()
+		err := n.tryNotifyIfNewBlock(pollCtx, pool)
+		n.stats.recordPoll(time.Since(started))
+		if err != nil {
+			logger(pollCtx).Error("poll failed", "err", err)
+		}
+		if n.metricsTextfile != "" {
+			n.writeMetricsTextfile(n.metricsTextfile)
+		}
+		interval := n.tuner.interval(pool)
+		if n.jitter {
+			interval = jittered(interval)
+		}
+		timer.Reset(interval)
+	}
+}
+
+func (n *Notifier) tryNotifyIfNewBlock(ctx context.Context, pool poolConfig) error {
+	blocks, err := fetchRecentBlocksContext(ctx, pool.URL)
+	if err != nil {
+		n.recordFetch(pool.Name, err)
+		return err
+	}
+	lastBlock := blocks[0]
+	n.recordFetch(pool.Name, nil)
+	logger(ctx).Debug("fetched last block", "height", lastBlock.height)
+	n.checkStale(ctx, pool, lastBlock)
+
+	previous := n.lastBlocks.getLastBlock(pool.Name)
+	if lastBlock.height < previous.height {
+		return n.handleTipBehind(ctx, pool, previous, lastBlock)
+	}
+
+	if lastBlock.height == previous.height {
+		// Nothing new, but the history may still lack the recent blocks,
+		// e.g. right after a restart.
+		n.recordHistory(pool.Name, blocks)
+		return nil
+	}
+
+	lastBlock = n.checkReward(pool, lastBlock)
+	blocks[0] = lastBlock
+	// Under mu, so a snapshot sees the block along with when it was seen.
+	n.mu.Lock()
+	n.lastBlocks.setLastBlock(pool.Name, lastBlock)
+	n.fetches[pool.Name].lastBlockSeenAt = time.Now()
+	n.mu.Unlock()
+	n.saveLastBlock(ctx, pool, lastBlock)
+	n.recordHistory(pool.Name, blocks)
+	logger(ctx).Info("new block", "height", lastBlock.height)
+	if len(pool.CrossCheckURLs) > 0 {
+		go n.checkChainSplit(ctx, pool, lastBlock)
+	}
+
+	// Seeding: the first block of a pool without saved state may be
+	// long gone, so it's only recorded. Nothing below may run for it,
+	// be it a message, a webhook or an MQTT publish.
+	if previous.height == 0 && n.skipFirstBlock && !n.takeForceAnnounce(pool.Name) {
+		logger(ctx).Info("first block since startup without saved state, not announced", "height", lastBlock.height)
+		return nil
+	}
+
+	if previous.height != 0 {
+		n.stats.blocksFound.Add(1)
+	}
+	if n.mqtt != nil {
+		go n.mqtt.publishBlock(pool, lastBlock)
+	}
+
+	if !passFilters(n.filters, lastBlock) {
+		logger(ctx).Info("block filtered out, not announced", "height", lastBlock.height)
+		return nil
+	}
+
+	deferred, err := n.deferIfPaused(ctx, pool, lastBlock)
+	if err != nil {
+		return err
+	}
+	if deferred {
+		logger(ctx).Info("notifications paused, block deferred", "height", lastBlock.height)
+		return nil
+	}
+
+	if err := n.announce(ctx, pool, lastBlock); err != nil {
+		return err
+	}
+	go n.checkMilestone(ctx, pool)
+	return nil
+}
+
+// handleTipBehind deals with an API tip below the stored last block, after a
+// reorg or an API rollback. A small gap is taken for a lagging API and the
+// stored block is kept. Past reseedMargin the stored block would silence the
+// pool for good, so the tip replaces it, without an announcement: the block
+// is no news.
+func (n *Notifier) handleTipBehind(ctx context.Context, pool poolConfig, stored, tip block) error {
+	if stored.height-tip.height < n.reseedMargin {
+		logger(ctx).Debug("API tip behind stored block, waiting", "tip", tip.height, "stored", stored.height)
+		return nil
+	}
+
+	logger(ctx).Warn("API tip far behind stored block, re-seeding", "tip", tip.height, "stored", stored.height)
+	n.mu.Lock()
+	n.lastBlocks.setLastBlock(pool.Name, tip)
+	n.mu.Unlock()
+	n.saveLastBlock(ctx, pool, tip)
+	return nil
+}
+
+// announce tells webhooks and subscribers about a found block.
+func (n *Notifier) announce(ctx context.Context, pool poolConfig, b block) error {
+	if n.webhooks != nil {
+		go n.deliverWebhooks(ctx, pool, b)
+	}
+
+	if n.batcher != nil {
+		n.batcher.add(ctx, pool, b)
+		return nil
+	}
+
+	return n.broadcast(ctx, pool, []block{b}, func(id int64) string {
+		return n.messageFor(id, pool, b)
+	})
+}
+
+// broadcast sends every subscriber the text for it about blocks of pool,
+// oldest first, and records who got it and how long after the last block
+// was found. A chat that can't be reached is skipped, and dropped once it
+// failed pruneAfter times in a row. Every log line of a broadcast carries
+// the same ID, see nextBroadcastID.
+func (n *Notifier) broadcast(ctx context.Context, pool poolConfig, blocks []block, text func(chatID int64) string) error {
+	b := blocks[len(blocks)-1]
+	height := b.height
+	l := logger(ctx).With("broadcast", nextBroadcastID(), "height", height)
+
+	if err := n.waitWarmup(ctx, l); err != nil {
+		return err
+	}
+
+	ids, err := n.recipients()
+	if err != nil {
+		l.Error("list subscribers", "err", err)
+		return err
+	}
+	ids, priority := n.sendOrder(ids)
+	skip := n.skipsNext(ids)
+	l.Info("broadcast started", "subscribers", len(ids))
+
+	sent, failed := 0, 0
+	sentByPriority := make(map[sendPriority]int)
+	delivered := make([]int64, 0, len(ids))
+	var skipped []int64
+	var latency deliveryLatency
+	defer func() {
+		if sent > 0 {
+			n.latency.record(latency)
+			n.recordDelivered(ctx, pool, height, delivered)
+			n.recordWitnessed(delivered, blocks)
+		}
+		n.clearSkips(skipped)
+		l.Info("broadcast done", "sent", sent, "failed", failed, "subscribers", len(ids), "latency", latency.last,
+			priorityOperator.String(), sentByPriority[priorityOperator], priorityBulk.String(), sentByPriority[priorityBulk])
+	}()
+
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if skip[id] {
+			l.Info("skipped on request", "chat", chatRef(id))
+			skipped = append(skipped, id)
+			continue
+		}
+
+		msg := tgbotapi.NewMessage(id, text(id))
+		msg.DisableWebPagePreview = !n.linkPreviews
+		if keyboard, ok := n.notificationKeyboard(id, height); ok {
+			msg.ReplyMarkup = keyboard
+		}
+		sentMsg, err := n.send(ctx, msg)
+		if err != nil {
+			// One chat failing mustn't keep the block from the rest.
+			n.stats.notificationErrors.Add(1)
+			failed++
+			l.Error("send failed", "chat", chatRef(id), "err", err)
+			if !isTerminalSendError(err) {
+				continue
+			}
+			if failures := n.recordDeliveryFailure(id); failures >= n.pruneAfter {
+				if err := n.dropUnreachable(id, failures); err != nil {
+					l.Error("prune", "chat", chatRef(id), "err", err)
+				}
+			}
+			continue
+		}
+		n.stats.notificationsSent.Add(1)
+		n.stats.sentByPriority[priority[id]].Add(1)
+		n.resetDeliveryFailures(id)
+		l.Info("sent", "chat", chatRef(id))
+		// The chat ID changes if the group was migrated meanwhile.
+		chatID := id
+		if sentMsg.Chat != nil {
+			chatID = sentMsg.Chat.ID
+		}
+		if err := n.store.RecordSentMessage(int64(sentMsg.MessageID), chatID, height); err != nil {
+			l.Error("record sent message", "chat", chatRef(id), "err", err)
+		}
+		latency.last = time.Since(b.ts)
+		if sent == 0 {
+			latency.first = latency.last
+		}
+		sent++
+		sentByPriority[priority[id]]++
+		delivered = append(delivered, id)
+		n.usage.countNotification()
+	}
+
+	return nil
+}
+
+var broadcastCounter atomic.Uint64
+
+// nextBroadcastID returns a short ID to correlate the log lines of one
+// broadcast. It is unique for the lifetime of the process.
+func nextBroadcastID() string {
+	return "b" + strconv.FormatUint(broadcastCounter.Add(1), 10)
+}
+
+// recordDelivered remembers that ids got the notification about the block
+// at height, for /missed.
+func (n *Notifier) recordDelivered(ctx context.Context, pool poolConfig, height int, ids []int64) {
+	err := n.state.update(func(st *state) {
+		if st.Delivered == nil {
+			st.Delivered = make(map[string]map[int64]int)
+		}
+		if st.Delivered[pool.Name] == nil {
+			st.Delivered[pool.Name] = make(map[int64]int)
+		}
+		for _, id := range ids {
+			st.Delivered[pool.Name][id] = height
+		}
+	})
+	if err != nil {
+		logger(ctx).Error("save delivered heights", "err", err)
+	}
+}
+
+func (n *Notifier) saveLastBlock(ctx context.Context, pool poolConfig, b block) {
+	err := n.state.update(func(st *state) {
+		if st.LastBlocks == nil {
+			st.LastBlocks = make(map[string]savedBlock)
+		}
+		st.LastBlocks[pool.Name] = newSavedBlock(pool.Name, b)
+	})
+	if err != nil {
+		logger(ctx).Error("save last block", "err", err)
+	}
+}
+
+// maxDeferred bounds the blocks kept during a pause, the oldest are dropped
+// first.
+const maxDeferred = 100
+
+func (n *Notifier) deferIfPaused(ctx context.Context, pool poolConfig, b block) (bool, error) {
+	var deferred bool
+	dropped := 0
+	err := n.state.update(func(st *state) {
+		if st.Paused {
+			st.Deferred = append(st.Deferred, newSavedBlock(pool.Name, b))
+			if len(st.Deferred) > maxDeferred {
+				dropped = len(st.Deferred) - maxDeferred
+				st.Deferred = st.Deferred[dropped:]
+			}
+			deferred = true
+		}
+	})
+	if dropped > 0 {
+		logger(ctx).Warn("too many deferred blocks, dropped the oldest", "dropped", dropped, "kept", maxDeferred)
+	}
+	return deferred, err
+}
+
+func (n *Notifier) paused() bool {
+	var paused bool
+	n.state.view(func(st state) {
+		paused = st.Paused
+	})
+	return paused
+}
+
+// pause stops block notifications. Polling carries on and found blocks are
+// kept until resume.
+func (n *Notifier) pause() error {
+	return n.state.update(func(st *state) {
+		st.Paused = true
+	})
+}
+
+// resume lifts a pause and announces the blocks deferred meanwhile in the
+// background. Blocks older than deferredMaxAge are dropped, nobody wants to
+// hear about them that late. It returns how many blocks are about to be
+// announced and how many were dropped.
+func (n *Notifier) resume(ctx context.Context) (announced, stale int, err error) {
+	var deferred []savedBlock
+	err = n.state.update(func(st *state) {
+		st.Paused = false
+		deferred = st.Deferred
+		st.Deferred = nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	cutoff := time.Now().Add(-n.deferredMaxAge)
+	fresh := make([]savedBlock, 0, len(deferred))
+	for _, d := range deferred {
+		if d.Timestamp.Before(cutoff) {
+			stale++
+			continue
+		}
+		fresh = append(fresh, d)
+	}
+	if stale > 0 {
+		logger(ctx).Warn("dropped stale deferred blocks", "dropped", stale, "max_age", n.deferredMaxAge)
+	}
+
+	go func() {
+		for _, d := range fresh {
+			pool, ok := n.pool(d.Pool)
+			if !ok {
+				logger(ctx).Warn("deferred block of unknown pool skipped", "pool", d.Pool, "height", d.Height)
+				continue
+			}
+			if err := n.announce(ctx, pool, d.block()); err != nil {
+				logger(ctx).Error("announce deferred block", "pool", pool.Name, "height", d.Height, "err", err)
+			}
+		}
+	}()
+
+	return len(fresh), stale, nil
+}
+
+func (n *Notifier) pool(name string) (poolConfig, bool) {
+	for _, pool := range n.pools {
+		if pool.Name == name {
+			return pool, true
+		}
+	}
+	return poolConfig{}, false
+}
+
+func (n *Notifier) deliverWebhooks(ctx context.Context, pool poolConfig, b block) {
+	var delivered bool
+	n.state.view(func(st state) {
+		delivered = st.WebhookHeights[pool.Name] == b.height
+	})
+	if delivered {
+		logger(ctx).Info("block already delivered to webhooks, skipped", "height", b.height)
+		return
+	}
+
+	payload := webhookPayload{Pool: pool.Name, Height: b.height, Timestamp: b.ts}
+	for _, err := range n.webhooks.deliver(ctx, payload) {
+		logger(ctx).Error("deliver webhook", "err", err)
+	}
+
+	err := n.state.update(func(st *state) {
+		if st.WebhookHeights == nil {
+			st.WebhookHeights = make(map[string]int)
+		}
+		st.WebhookHeights[pool.Name] = b.height
+	})
+	if err != nil {
+		logger(ctx).Error("save webhook height", "err", err)
+	}
+}
+
+// sendMigrating delivers msg, following the chat to its new ID if Telegram
+// reports that the group was migrated to a supergroup.
+func (n *Notifier) sendMigrating(ctx context.Context, msg tgbotapi.MessageConfig) (tgbotapi.Message, error) {
+	sent, err := n.bot.Send(msg)
+
+	var tgErr *tgbotapi.Error
+	if errors.As(err, &tgErr) && tgErr.MigrateToChatID != 0 {
+		if err := n.store.Replace(msg.ChatID, tgErr.MigrateToChatID); err != nil {
+			return sent, err
+		}
+		logger(ctx).Info("chat migrated", "chat", chatRef(msg.ChatID), "to", chatRef(tgErr.MigrateToChatID))
+
+		msg.ChatID = tgErr.MigrateToChatID
+		return n.bot.Send(msg)
+	}
+
+	return sent, err
+}
+
+func (n *Notifier) blockMessage(pool poolConfig, b block) string {
+	text := fmt.Sprintf("Блок найден! Высота: %d, время: %s", b.height, b.ts.Format(n.timeFormat))
+	if len(n.pools) > 1 {
+		text = fmt.Sprintf("Блок найден пулом %s! Высота: %d, время: %s", pool.Name, b.height, b.ts.Format(n.timeFormat))
+	}
+	if b.effort != 0 {
+		text += ", усилие: " + formatEffort(b.effort)
+	}
+	if b.reward != 0 {
+		text += ", награда: " + formatXMR(b.reward)
+	}
+	return text
+}
+
+// recordFetch keeps the outcome of a poll of pool. A failed fetch never
+// clears the time of the last successful one, so health can be judged by
+// its age.
+func (n *Notifier) recordFetch(pool string, err error) {
+	n.stats.recordFetch(err)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, ok := n.fetches[pool]
+	if !ok {
+		return
+	}
+	f.lastError = err
+	if err == nil {
+		f.lastSuccess = time.Now()
+	}
+}
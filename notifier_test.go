@@ -0,0 +1,402 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBroadcastPrunesAfterFailures(t *testing.T) {
+	const dead = 2
+
+	tests := []struct {
+		name       string
+		pruneAfter int
+		broadcasts int
+		wantPruned bool
+	}{
+		{"first failure, default", 0, 1, true},
+		{"below threshold", 3, 2, false},
+		{"at threshold", 3, 3, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tg := newFakeTelegram(t)
+			tg.failFor(dead)
+			store := newTestStore(t)
+			for _, id := range []int64{1, dead, 3} {
+				if err := store.Add(id); err != nil {
+					t.Fatal(err)
+				}
+			}
+			n := newTestNotifier(t, config{PruneAfterFailures: tt.pruneAfter}, store)
+			n.bot = tg.bot(t)
+
+			for i := 0; i < tt.broadcasts; i++ {
+				blocks := []block{{height: 100 + i, ts: time.Now()}}
+				err := n.broadcast(context.Background(), poolConfig{Name: defaultPoolName}, blocks, func(int64) string { return "block" })
+				if err != nil {
+					t.Fatalf("broadcast %d: %s", i, err)
+				}
+			}
+
+			// The failing chat in the middle doesn't stop the broadcast.
+			sent := tg.sentTo()
+			for _, id := range []int64{1, 3} {
+				if got := countOf(sent, id); got != tt.broadcasts {
+					t.Errorf("chat %d got %d messages, want %d", id, got, tt.broadcasts)
+				}
+			}
+
+			ids, _ := store.Subscribers()
+			if pruned := !slices.Contains(ids, dead); pruned != tt.wantPruned {
+				t.Errorf("pruned = %v, want %v", pruned, tt.wantPruned)
+			}
+			wantFailures := tt.broadcasts
+			if tt.wantPruned {
+				wantFailures = 0
+			}
+			if got := n.deliveryFailures(dead); got != wantFailures {
+				t.Errorf("failures = %d, want %d", got, wantFailures)
+			}
+		})
+	}
+}
+
+func TestBroadcastResetsFailuresOnSuccess(t *testing.T) {
+	tg := newFakeTelegram(t)
+	store := newTestStore(t)
+	store.Add(1)
+	n := newTestNotifier(t, config{PruneAfterFailures: 3}, store)
+	n.bot = tg.bot(t)
+	n.recordDeliveryFailure(1)
+	n.recordDeliveryFailure(1)
+
+	blocks := []block{{height: 100, ts: time.Now()}}
+	if err := n.broadcast(context.Background(), poolConfig{Name: defaultPoolName}, blocks, func(int64) string { return "block" }); err != nil {
+		t.Fatal(err)
+	}
+	if got := n.deliveryFailures(1); got != 0 {
+		t.Errorf("failures = %d, want 0", got)
+	}
+}
+
+func TestBroadcastLinkPreviews(t *testing.T) {
+	for _, previews := range []bool{false, true} {
+		t.Run(fmt.Sprint("LinkPreviews=", previews), func(t *testing.T) {
+			tg := newFakeTelegram(t)
+			store := newTestStore(t)
+			store.Add(1)
+			n := newTestNotifier(t, config{LinkPreviews: previews}, store)
+			n.bot = tg.bot(t)
+
+			blocks := []block{{height: 100, ts: time.Now()}}
+			if err := n.broadcast(context.Background(), poolConfig{Name: defaultPoolName}, blocks, func(int64) string { return "https://p2pool.io/mini/" }); err != nil {
+				t.Fatal(err)
+			}
+			if got := tg.sentPreviews(); !slices.Equal(got, []bool{previews}) {
+				t.Errorf("previews = %v, want %v", got, []bool{previews})
+			}
+		})
+	}
+}
+
+func countOf(ids []int64, id int64) int {
+	count := 0
+	for _, v := range ids {
+		if v == id {
+			count++
+		}
+	}
+	return count
+}
+
+func TestBlockWithoutTimestampIsAnnounced(t *testing.T) {
+	tests := []struct {
+		name string
+		tip  string
+	}{
+		{"missing", `{"height":101,"hash":"ab"}`},
+		{"zero", `{"height":101,"hash":"ab","ts":0}`},
+		{"not a number", `{"height":101,"hash":"ab","ts":"soon"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLogs(t)
+			tg := newFakeTelegram(t)
+			store := newTestStore(t)
+			store.Add(1)
+			pool := poolConfig{Name: defaultPoolName, URL: newPoolAPI(t, http.StatusOK, "["+tt.tip+`,{"height":100,"ts":1700000000000}]`)}
+			n := newTestNotifier(t, config{Pools: []poolConfig{pool}}, store)
+			n.bot = tg.bot(t)
+			n.lastBlocks.setLastBlock(pool.Name, block{height: 100})
+
+			before := time.Now()
+			if err := n.tryNotifyIfNewBlock(context.Background(), pool); err != nil {
+				t.Fatal(err)
+			}
+
+			texts := tg.sentTexts()
+			if len(texts) != 1 || !strings.Contains(texts[0], "Высота: 101") {
+				t.Errorf("sent %q, want a notification about block 101", texts)
+			}
+			if ts := n.lastBlocks.getLastBlock(pool.Name).ts; ts.Before(before) {
+				t.Errorf("block time = %s, want the current time", ts)
+			}
+			if !strings.Contains(logs.String(), "warning: 1 of 2 blocks") {
+				t.Errorf("no warning about the timestamp logged:\n%s", logs)
+			}
+		})
+	}
+}
+
+func TestTipBehindStoredBlock(t *testing.T) {
+	const stored = 200
+
+	tests := []struct {
+		name       string
+		tip        int
+		wantReseed bool
+	}{
+		{"within margin", stored - defaultReseedMargin + 1, false},
+		{"at margin", stored - defaultReseedMargin, true},
+		{"far behind", 100, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body atomic.Value
+			body.Store(blocksJSON(tt.tip, tt.tip-1))
+			api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, body.Load())
+			}))
+			defer api.Close()
+
+			tg := newFakeTelegram(t)
+			store := newTestStore(t)
+			store.Add(1)
+			pool := poolConfig{Name: defaultPoolName, URL: api.URL}
+			n := newTestNotifier(t, config{Pools: []poolConfig{pool}}, store)
+			n.bot = tg.bot(t)
+			n.lastBlocks.setLastBlock(pool.Name, block{height: stored})
+
+			if err := n.tryNotifyIfNewBlock(context.Background(), pool); err != nil {
+				t.Fatal(err)
+			}
+			if len(tg.sentTo()) != 0 {
+				t.Error("a block behind the stored one was announced")
+			}
+			wantHeight := stored
+			if tt.wantReseed {
+				wantHeight = tt.tip
+			}
+			if got := n.lastBlocks.getLastBlock(pool.Name).height; got != wantHeight {
+				t.Fatalf("stored block = %d, want %d", got, wantHeight)
+			}
+
+			// Once re-seeded, the next block past the tip is announced.
+			body.Store(blocksJSON(tt.tip+1, tt.tip))
+			if err := n.tryNotifyIfNewBlock(context.Background(), pool); err != nil {
+				t.Fatal(err)
+			}
+			if announced := len(tg.sentTo()) == 1; announced != tt.wantReseed {
+				t.Errorf("announced the next block = %v, want %v", announced, tt.wantReseed)
+			}
+		})
+	}
+}
+
+func TestSeedingSendsNothing(t *testing.T) {
+	no := false
+
+	tests := []struct {
+		name  string
+		skip  *bool
+		reset bool
+		// want are the heights every outlet gets.
+		want []int
+	}{
+		{name: "seeded", want: []int{101}},
+		{name: "seeding off", skip: &no, want: []int{100, 101}},
+		{name: "after /reset", reset: true, want: []int{100, 101}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hooked := make(chan int, 2)
+			hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var p webhookPayload
+				json.NewDecoder(r.Body).Decode(&p)
+				hooked <- p.Height
+			}))
+			defer hook.Close()
+
+			var body atomic.Value
+			body.Store(blocksJSON(100, 99))
+			api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, body.Load())
+			}))
+			defer api.Close()
+
+			tg := newFakeTelegram(t)
+			store := newTestStore(t)
+			store.Add(1)
+			pool := poolConfig{Name: defaultPoolName, URL: api.URL}
+			n := newTestNotifier(t, config{Pools: []poolConfig{pool}, SkipFirstBlockOnStartup: tt.skip}, store)
+			n.bot = tg.bot(t)
+			n.webhooks = newWebhookDispatcher([]string{hook.URL}, time.Second, 1)
+			client := newFakeMQTTClient(nil, false)
+			n.mqtt = &mqttPublisher{client: client, prefix: defaultMQTTTopicPrefix}
+			if tt.reset {
+				if err := n.resetLastBlocks(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, tip := range []int{100, 101} {
+				body.Store(blocksJSON(tip, tip-1))
+				if err := n.tryNotifyIfNewBlock(context.Background(), pool); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var texts, hooks, published []int
+			for _, text := range tg.sentTexts() {
+				for _, h := range []int{100, 101} {
+					if strings.Contains(text, fmt.Sprintf("Высота: %d", h)) {
+						texts = append(texts, h)
+					}
+				}
+			}
+			for range tt.want {
+				select {
+				case h := <-hooked:
+					hooks = append(hooks, h)
+				case <-time.After(time.Second):
+					t.Fatal("webhook not called")
+				}
+				select {
+				case <-client.done:
+				case <-time.After(time.Second):
+					t.Fatal("block not published")
+				}
+			}
+			for _, msg := range client.messages() {
+				var b mqttBlock
+				json.Unmarshal(msg.payload, &b)
+				published = append(published, b.Height)
+			}
+			slices.Sort(hooks)
+			slices.Sort(published)
+
+			for outlet, got := range map[string][]int{"Telegram": texts, "webhooks": hooks, "MQTT": published} {
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("%s got blocks %v, want %v", outlet, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDeferredBounded(t *testing.T) {
+	n := newTestNotifier(t, config{}, newTestStore(t))
+	if err := n.pause(); err != nil {
+		t.Fatal(err)
+	}
+
+	pool := poolConfig{Name: defaultPoolName}
+	for h := 1; h <= maxDeferred+5; h++ {
+		if deferred, err := n.deferIfPaused(context.Background(), pool, block{height: h, ts: time.Now()}); err != nil || !deferred {
+			t.Fatalf("deferIfPaused(%d) = %t, %v", h, deferred, err)
+		}
+	}
+
+	n.state.view(func(st state) {
+		if len(st.Deferred) != maxDeferred {
+			t.Fatalf("%d blocks deferred, want %d", len(st.Deferred), maxDeferred)
+		}
+		if first, last := st.Deferred[0].Height, st.Deferred[maxDeferred-1].Height; first != 6 || last != maxDeferred+5 {
+			t.Errorf("kept blocks %d to %d, want the latest", first, last)
+		}
+	})
+}
+
+func TestResumeDropsStaleBlocks(t *testing.T) {
+	tg := newFakeTelegram(t)
+	store := newTestStore(t)
+	store.Add(1)
+	n := newTestNotifier(t, config{DeferredMaxAge: "1h"}, store)
+	n.bot = tg.bot(t)
+	if err := n.pause(); err != nil {
+		t.Fatal(err)
+	}
+
+	pool := poolConfig{Name: defaultPoolName}
+	for _, b := range []block{
+		{height: 100, ts: time.Now().Add(-3 * time.Hour)},
+		{height: 101, ts: time.Now().Add(-2 * time.Hour)},
+		{height: 102, ts: time.Now().Add(-time.Minute)},
+	} {
+		if _, err := n.deferIfPaused(context.Background(), pool, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	announced, stale, err := n.resume(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if announced != 1 || stale != 2 {
+		t.Errorf("resume() = %d announced, %d stale, want 1 and 2", announced, stale)
+	}
+	texts := tg.waitForTexts(t, 1)
+	// Give a stale block the time to show up.
+	time.Sleep(100 * time.Millisecond)
+	if texts = tg.sentTexts(); len(texts) != 1 || !strings.Contains(texts[0], "102") {
+		t.Errorf("sent %q, want only block 102", texts)
+	}
+	if n.paused() {
+		t.Error("still paused")
+	}
+}
+
+func TestPoolWorkerStopsDuringInterval(t *testing.T) {
+	var polls atomic.Int64
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		polls.Add(1)
+		fmt.Fprint(w, blocksJSON(100))
+	}))
+	defer api.Close()
+	pool := poolConfig{Name: defaultPoolName, URL: api.URL}
+	n := newTestNotifier(t, config{Pools: []poolConfig{pool}, NotifyDuration: "1h", DisableJitter: true}, newTestStore(t))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		n.poolWorker(ctx, pool)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for polls.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("poolWorker still waiting out the interval after cancel")
+	}
+	if got := polls.Load(); got != 1 {
+		t.Errorf("%d polls, want 1", got)
+	}
+}
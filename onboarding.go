@@ -0,0 +1,168 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	welcomePrivate = "Вы успешно подписались на обновления! Теперь бот будет присылать вам сообщение с каждым найденным блоком пулом https://p2pool.io/mini/#pool c:\n\n" +
+		"/pools — отслеживаемые пулы\n" +
+		"/luck — удача пула\n" +
+		"/preview — как выглядит уведомление\n" +
+		"/template — свой формат уведомлений\n" +
+		"/hidename — не хранить имя этого чата\n" +
+		"/unsubscribe — отписаться"
+	welcomeGroup = "Группа подписана на обновления! Сообщение о каждом найденном блоке пулом https://p2pool.io/mini/#pool будет приходить в этот чат. " +
+		"Подписывать и отписывать группу могут только её администраторы."
+	groupAdminsOnly = "Подписку и настройки группы могут менять только её администраторы"
+	introGroup      = "Привет! Я присылаю сообщение о каждом блоке, найденном пулом https://p2pool.io/mini/#pool. " +
+		"Чтобы уведомления приходили в этот чат, администратор группы может отправить /subscribe."
+	welcomeChannel = "Канал подписан на обновления о каждом блоке, найденном пулом https://p2pool.io/mini/#pool. " +
+		"Команды в каналах не работают; чтобы отписаться, удалите бота из канала."
+)
+
+// welcomeText is the reply to a successful /start in a chat of chatType.
+func welcomeText(chatType string) string {
+	switch chatType {
+	case "group", "supergroup":
+		return welcomeGroup
+	default:
+		return welcomePrivate
+	}
+}
+
+// groupAdminsTTL is how long the administrators of a group are trusted
+// before asking Telegram again.
+const groupAdminsTTL = 5 * time.Minute
+
+// groupAdminCache remembers the administrators of groups, so that every
+// command in a busy group doesn't cost a Telegram request.
+type groupAdminCache struct {
+	mu     sync.Mutex
+	groups map[int64]cachedAdmins
+}
+
+type cachedAdmins struct {
+	ids       map[int64]bool
+	fetchedAt time.Time
+}
+
+func newGroupAdminCache() *groupAdminCache {
+	return &groupAdminCache{groups: make(map[int64]cachedAdmins)}
+}
+
+// admins returns the user IDs of the administrators of chatID. Failures
+// aren't cached.
+func (c *groupAdminCache) admins(bot *tgbotapi.BotAPI, chatID int64) (map[int64]bool, error) {
+	c.mu.Lock()
+	cached, ok := c.groups[chatID]
+	c.mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < groupAdminsTTL {
+		return cached.ids, nil
+	}
+
+	members, err := bot.GetChatAdministrators(tgbotapi.ChatAdministratorsConfig{ChatConfig: tgbotapi.ChatConfig{ChatID: chatID}})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[int64]bool, len(members))
+	for _, member := range members {
+		if member.User != nil {
+			ids[member.User.ID] = true
+		}
+	}
+
+	c.mu.Lock()
+	c.groups[chatID] = cachedAdmins{ids: ids, fetchedAt: time.Now()}
+	c.mu.Unlock()
+	return ids, nil
+}
+
+// mayManage reports whether the sender of msg may change the subscription
+// of the chat: anyone in private chats, administrators in groups. Bot
+// admins always may.
+func (r *commandRouter) mayManage(msg *tgbotapi.Message) (bool, error) {
+	if !msg.Chat.IsGroup() && !msg.Chat.IsSuperGroup() {
+		return true, nil
+	}
+	// Anonymous group administrators write on behalf of the group.
+	if msg.SenderChat != nil && msg.SenderChat.ID == msg.Chat.ID {
+		return true, nil
+	}
+	if msg.From == nil {
+		return false, nil
+	}
+	if r.admins[msg.From.ID] {
+		return true, nil
+	}
+
+	admins, err := r.groupAdmins.admins(r.bot, msg.Chat.ID)
+	if err != nil {
+		return false, err
+	}
+	return admins[msg.From.ID], nil
+}
+
+// requireGroupAdmin wraps cmd so that in groups only administrators can
+// run it, for the commands that change the subscription or settings of the
+// chat.
+func (r *commandRouter) requireGroupAdmin(cmd commandFunc) commandFunc {
+	return func(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+		ok, err := r.mayManage(msg)
+		if err != nil {
+			log.Printf("error: get administrators of %s: %s", chatRef(msg.Chat.ID), err.Error())
+			return reply(msg, "Не удалось проверить права в группе, попробуйте позже")
+		}
+		if !ok {
+			return reply(msg, groupAdminsOnly)
+		}
+		return cmd(msg)
+	}
+}
+
+// handleMembership greets the chats the bot is added to. Channels can't
+// send commands, so adding the bot to one subscribes it and removing the
+// bot unsubscribes it.
+func (r *commandRouter) handleMembership(update *tgbotapi.ChatMemberUpdated) {
+	chat := update.Chat
+	r.notifier.rememberChatType(chat.ID, chat.Type)
+
+	wasIn := !update.OldChatMember.HasLeft() && !update.OldChatMember.WasKicked()
+	isIn := !update.NewChatMember.HasLeft() && !update.NewChatMember.WasKicked()
+
+	switch {
+	case chat.IsChannel() && !wasIn && isIn:
+		if err := r.store.Add(chat.ID); err != nil {
+			log.Printf("error: subscribe channel %s: %s", chatRef(chat.ID), err.Error())
+			return
+		}
+		r.notifier.markJoined(chat.ID)
+		r.notifier.winBack(chat.ID)
+		r.usage.countSubscribe()
+		log.Printf("added to channel %s, subscribed it", chatRef(chat.ID))
+		r.sendIntro(chat.ID, welcomeChannel)
+	case chat.IsChannel() && wasIn && !isIn:
+		if err := r.store.Remove(chat.ID); err != nil {
+			log.Printf("error: unsubscribe channel %s: %s", chatRef(chat.ID), err.Error())
+			return
+		}
+		r.notifier.retain(chat.ID)
+		r.usage.countUnsubscribe()
+		log.Printf("removed from channel %s, unsubscribed it", chatRef(chat.ID))
+	case (chat.IsGroup() || chat.IsSuperGroup()) && !wasIn && isIn:
+		r.sendIntro(chat.ID, introGroup)
+	}
+}
+
+func (r *commandRouter) sendIntro(chatID int64, text string) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.DisableWebPagePreview = true
+	if _, err := r.bot.Send(msg); err != nil {
+		log.Printf("error: send onboarding to %s: %s", chatRef(chatID), err.Error())
+	}
+}
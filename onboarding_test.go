@@ -0,0 +1,103 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestGroupSettingsNeedAdmin(t *testing.T) {
+	const group, admin, member = -100, 10, 11
+
+	tests := []string{
+		"/start",
+		"/unsubscribe",
+		"/delete",
+		"/template Блок {{.Height}}",
+		"/skipnext",
+		"/hidename",
+	}
+
+	for _, text := range tests {
+		for _, from := range []int64{admin, member} {
+			t.Run(text, func(t *testing.T) {
+				tg := newFakeTelegram(t)
+				tg.setGroupAdmins(group, admin)
+				store := newTestStore(t)
+				n := newTestNotifier(t, config{}, store)
+				n.bot = tg.bot(t)
+				r := newCommandRouter(n.bot, store, n, n.usage, config{})
+
+				r.handle(groupCommand(group, from, text))
+
+				texts := tg.waitForTexts(t, 1)
+				if refused := texts[0] == groupAdminsOnly; refused != (from == member) {
+					t.Errorf("user %d got %q, want it refused %v", from, texts[0], from == member)
+				}
+			})
+		}
+	}
+}
+
+func TestOnboardingPerChatType(t *testing.T) {
+	const admin, member = 10, 11
+	joined := tgbotapi.ChatMember{Status: "member"}
+	left := tgbotapi.ChatMember{Status: "left"}
+
+	tests := []struct {
+		name string
+		chat tgbotapi.Chat
+		// message is sent by from; without it, the bot is added to the
+		// chat.
+		message        string
+		from           int64
+		wantSubscribed bool
+		wantReply      string
+	}{
+		{name: "private text", chat: tgbotapi.Chat{ID: 1, Type: "private"}, message: "привет", from: 1, wantSubscribed: true, wantReply: welcomePrivate},
+		{name: "private /start", chat: tgbotapi.Chat{ID: 1, Type: "private"}, message: "/start", from: 1, wantSubscribed: true, wantReply: welcomePrivate},
+		{name: "group text", chat: tgbotapi.Chat{ID: -1, Type: "group"}, message: "привет", from: member},
+		{name: "supergroup text", chat: tgbotapi.Chat{ID: -1, Type: "supergroup"}, message: "привет", from: admin},
+		{name: "group /start by admin", chat: tgbotapi.Chat{ID: -1, Type: "group"}, message: "/start", from: admin, wantSubscribed: true, wantReply: welcomeGroup},
+		{name: "supergroup /start by member", chat: tgbotapi.Chat{ID: -1, Type: "supergroup"}, message: "/start", from: member, wantReply: groupAdminsOnly},
+		{name: "added to group", chat: tgbotapi.Chat{ID: -1, Type: "group"}, wantReply: introGroup},
+		{name: "added to channel", chat: tgbotapi.Chat{ID: -1, Type: "channel"}, wantSubscribed: true, wantReply: welcomeChannel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tg := newFakeTelegram(t)
+			tg.setGroupAdmins(tt.chat.ID, admin)
+			store := newTestStore(t)
+			n := newTestNotifier(t, config{}, store)
+			n.bot = tg.bot(t)
+			r := newCommandRouter(n.bot, store, n, n.usage, config{})
+
+			chat := tt.chat
+			if tt.message == "" {
+				r.handleMembership(&tgbotapi.ChatMemberUpdated{Chat: chat, OldChatMember: left, NewChatMember: joined})
+			} else {
+				msg := command(tt.from, tt.message)
+				if !strings.HasPrefix(tt.message, "/") {
+					msg = textMessage(tt.from, tt.message)
+				}
+				msg.Chat = &chat
+				r.handle(msg)
+			}
+
+			ids, _ := store.Subscribers()
+			if subscribed := slices.Contains(ids, chat.ID); subscribed != tt.wantSubscribed {
+				t.Errorf("subscribed = %v, want %v", subscribed, tt.wantSubscribed)
+			}
+			texts := tg.sentTexts()
+			switch {
+			case tt.wantReply == "" && len(texts) > 0:
+				t.Errorf("replied %q, want no reply", texts)
+			case tt.wantReply != "" && (len(texts) != 1 || texts[0] != tt.wantReply):
+				t.Errorf("replied %q, want %q", texts, tt.wantReply)
+			}
+		})
+	}
+}
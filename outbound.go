@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// outboundRecord is one message the bot sent or tried to send.
+type outboundRecord struct {
+	Time   time.Time `json:"time"`
+	ChatID int64     `json:"chat_id,omitempty"`
+	// ChatRef replaces ChatID with LogRedactChatIDs.
+	ChatRef string `json:"chat_ref,omitempty"`
+	// Type is the Bot API method, e.g. sendMessage or editMessageText.
+	Type string `json:"type"`
+	OK   bool   `json:"ok"`
+	// Status is the HTTP status of the reply, 0 if there was none.
+	Status int `json:"status,omitempty"`
+}
+
+// outboundLog appends a JSON line per outbound message to a file of its
+// own, apart from the application log, for operators who must keep one.
+// The file is only ever appended to.
+type outboundLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func openOutboundLog(path string) (*outboundLog, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return nil, err
+	}
+	return &outboundLog{file: file}, nil
+}
+
+func (l *outboundLog) write(r outboundRecord) {
+	line, err := json.Marshal(r)
+	if err != nil {
+		log.Printf("error: encode outbound log record: %s", err.Error())
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		log.Printf("error: write outbound log: %s", err.Error())
+	}
+}
+
+// isOutboundMethod reports whether the Bot API method puts a message in a
+// chat.
+func isOutboundMethod(method string) bool {
+	for _, prefix := range []string{"send", "edit", "copy", "forward"} {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// outboundTransport records the Bot API calls that send messages to an
+// outboundLog. Every Telegram request goes through it, whatever part of
+// the bot makes it.
+type outboundTransport struct {
+	log  *outboundLog
+	next http.RoundTripper
+}
+
+func (t outboundTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	method := path.Base(req.URL.Path)
+	if !isOutboundMethod(method) || req.Body == nil {
+		return t.next.RoundTrip(req)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	// A RoundTripper must not modify the caller's request.
+	form := req.Clone(req.Context())
+	form.Body = io.NopCloser(bytes.NewReader(body))
+	chatID, _ := strconv.ParseInt(form.FormValue("chat_id"), 10, 64)
+
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	res, err := t.next.RoundTrip(out)
+
+	record := outboundRecord{Time: time.Now(), ChatID: chatID, Type: method}
+	if chatIDSalt != nil {
+		record.ChatID, record.ChatRef = 0, chatRef(chatID)
+	}
+	if err == nil {
+		record.Status = res.StatusCode
+		record.OK = res.StatusCode == http.StatusOK
+	}
+	t.log.write(record)
+	return res, err
+}
+
+// newBot connects to Telegram, recording outbound messages to
+// OutboundLogPath if set.
+func newBot(conf config, apiKey string) (*tgbotapi.BotAPI, error) {
+	if conf.OutboundLogPath == "" {
+		return tgbotapi.NewBotAPI(apiKey)
+	}
+
+	outbound, err := openOutboundLog(conf.OutboundLogPath)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Transport: outboundTransport{log: outbound, next: http.DefaultTransport}}
+	return tgbotapi.NewBotAPIWithClient(apiKey, tgbotapi.APIEndpoint, client)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// outboundBot returns a client of tg that records outbound messages to the
+// file at path.
+func outboundBot(t *testing.T, tg *fakeTelegram, path string) *tgbotapi.BotAPI {
+	t.Helper()
+	outbound, err := openOutboundLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { outbound.file.Close() })
+
+	client := &http.Client{Transport: outboundTransport{log: outbound, next: http.DefaultTransport}}
+	bot, err := tgbotapi.NewBotAPIWithClient("token", tg.URL+"/bot%s/%s", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bot
+}
+
+// readOutboundLog returns the records of the outbound log at path.
+func readOutboundLog(t *testing.T, path string) []outboundRecord {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var records []outboundRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var r outboundRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("line %q: %s", scanner.Text(), err)
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+func TestOutboundLog(t *testing.T) {
+	const delivered, blocked = 987654321, 876543219
+
+	tests := []struct {
+		name   string
+		redact bool
+	}{
+		{name: "raw IDs"},
+		{name: "redacted IDs", redact: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.redact {
+				chatIDSalt = []byte("salt")
+				t.Cleanup(func() { chatIDSalt = nil })
+			}
+			path := filepath.Join(t.TempDir(), "outbound.jsonl")
+			tg := newFakeTelegram(t)
+			tg.failFor(blocked)
+			bot := outboundBot(t, tg, path)
+
+			bot.Send(tgbotapi.NewMessage(delivered, "block"))
+			bot.Send(tgbotapi.NewMessage(blocked, "block"))
+			bot.Request(tgbotapi.NewEditMessageText(delivered, 1, "edited"))
+			// Calls that put nothing in a chat aren't recorded.
+			bot.GetChat(tgbotapi.ChatInfoConfig{ChatConfig: tgbotapi.ChatConfig{ChatID: delivered}})
+
+			records := readOutboundLog(t, path)
+			want := []outboundRecord{
+				{ChatID: delivered, Type: "sendMessage", OK: true, Status: http.StatusOK},
+				{ChatID: blocked, Type: "sendMessage", Status: http.StatusForbidden},
+				{ChatID: delivered, Type: "editMessageText", OK: true, Status: http.StatusOK},
+			}
+			if len(records) != len(want) {
+				t.Fatalf("records = %+v, want %d", records, len(want))
+			}
+			for i, r := range records {
+				w := want[i]
+				if tt.redact {
+					w.ChatID, w.ChatRef = 0, chatRef(w.ChatID)
+				}
+				if r.Time.IsZero() {
+					t.Errorf("record %d has no time", i)
+				}
+				r.Time = w.Time
+				if r != w {
+					t.Errorf("record %d = %+v, want %+v", i, r, w)
+				}
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.redact && strings.Contains(string(data), "987654321") {
+				t.Errorf("redacted log has a raw chat ID:\n%s", data)
+			}
+		})
+	}
+}
+
+func TestOutboundLogAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbound.jsonl")
+	if err := os.WriteFile(path, []byte(`{"time":"2024-01-01T00:00:00Z","chat_id":1,"type":"sendMessage","ok":true,"status":200}`+"\n"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	tg := newFakeTelegram(t)
+	outboundBot(t, tg, path).Send(tgbotapi.NewMessage(2, "block"))
+
+	records := readOutboundLog(t, path)
+	if len(records) != 2 || records[0].ChatID != 1 || records[1].ChatID != 2 {
+		t.Errorf("records = %+v, want the old one kept and the new one after it", records)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	miniStatsURL = "https://p2pool.io/mini/api/pool/stats"
+	mainStatsURL = "https://p2pool.io/api/pool/stats"
+
+	// sidechainBlockTime is the target share time of both p2pool sidechains.
+	sidechainBlockTime = 10 * time.Second
+)
+
+var errInvalidHashrate = errors.New("invalid hashrate")
+
+type poolStats struct {
+	HashRate            float64 `json:"hashRate"`
+	Miners              int     `json:"miners"`
+	SidechainDifficulty float64 `json:"sidechainDifficulty"`
+	TotalBlocksFound    int     `json:"totalBlocksFound"`
+}
+
+func fetchPoolStats(url string) (poolStats, error) {
+	res, err := apiClient.Get(url)
+	if err != nil {
+		return poolStats{}, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return poolStats{}, err
+	}
+
+	var resp struct {
+		PoolStatistics *poolStats `json:"pool_statistics"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return poolStats{}, err
+	}
+	if resp.PoolStatistics == nil {
+		return poolStats{}, errUnexpectedStructure
+	}
+
+	return *resp.PoolStatistics, nil
+}
+
+// recommendPool suggests mini to miners whose hashrate is under 1% of the
+// hashrate needed to find a main sidechain share every block time; they'd
+// wait too long between shares on main.
+func recommendPool(hashrate float64, miniStats, mainStats poolStats) string {
+	if miniStats.SidechainDifficulty == 0 || mainStats.SidechainDifficulty == 0 {
+		return ""
+	}
+
+	mainShareRate := mainStats.SidechainDifficulty / sidechainBlockTime.Seconds()
+	if hashrate < mainShareRate/100 {
+		return "mini"
+	}
+	return "main"
+}
+
+// parseHashrate parses user input like "500", "500h", "1.5kh" or "2 MH/s"
+// into H/s.
+func parseHashrate(s string) (uint64, error) {
+	s = strings.ToLower(strings.ReplaceAll(s, " ", ""))
+	s = strings.TrimSuffix(s, "/s")
+	s = strings.TrimSuffix(s, "h")
+
+	multiplier := 1.0
+	if s != "" {
+		switch s[len(s)-1] {
+		case 'k':
+			multiplier = 1e3
+		case 'm':
+			multiplier = 1e6
+		case 'g':
+			multiplier = 1e9
+		case 't':
+			multiplier = 1e12
+		case 'p':
+			multiplier = 1e15
+		}
+		if multiplier != 1 {
+			s = s[:len(s)-1]
+		}
+	}
+
+	// Whole numbers are taken as they are, a float64 can't hold all of
+	// them.
+	if n, err := strconv.ParseUint(s, 10, 64); err == nil && multiplier == 1 {
+		return n, nil
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+	// ParseFloat also takes "inf" and "nan"; 2^64 is the first value that
+	// doesn't fit.
+	if err != nil || math.IsNaN(value) || value < 0 || value*multiplier >= math.Exp2(64) {
+		return 0, fmt.Errorf("%w: %q", errInvalidHashrate, s)
+	}
+
+	return uint64(value * multiplier), nil
+}
+
+// formatHashrate formats hs H/s with the largest unit that keeps the number
+// at least 1, e.g. "4.56 MH/s".
+func formatHashrate(hs uint64) string {
+	units := []string{"H/s", "KH/s", "MH/s", "GH/s", "TH/s", "PH/s", "EH/s"}
+	h := float64(hs)
+	unit := 0
+	for h >= 1000 && unit < len(units)-1 {
+		h /= 1000
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d %s", hs, units[unit])
+	}
+	// 999.995 KH/s would print as "1000.00 KH/s".
+	if math.Round(h*100) >= 1000*100 && unit < len(units)-1 {
+		h /= 1000
+		unit++
+	}
+	return fmt.Sprintf("%.2f %s", h, units[unit])
+}
+
+// statsURL is the stats endpoint of pool: StatsURL if set, otherwise the
+// sibling of a p2pool.io style .../pool/blocks URL.
+func (p poolConfig) statsURL() (string, bool) {
+	if p.StatsURL != "" {
+		return p.StatsURL, true
+	}
+	if base, ok := strings.CutSuffix(p.URL, "/pool/blocks"); ok {
+		return base + "/pool/stats", true
+	}
+	return "", false
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"testing"
+)
+
+func TestFormatHashrate(t *testing.T) {
+	tests := []struct {
+		hs   uint64
+		want string
+	}{
+		{0, "0 H/s"},
+		{1, "1 H/s"},
+		{999, "999 H/s"},
+		{1000, "1.00 KH/s"},
+		{1234, "1.23 KH/s"},
+		{999_994, "999.99 KH/s"},
+		{999_995, "1.00 MH/s"},
+		{999_999, "1.00 MH/s"},
+		{4_560_000, "4.56 MH/s"},
+		{999_995_000, "1.00 GH/s"},
+		{7_890_000_000, "7.89 GH/s"},
+		{1_500_000_000_000, "1.50 TH/s"},
+		{2_000_000_000_000_000, "2.00 PH/s"},
+		{math.MaxUint64, "18.45 EH/s"},
+	}
+
+	for _, tt := range tests {
+		if got := formatHashrate(tt.hs); got != tt.want {
+			t.Errorf("formatHashrate(%d) = %q, want %q", tt.hs, got, tt.want)
+		}
+	}
+}
+
+func TestParseHashrate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    uint64
+		wantErr bool
+	}{
+		{in: "0", want: 0},
+		{in: "500", want: 500},
+		{in: "500h", want: 500},
+		{in: "500kh", want: 500_000},
+		{in: "1.5kh", want: 1500},
+		{in: "2 MH/s", want: 2_000_000},
+		{in: "3g", want: 3_000_000_000},
+		{in: "1TH/s", want: 1_000_000_000_000},
+		{in: "18446744073709551615", want: math.MaxUint64},
+		{in: "", wantErr: true},
+		{in: "abc", wantErr: true},
+		{in: "-1", wantErr: true},
+		{in: "inf", wantErr: true},
+		{in: "nan", wantErr: true},
+		{in: "20000p", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseHashrate(tt.in)
+		if tt.wantErr {
+			if !errors.Is(err, errInvalidHashrate) {
+				t.Errorf("parseHashrate(%q) error = %v, want errInvalidHashrate", tt.in, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseHashrate(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestCmdHashrate(t *testing.T) {
+	tests := []struct {
+		name string
+		pool func(t *testing.T) poolConfig
+		want string
+	}{
+		{
+			name: "stats server",
+			pool: func(t *testing.T) poolConfig {
+				return poolConfig{Name: "mini", StatsURL: newPoolAPI(t, http.StatusOK, `{"pool_statistics":{"hashRate":12400000,"miners":1234}}`)}
+			},
+			want: "Хешрейт пула mini: 12.40 MH/s, майнеров: 1234",
+		},
+		{
+			name: "unexpected structure",
+			pool: func(t *testing.T) poolConfig {
+				return poolConfig{Name: "mini", StatsURL: newPoolAPI(t, http.StatusOK, `{}`)}
+			},
+			want: "Не удалось получить статистику пула",
+		},
+		{
+			name: "no stats URL",
+			pool: func(t *testing.T) poolConfig {
+				return poolConfig{Name: "mini", URL: "http://example.invalid/blocks"}
+			},
+			want: "Для пула mini не настроена статистика (StatsURL)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := config{Pools: []poolConfig{tt.pool(t)}}
+			store := newTestStore(t)
+			n := newTestNotifier(t, conf, store)
+			r := newCommandRouter(nil, store, n, n.usage, conf)
+
+			if got := r.cmdHashrate(command(1, "/hashrate")).Text; got != tt.want {
+				t.Errorf("/hashrate = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecommendPool(t *testing.T) {
+	mini := poolStats{SidechainDifficulty: 1e9}
+	// A main share every 10s takes 1e10 H/s, 1% of it is 1e8 H/s.
+	main := poolStats{SidechainDifficulty: 1e11}
+
+	tests := []struct {
+		name     string
+		hashrate float64
+		mini     poolStats
+		main     poolStats
+		want     string
+	}{
+		{"small miner", 5e3, mini, main, "mini"},
+		{"just below the threshold", 1e8 - 1, mini, main, "mini"},
+		{"at the threshold", 1e8, mini, main, "main"},
+		{"large miner", 1e9, mini, main, "main"},
+		{"no hashrate", 0, mini, main, "mini"},
+		{"main stats missing", 5e3, mini, poolStats{}, ""},
+		{"mini stats missing", 5e3, poolStats{}, main, ""},
+	}
+
+	for _, tt := range tests {
+		if got := recommendPool(tt.hashrate, tt.mini, tt.main); got != tt.want {
+			t.Errorf("%s: recommendPool(%g) = %q, want %q", tt.name, tt.hashrate, got, tt.want)
+		}
+	}
+}
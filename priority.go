@@ -0,0 +1,41 @@
+package main
+
+import "sort"
+
+// sendPriority orders broadcast recipients, lower goes first.
+type sendPriority int
+
+const (
+	// priorityOperator is the operator's channels and the admins.
+	priorityOperator sendPriority = iota
+	priorityBulk
+)
+
+func (p sendPriority) String() string {
+	if p == priorityOperator {
+		return "operator"
+	}
+	return "bulk"
+}
+
+// sendOrder sorts ids so that admins and channels are notified before the
+// rest. The order within a class is that of the store, so it doesn't change
+// between broadcasts. Only cached chat types are looked at: asking Telegram
+// would hold up the very notifications we want to get out first.
+func (n *Notifier) sendOrder(ids []int64) ([]int64, map[int64]sendPriority) {
+	priority := make(map[int64]sendPriority, len(ids))
+	n.state.view(func(st state) {
+		for _, id := range ids {
+			priority[id] = priorityBulk
+			if n.admins[id] || st.ChatTypes[id] == "channel" {
+				priority[id] = priorityOperator
+			}
+		}
+	})
+
+	ordered := append([]int64(nil), ids...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return priority[ordered[i]] < priority[ordered[j]]
+	})
+	return ordered, priority
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSendOrder(t *testing.T) {
+	const admin, channel = 7, -1007
+
+	tests := []struct {
+		name         string
+		ids          []int64
+		want         []int64
+		wantOperator []int64
+	}{
+		{"no operators", []int64{3, 1, 2}, []int64{3, 1, 2}, nil},
+		{"operators first", []int64{1, 2, channel, 3, admin}, []int64{channel, admin, 1, 2, 3}, []int64{channel, admin}},
+		{"already in order", []int64{admin, 1, 2}, []int64{admin, 1, 2}, []int64{admin}},
+		{"empty", nil, []int64{}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNotifier(t, config{AdminIDs: []int64{admin}}, newTestStore(t))
+			n.rememberChatType(channel, "channel")
+			n.rememberChatType(1, "private")
+
+			got, priority := n.sendOrder(tt.ids)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("sendOrder(%v) = %v, want %v", tt.ids, got, tt.want)
+			}
+			for _, id := range tt.ids {
+				want := priorityBulk
+				if slices.Contains(tt.wantOperator, id) {
+					want = priorityOperator
+				}
+				if priority[id] != want {
+					t.Errorf("priority of %d = %s, want %s", id, priority[id], want)
+				}
+			}
+		})
+	}
+}
+
+func TestBroadcastCountsPriorities(t *testing.T) {
+	const admin = 7
+
+	tg := newFakeTelegram(t)
+	store := newTestStore(t)
+	for _, id := range []int64{1, 2, admin, 3} {
+		if err := store.Add(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n := newTestNotifier(t, config{AdminIDs: []int64{admin}}, store)
+	n.bot = tg.bot(t)
+
+	for i := 0; i < 2; i++ {
+		blocks := []block{{height: 100 + i, ts: time.Now()}}
+		if err := n.broadcast(context.Background(), poolConfig{Name: defaultPoolName}, blocks, func(int64) string { return "block" }); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if sent := tg.sentTo(); len(sent) == 0 || sent[0] != admin {
+		t.Errorf("sent to %v, want the admin first", sent)
+	}
+	var out strings.Builder
+	writeMetrics(&out, n.metrics())
+	for _, want := range []string{
+		`p2pool_notifier_notifications_sent_total{priority="operator"} 2`,
+		`p2pool_notifier_notifications_sent_total{priority="bulk"} 6`,
+	} {
+		if !strings.Contains(out.String(), want+"\n") {
+			t.Errorf("metrics lack %q:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// pruneRequestInterval spaces out getChat calls to stay well below the
+// Telegram rate limits.
+const pruneRequestInterval = 200 * time.Millisecond
+
+// pruneWorker periodically drops subscribers whose chats no longer exist.
+func (n *Notifier) pruneWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pruned, err := n.pruneUnreachable(ctx)
+			if err != nil {
+				log.Printf("error: prune subscribers: %s", err.Error())
+			}
+			log.Printf("pruned %d unreachable subscribers", pruned)
+		}
+	}
+}
+
+func (n *Notifier) pruneUnreachable(ctx context.Context) (int, error) {
+	ids, err := n.store.Subscribers()
+	if err != nil {
+		return 0, err
+	}
+
+	throttle := time.NewTicker(pruneRequestInterval)
+	defer throttle.Stop()
+
+	pruned := 0
+	for _, id := range ids {
+		select {
+		case <-ctx.Done():
+			return pruned, ctx.Err()
+		case <-throttle.C:
+		}
+
+		_, err := n.bot.GetChat(tgbotapi.ChatInfoConfig{ChatConfig: tgbotapi.ChatConfig{ChatID: id}})
+		if err == nil {
+			n.resetDeliveryFailures(id)
+			continue
+		}
+		failures := n.deliveryFailures(id)
+		if isChatNotFound(err) {
+			failures = n.recordDeliveryFailure(id)
+		}
+		if failures < n.pruneAfter {
+			continue
+		}
+
+		// One chat that can't be dropped mustn't keep the rest.
+		if err := n.dropUnreachable(id, failures); err != nil {
+			log.Printf("error: prune %s: %s", chatRef(id), err.Error())
+			continue
+		}
+		pruned++
+	}
+
+	return pruned, nil
+}
+
+// dropUnreachable unsubscribes id after failures in a row.
+func (n *Notifier) dropUnreachable(id int64, failures int) error {
+	if err := n.store.Remove(id); err != nil {
+		return err
+	}
+	n.resetDeliveryFailures(id)
+	log.Printf("pruned %s after %d failures in a row", chatRef(id), failures)
+	n.audit.record(0, "prune", map[string]string{"chat": strconv.FormatInt(id, 10), "failures": strconv.Itoa(failures)})
+	return nil
+}
+
+// isTerminalSendError reports whether err means messages to the chat will
+// keep failing: it's gone, or the bot was blocked or removed from it.
+func isTerminalSendError(err error) bool {
+	var tgErr *tgbotapi.Error
+	return isChatNotFound(err) || errors.As(err, &tgErr) && tgErr.Code == http.StatusForbidden
+}
+
+func (n *Notifier) deliveryFailures(id int64) int {
+	var failures int
+	n.state.view(func(st state) {
+		failures = st.DeliveryFailures[id]
+	})
+	return failures
+}
+
+// recordDeliveryFailure counts a terminal failure to reach id and returns
+// the failures in a row so far.
+func (n *Notifier) recordDeliveryFailure(id int64) int {
+	var failures int
+	err := n.state.update(func(st *state) {
+		if st.DeliveryFailures == nil {
+			st.DeliveryFailures = make(map[int64]int)
+		}
+		st.DeliveryFailures[id]++
+		failures = st.DeliveryFailures[id]
+	})
+	if err != nil {
+		log.Printf("error: save delivery failures of %s: %s", chatRef(id), err.Error())
+	}
+	return failures
+}
+
+func (n *Notifier) resetDeliveryFailures(id int64) {
+	if n.deliveryFailures(id) == 0 {
+		return
+	}
+	err := n.state.update(func(st *state) {
+		delete(st.DeliveryFailures, id)
+	})
+	if err != nil {
+		log.Printf("error: save delivery failures of %s: %s", chatRef(id), err.Error())
+	}
+}
+
+func isChatNotFound(err error) bool {
+	var tgErr *tgbotapi.Error
+	return errors.As(err, &tgErr) &&
+		tgErr.Code == http.StatusBadRequest &&
+		strings.Contains(strings.ToLower(tgErr.Message), "chat not found")
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// removeFailingStore fails to remove the chats in failing.
+type removeFailingStore struct {
+	Storer
+	failing map[int64]bool
+}
+
+func (s *removeFailingStore) Remove(id int64) error {
+	if s.failing[id] {
+		return errors.New("disk full")
+	}
+	return s.Storer.Remove(id)
+}
+
+func TestPruneUnreachable(t *testing.T) {
+	const (
+		alive      = 1
+		gone       = 2
+		stuck      = 3
+		recovered  = 4
+		pruneAfter = 2
+	)
+
+	tg := newFakeTelegram(t)
+	tg.remove(gone, stuck)
+	store := &removeFailingStore{Storer: newTestStore(t), failing: map[int64]bool{stuck: true}}
+	for _, id := range []int64{alive, gone, stuck, recovered} {
+		if err := store.Add(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n := newTestNotifier(t, config{PruneAfterFailures: pruneAfter}, store)
+	n.bot = tg.bot(t)
+	// A broadcast failed to reach it, but the chat is fine now.
+	n.recordDeliveryFailure(recovered)
+
+	tests := []struct {
+		name         string
+		wantPruned   int
+		wantFailures map[int64]int
+		wantLeft     []int64
+	}{
+		{
+			name:         "first run counts",
+			wantPruned:   0,
+			wantFailures: map[int64]int{gone: 1, stuck: 1},
+			wantLeft:     []int64{alive, gone, stuck, recovered},
+		},
+		{
+			name:       "second run drops the gone chat past the stuck one",
+			wantPruned: 1,
+			// The stuck chat keeps counting until it can be dropped.
+			wantFailures: map[int64]int{stuck: 2},
+			wantLeft:     []int64{alive, stuck, recovered},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pruned, err := n.pruneUnreachable(context.Background())
+			if err != nil {
+				t.Fatalf("pruneUnreachable() = %v", err)
+			}
+			if pruned != tt.wantPruned {
+				t.Errorf("pruned %d, want %d", pruned, tt.wantPruned)
+			}
+			for _, id := range []int64{alive, gone, stuck, recovered} {
+				if got := n.deliveryFailures(id); got != tt.wantFailures[id] {
+					t.Errorf("chat %d has %d failures, want %d", id, got, tt.wantFailures[id])
+				}
+			}
+			left, err := store.Subscribers()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(left, tt.wantLeft) {
+				t.Errorf("subscribers = %v, want %v", left, tt.wantLeft)
+			}
+		})
+	}
+}
+
+func TestPruneUnreachableCanceled(t *testing.T) {
+	tg := newFakeTelegram(t)
+	store := newTestStore(t)
+	store.Add(1)
+	n := newTestNotifier(t, config{}, store)
+	n.bot = tg.bot(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := n.pruneUnreachable(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("pruneUnreachable() = %v, want context.Canceled", err)
+	}
+}
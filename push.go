@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+type pushRequest struct {
+	Text string `json:"text"`
+	// Tag, if set, limits the push to the subscribers tagged with it.
+	Tag string `json:"tag,omitempty"`
+}
+
+type pushResponse struct {
+	Sent   int `json:"sent"`
+	Failed int `json:"failed"`
+}
+
+// pushHandler serves POST /api/notify for external monitoring: the text of
+// the JSON body goes to every subscriber.
+func pushHandler(n *Notifier, token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req pushRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxMessageLength*4)).Decode(&req); err != nil {
+			http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Text = strings.TrimSpace(req.Text)
+		if req.Text == "" || len([]rune(req.Text)) > maxMessageLength {
+			http.Error(w, "text must be 1 to 4096 characters", http.StatusBadRequest)
+			return
+		}
+
+		sent, failed, err := n.push(r.Context(), req.Tag, req.Text)
+		if err != nil {
+			log.Printf("error: push notification: %s", err.Error())
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		n.audit.record(0, "push", map[string]string{
+			"tag": req.Tag, "text_sha256": textDigest(req.Text), "sent": strconv.Itoa(sent), "failed": strconv.Itoa(failed),
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pushResponse{Sent: sent, Failed: failed})
+	}
+}
+
+// push sends text to every subscriber, or to those tagged with tag if it
+// isn't empty, in the same order and through the same send queue as block
+// notifications. It carries on past failed sends and counts them.
+func (n *Notifier) push(ctx context.Context, tag, text string) (sent, failed int, err error) {
+	ids, err := n.recipients()
+	if err != nil {
+		return 0, 0, err
+	}
+	if tag != "" {
+		ids = n.tagged(ids, tag)
+	}
+	ids, _ = n.sendOrder(ids)
+
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			failed += len(ids) - sent - failed
+			break
+		}
+		if _, err := n.send(ctx, tgbotapi.NewMessage(id, text)); err != nil {
+			log.Printf("error: push to %s: %s", chatRef(id), err.Error())
+			failed++
+			continue
+		}
+		sent++
+	}
+
+	log.Printf("pushed notification to %d of %d subscribers", sent, len(ids))
+	return sent, failed, nil
+}
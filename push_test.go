@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPushEndpoint(t *testing.T) {
+	const token = "s3cret"
+
+	tests := []struct {
+		name       string
+		method     string
+		auth       string
+		body       string
+		wantStatus int
+		wantSent   []int64
+	}{
+		{"delivered", http.MethodPost, "Bearer " + token, `{"text":"pool maintenance tonight"}`, http.StatusOK, []int64{1, 2}},
+		{"no token", http.MethodPost, "", `{"text":"hi"}`, http.StatusUnauthorized, nil},
+		{"wrong token", http.MethodPost, "Bearer nope", `{"text":"hi"}`, http.StatusUnauthorized, nil},
+		{"not bearer", http.MethodPost, token, `{"text":"hi"}`, http.StatusUnauthorized, nil},
+		{"GET", http.MethodGet, "Bearer " + token, "", http.StatusMethodNotAllowed, nil},
+		{"empty text", http.MethodPost, "Bearer " + token, `{"text":"  "}`, http.StatusBadRequest, nil},
+		{"not JSON", http.MethodPost, "Bearer " + token, `text`, http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tg := newFakeTelegram(t)
+			store := newTestStore(t)
+			store.Add(1)
+			store.Add(2)
+			n := newTestNotifier(t, config{}, store)
+			n.bot = tg.bot(t)
+			srv := httptest.NewServer(httpMux(n, time.Minute, token))
+			defer srv.Close()
+
+			req, _ := http.NewRequest(tt.method, srv.URL+"/api/notify", strings.NewReader(tt.body))
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+			sent := tg.sentTo()
+			slices.Sort(sent)
+			if !slices.Equal(sent, tt.wantSent) {
+				t.Errorf("sent to %v, want %v", sent, tt.wantSent)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var resp pushResponse
+			if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Sent != len(tt.wantSent) || resp.Failed != 0 {
+				t.Errorf("response = %+v, want %d sent", resp, len(tt.wantSent))
+			}
+		})
+	}
+}
+
+func TestPushEndpointDisabledWithoutToken(t *testing.T) {
+	n := newTestNotifier(t, config{}, newTestStore(t))
+	srv := httptest.NewServer(httpMux(n, time.Minute, ""))
+	defer srv.Close()
+
+	res, err := http.Post(srv.URL+"/api/notify", "application/json", strings.NewReader(`{"text":"hi"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", res.StatusCode)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// readOnlyProbeInterval is how often a store in read-only mode checks
+// whether it can write again.
+const readOnlyProbeInterval = time.Minute
+
+// errStoreReadOnly is returned by writes while the store is in read-only
+// mode, without trying them.
+var errStoreReadOnly = errors.New("store is read-only")
+
+const subscriptionsClosed = "Подписка и отписка временно закрыты из-за технических проблем, попробуйте позже. Администраторы уже в курсе"
+
+// isReadOnlyError reports whether err comes from a read-only filesystem or
+// missing write permission, which retrying won't fix.
+func isReadOnlyError(err error) bool {
+	return errors.Is(err, syscall.EROFS) || errors.Is(err, fs.ErrPermission)
+}
+
+// degradable is implemented by stores that switch to a read-only mode when
+// their storage stops accepting writes, and back once it does again.
+type degradable interface {
+	readOnly() bool
+	// onReadOnlyChange registers fn to be called on every switch, with the
+	// error that caused it when entering read-only mode.
+	onReadOnlyChange(fn func(readOnly bool, err error))
+}
+
+// doWrite runs a write on the store goroutine. A read-only filesystem or a
+// permission error switches the store to read-only mode, where writes fail
+// right away until a probe in the background succeeds.
+func (s *fileStore) doWrite(fn func() error) error {
+	if s.degraded.Load() {
+		return errStoreReadOnly
+	}
+
+	err := s.do(fn)
+	if !isReadOnlyError(err) {
+		return err
+	}
+	if s.degraded.CompareAndSwap(false, true) {
+		log.Printf("error: %s is not writable, switching to read-only mode: %s", s.path, err.Error())
+		s.notifyReadOnly(true, err)
+		go s.probeWritable()
+	}
+	return fmt.Errorf("%w: %w", errStoreReadOnly, err)
+}
+
+// probeWritable tries to create a file next to the subscribers file every
+// readOnlyProbeInterval and leaves read-only mode once it can.
+func (s *fileStore) probeWritable() {
+	ticker := time.NewTicker(readOnlyProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+
+		err := s.do(func() error {
+			probe, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".probe-*")
+			if err != nil {
+				return err
+			}
+			probe.Close()
+			return os.Remove(probe.Name())
+		})
+		if errors.Is(err, errStoreClosed) {
+			return
+		}
+		if err == nil {
+			s.degraded.Store(false)
+			log.Printf("%s is writable again, leaving read-only mode", s.path)
+			s.notifyReadOnly(false, nil)
+			return
+		}
+	}
+}
+
+func (s *fileStore) readOnly() bool {
+	return s.degraded.Load()
+}
+
+func (s *fileStore) onReadOnlyChange(fn func(readOnly bool, err error)) {
+	s.readOnlyMu.Lock()
+	defer s.readOnlyMu.Unlock()
+	s.readOnlyHook = fn
+}
+
+func (s *fileStore) notifyReadOnly(readOnly bool, err error) {
+	s.readOnlyMu.Lock()
+	fn := s.readOnlyHook
+	s.readOnlyMu.Unlock()
+	if fn != nil {
+		fn(readOnly, err)
+	}
+}
+
+// storeReadOnly reports whether the store is in read-only mode.
+func (n *Notifier) storeReadOnly() bool {
+	d, ok := n.store.(degradable)
+	return ok && d.readOnly()
+}
+
+// alertReadOnly tells the admins once when the store turns read-only and
+// once when it recovers.
+func (n *Notifier) alertReadOnly(readOnly bool, err error) {
+	text := "✅ Хранилище подписчиков снова доступно для записи, подписка открыта"
+	if readOnly {
+		text = fmt.Sprintf("⚠️ Хранилище подписчиков недоступно для записи (%s). Новые подписки и отписки закрыты, уведомления текущим подписчикам продолжаются", err.Error())
+	}
+	logger(context.Background()).Warn("store read-only mode", "read_only", readOnly)
+	for id := range n.admins {
+		if _, err := n.bot.Send(tgbotapi.NewMessage(id, text)); err != nil {
+			log.Printf("error: send read-only alert to %s: %s", chatRef(id), err.Error())
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestIsReadOnlyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&fs.PathError{Op: "open", Path: "subscribers.txt", Err: syscall.EROFS}, true},
+		{&fs.PathError{Op: "open", Path: "subscribers.txt", Err: syscall.EACCES}, true},
+		{fmt.Errorf("write: %w", fs.ErrPermission), true},
+		{&fs.PathError{Op: "write", Path: "subscribers.txt", Err: syscall.ENOSPC}, false},
+		{errors.New("other"), false},
+		{nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := isReadOnlyError(tt.err); got != tt.want {
+			t.Errorf("isReadOnlyError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestFileStoreReadOnlyDir(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root writes to read-only directories")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "subscribers.txt")
+	if err := os.WriteFile(path, []byte("1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s := newFileStore(path)
+	t.Cleanup(func() { s.Close() })
+	var changes []bool
+	s.onReadOnlyChange(func(readOnly bool, err error) { changes = append(changes, readOnly) })
+
+	// Like a filesystem remounted read-only: neither the file nor the
+	// directory take writes.
+	for _, p := range []string{path, dir} {
+		if err := os.Chmod(p, 0500); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { os.Chmod(dir, 0700) })
+
+	if err := s.Add(2); !errors.Is(err, errStoreReadOnly) || !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Add = %v, want a read-only error with its cause", err)
+	}
+	if !s.readOnly() || !slices.Equal(changes, []bool{true}) {
+		t.Errorf("read-only = %v after changes %v, want read-only once", s.readOnly(), changes)
+	}
+	// Later writes fail without trying.
+	if err := s.Remove(1); !errors.Is(err, errStoreReadOnly) || errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Remove = %v, want %v alone", err, errStoreReadOnly)
+	}
+	if ids, err := s.Subscribers(); err != nil || !slices.Equal(ids, []int64{1}) {
+		t.Errorf("Subscribers = %v, %v, want the stored ones still readable", ids, err)
+	}
+}
+
+func TestReadOnlyStoreClosesSubscriptions(t *testing.T) {
+	tg := newFakeTelegram(t)
+	store := newTestStore(t)
+	store.Add(1)
+	n := newTestNotifier(t, config{AdminIDs: []int64{7}}, store)
+	n.bot = tg.bot(t)
+	r := newCommandRouter(nil, store, n, n.usage, config{})
+
+	// What a write to a read-only filesystem returns.
+	err := store.doWrite(func() error {
+		return &fs.PathError{Op: "open", Path: store.path, Err: syscall.EROFS}
+	})
+	if !errors.Is(err, errStoreReadOnly) {
+		t.Fatalf("write = %v, want %v", err, errStoreReadOnly)
+	}
+	if !n.storeReadOnly() {
+		t.Fatal("store not in read-only mode")
+	}
+	if texts := tg.sentTexts(); len(texts) != 1 || !strings.HasPrefix(texts[0], "⚠️ Хранилище подписчиков недоступно для записи") {
+		t.Errorf("alerts = %q, want one to the admin", texts)
+	}
+
+	for _, text := range []string{"/start", "/unsubscribe"} {
+		msg := command(2, text)
+		_, cmd := r.route(msg)
+		if got := cmd(msg).Text; got != subscriptionsClosed {
+			t.Errorf("%s = %q, want %q", text, got, subscriptionsClosed)
+		}
+	}
+	if ids, _ := store.Subscribers(); !slices.Equal(ids, []int64{1}) {
+		t.Errorf("subscribers = %v, want them unchanged", ids)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// poolRecovery compares what a pool's state said before the restart with
+// what the API says now.
+type poolRecovery struct {
+	pool      string
+	persisted int
+	tip       int
+	// missed is how many blocks were found while the bot was down. When
+	// atLeast is set the API history didn't reach back far enough to tell.
+	missed  int
+	atLeast bool
+	// skipFirst is set when a tip without saved state won't be announced.
+	skipFirst bool
+	err       error
+}
+
+type recoveryReport struct {
+	stateCorrupt bool
+	// subscribers is set when the subscribers file was quarantined.
+	subscribers *quarantineReport
+	deferred    int
+	pools       []poolRecovery
+}
+
+// reconcile runs once before the worker starts polling and reports how the
+// persisted state relates to the pool API after a restart.
+func (n *Notifier) reconcile() recoveryReport {
+	var report recoveryReport
+	report.stateCorrupt = n.state.corrupt
+	if file, ok := n.store.(*fileStore); ok {
+		report.subscribers = file.quarantine
+	}
+	n.state.view(func(st state) {
+		report.deferred = len(st.Deferred)
+	})
+
+	for _, pool := range n.pools {
+		r := poolRecovery{pool: pool.Name, persisted: n.lastBlocks.getLastBlock(pool.Name).height, skipFirst: n.skipFirstBlock}
+
+		blocks, err := fetchRecentBlocks(pool.URL)
+		if err != nil {
+			r.err = err
+			report.pools = append(report.pools, r)
+			continue
+		}
+
+		r.tip = blocks[0].height
+		if r.persisted != 0 {
+			for _, b := range blocks {
+				if b.height <= r.persisted {
+					break
+				}
+				r.missed++
+			}
+			r.atLeast = r.missed == len(blocks)
+		}
+
+		report.pools = append(report.pools, r)
+	}
+
+	return report
+}
+
+func (r poolRecovery) outcome() string {
+	switch {
+	case r.err != nil:
+		return "unknown, API unreachable: " + r.err.Error()
+	case r.persisted == 0 && r.skipFirst:
+		return "no saved state, the current tip will be recorded without an announcement"
+	case r.persisted == 0:
+		return "no saved state, the current tip will be announced"
+	case r.missed == 0:
+		return "up to date, nothing to announce"
+	case r.atLeast:
+		return fmt.Sprintf("at least %d blocks found while down, only the tip will be announced", r.missed)
+	default:
+		return fmt.Sprintf("%d blocks found while down, only the tip will be announced", r.missed)
+	}
+}
+
+func (r recoveryReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "recovery: state_corrupt=%t subscribers_quarantined=%t deferred=%d", r.stateCorrupt, r.subscribers != nil, r.deferred)
+	for _, p := range r.pools {
+		fmt.Fprintf(&sb, "; pool=%s persisted=%d tip=%d missed=%d outcome=%q", p.pool, p.persisted, p.tip, p.missed, p.outcome())
+	}
+	return sb.String()
+}
+
+// needsAttention is set when data was moved aside, which admins should hear
+// about even without RecoveryReport.
+func (r recoveryReport) needsAttention() bool {
+	return r.stateCorrupt || r.subscribers != nil
+}
+
+// notifyAdmins sends the report to every admin, errors are only logged.
+func (r recoveryReport) notifyAdmins(bot *tgbotapi.BotAPI, adminIDs []int64) {
+	var sb strings.Builder
+	sb.WriteString("Бот перезапущен.\n")
+	if r.stateCorrupt {
+		sb.WriteString("Файл состояния был повреждён и отложен в сторону, состояние сброшено.\n")
+	}
+	if q := r.subscribers; q != nil {
+		fmt.Fprintf(&sb, "Файл подписчиков повреждён: %d строк не прочитано, сохранено подписчиков: %d. Оригинал лежит в %s.\n", q.corrupted, q.valid, q.path)
+	}
+	if r.deferred > 0 {
+		fmt.Fprintf(&sb, "Отложенных блоков ждут /resumebot: %d\n", r.deferred)
+	}
+	for _, p := range r.pools {
+		switch {
+		case p.err != nil:
+			fmt.Fprintf(&sb, "%s: API недоступно\n", p.pool)
+		case p.persisted == 0 && p.skipFirst:
+			fmt.Fprintf(&sb, "%s: сохранённого состояния нет, текущий блок %d будет запомнен без уведомления\n", p.pool, p.tip)
+		case p.persisted == 0:
+			fmt.Fprintf(&sb, "%s: сохранённого состояния нет, текущий блок %d будет объявлен\n", p.pool, p.tip)
+		case p.missed == 0:
+			fmt.Fprintf(&sb, "%s: пропущенных блоков нет\n", p.pool)
+		default:
+			fmt.Fprintf(&sb, "%s: пропущено блоков: %d (%d → %d), будет объявлен только последний\n", p.pool, p.missed, p.persisted, p.tip)
+		}
+	}
+
+	for _, id := range adminIDs {
+		if _, err := bot.Send(tgbotapi.NewMessage(id, sb.String())); err != nil {
+			log.Printf("error: send recovery report to %s: %s", chatRef(id), err.Error())
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReconcile(t *testing.T) {
+	tests := []struct {
+		name        string
+		persisted   int
+		status      int
+		body        string
+		skipFirst   bool
+		want        poolRecovery
+		wantOutcome string
+	}{
+		{
+			name:        "up to date",
+			persisted:   103,
+			status:      200,
+			body:        blocksJSON(103, 102, 101),
+			want:        poolRecovery{persisted: 103, tip: 103},
+			wantOutcome: "up to date",
+		},
+		{
+			name:        "missed some",
+			persisted:   101,
+			status:      200,
+			body:        blocksJSON(103, 102, 101),
+			want:        poolRecovery{persisted: 101, tip: 103, missed: 2},
+			wantOutcome: "2 blocks found while down",
+		},
+		{
+			name:        "down longer than the API history",
+			persisted:   90,
+			status:      200,
+			body:        blocksJSON(103, 102, 101),
+			want:        poolRecovery{persisted: 90, tip: 103, missed: 3, atLeast: true},
+			wantOutcome: "at least 3 blocks",
+		},
+		{
+			name:        "no saved state, announced",
+			status:      200,
+			body:        blocksJSON(103),
+			want:        poolRecovery{tip: 103},
+			wantOutcome: "the current tip will be announced",
+		},
+		{
+			name:        "no saved state, skipped",
+			status:      200,
+			body:        blocksJSON(103),
+			skipFirst:   true,
+			want:        poolRecovery{tip: 103, skipFirst: true},
+			wantOutcome: "recorded without an announcement",
+		},
+		{
+			name:        "API down",
+			persisted:   101,
+			status:      500,
+			body:        "oops",
+			want:        poolRecovery{persisted: 101},
+			wantOutcome: "API unreachable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := config{
+				Pools:                   []poolConfig{{Name: "mini", URL: newPoolAPI(t, tt.status, tt.body)}},
+				SkipFirstBlockOnStartup: &tt.skipFirst,
+			}
+			n := newTestNotifier(t, conf, newTestStore(t))
+			if tt.persisted != 0 {
+				n.lastBlocks.setLastBlock("mini", block{height: tt.persisted})
+			}
+
+			report := n.reconcile()
+			if len(report.pools) != 1 {
+				t.Fatalf("report of %d pools, want 1", len(report.pools))
+			}
+			got := report.pools[0]
+			if (got.err != nil) != (tt.status != 200) {
+				t.Errorf("err = %v, want one %t", got.err, tt.status != 200)
+			}
+			got.err = nil
+			tt.want.pool = "mini"
+			if got != tt.want {
+				t.Errorf("recovery = %+v, want %+v", got, tt.want)
+			}
+			if outcome := report.pools[0].outcome(); !strings.Contains(outcome, tt.wantOutcome) {
+				t.Errorf("outcome = %q, want it to mention %q", outcome, tt.wantOutcome)
+			}
+			if report.needsAttention() {
+				t.Error("clean state needs attention")
+			}
+		})
+	}
+}
+
+func TestReconcileCorruptState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	st, err := loadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.update(func(st *state) {
+		st.Deferred = []savedBlock{{Pool: "mini", Height: 100}, {Pool: "mini", Height: 101}}
+	})
+
+	n := newNotifier(nil, newTestStore(t), config{}, &usageStats{state: st, disabled: true}, st, nil, nil)
+	report := n.reconcile()
+	if !report.stateCorrupt || !report.needsAttention() {
+		t.Errorf("report = %+v, want the corrupt state flagged", report)
+	}
+	if report.deferred != 2 {
+		t.Errorf("deferred = %d, want 2", report.deferred)
+	}
+	if s := report.String(); !strings.Contains(s, "state_corrupt=true") || !strings.Contains(s, "deferred=2") {
+		t.Errorf("report = %q", s)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const resetPrefix = "reset:"
+
+// cmdReset implements /reset. It only asks for confirmation, the reset
+// itself happens in confirmReset.
+func (r *commandRouter) cmdReset(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	resp := reply(msg, "Забыть последние блоки всех пулов? Текущие блоки будут заново объявлены всем подписчикам и вебхукам.")
+	resp.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Да", resetPrefix+"yes"),
+		tgbotapi.NewInlineKeyboardButtonData("Нет", resetPrefix+"no"),
+	))
+	return resp
+}
+
+// confirmReset handles the answer to /reset and replaces the question with
+// the outcome.
+func (r *commandRouter) confirmReset(msg *tgbotapi.Message, from *tgbotapi.User, answer string) {
+	text := "Сброс отменён"
+	if answer == "yes" {
+		if err := r.notifier.resetLastBlocks(); err != nil {
+			log.Printf("error: reset last blocks: %s", err.Error())
+			text = "Не удалось сбросить состояние"
+		} else {
+			log.Printf("last blocks reset by %s", chatRef(from.ID))
+			r.notifier.audit.record(from.ID, "reset", nil)
+			text = "Последние блоки забыты, текущие будут объявлены при следующей проверке"
+		}
+	}
+
+	if _, err := r.bot.Send(tgbotapi.NewEditMessageText(msg.Chat.ID, msg.MessageID, text)); err != nil {
+		log.Printf("error: edit reset confirmation: %s", err.Error())
+	}
+}
+
+// resetLastBlocks forgets the last block of every pool, so the next poll
+// announces the current one again, SkipFirstBlockOnStartup notwithstanding.
+func (n *Notifier) resetLastBlocks() error {
+	err := n.state.update(func(st *state) {
+		st.LastBlocks = nil
+		st.WebhookHeights = nil
+	})
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for _, pool := range n.pools {
+		n.lastBlocks.setLastBlock(pool.Name, block{})
+		n.forceAnnounce[pool.Name] = true
+	}
+	return nil
+}
+
+// takeForceAnnounce reports whether the first block of pool must be
+// announced after a reset, clearing the flag.
+func (n *Notifier) takeForceAnnounce(pool string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	force := n.forceAnnounce[pool]
+	delete(n.forceAnnounce, pool)
+	return force
+}
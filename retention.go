@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	defaultUnsubscribeRetention = 30 * 24 * time.Hour
+	retentionPurgeInterval      = time.Hour
+)
+
+// forgetChat drops everything st keeps about the chat id.
+func forgetChat(st *state, id int64) {
+	delete(st.ChatTypes, id)
+	delete(st.Names, id)
+	delete(st.HiddenNames, id)
+	delete(st.Sources, id)
+	delete(st.SkipNext, id)
+	delete(st.Templates, id)
+	delete(st.Tags, id)
+	delete(st.Witnessed, id)
+	delete(st.Unsubscribed, id)
+	for _, chats := range st.Delivered {
+		delete(chats, id)
+	}
+}
+
+// retain keeps the settings of a chat that just unsubscribed, so that
+// subscribing again within the retention period restores them.
+func (n *Notifier) retain(id int64) {
+	err := n.state.update(func(st *state) {
+		if st.Unsubscribed == nil {
+			st.Unsubscribed = make(map[int64]time.Time)
+		}
+		st.Unsubscribed[id] = time.Now()
+	})
+	if err != nil {
+		log.Printf("error: retain settings of %s: %s", chatRef(id), err.Error())
+	}
+}
+
+// winBack reports whether id had unsubscribed with its settings retained,
+// and ends the retention.
+func (n *Notifier) winBack(id int64) bool {
+	var retained bool
+	err := n.state.update(func(st *state) {
+		if _, retained = st.Unsubscribed[id]; retained {
+			delete(st.Unsubscribed, id)
+		}
+	})
+	if err != nil {
+		log.Printf("error: restore settings of %s: %s", chatRef(id), err.Error())
+	}
+	return retained
+}
+
+func (n *Notifier) retainedCount() int {
+	var count int
+	n.state.view(func(st state) {
+		count = len(st.Unsubscribed)
+	})
+	return count
+}
+
+// purgeRetained forgets the chats that unsubscribed longer than retention
+// ago. Chats that came back some other way, e.g. /add, keep their
+// settings.
+func (n *Notifier) purgeRetained(retention time.Duration) (int, error) {
+	ids, err := n.store.Subscribers()
+	if err != nil {
+		return 0, err
+	}
+	subscribed := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		subscribed[id] = true
+	}
+
+	purged := 0
+	err = n.state.update(func(st *state) {
+		for id, at := range st.Unsubscribed {
+			switch {
+			case subscribed[id]:
+				delete(st.Unsubscribed, id)
+			case time.Since(at) > retention:
+				forgetChat(st, id)
+				purged++
+			}
+		}
+	})
+	return purged, err
+}
+
+func (n *Notifier) purgeWorker(ctx context.Context, retention time.Duration) {
+	ticker := time.NewTicker(retentionPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := n.purgeRetained(retention)
+			if err != nil {
+				log.Printf("error: purge retained settings: %s", err.Error())
+				continue
+			}
+			if purged > 0 {
+				log.Printf("purged the settings of %d chats unsubscribed over %s ago", purged, retention)
+			}
+		}
+	}
+}
+
+// cmdDelete implements /delete: unsubscribe and forget the chat right away.
+func (r *commandRouter) cmdDelete(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	if err := r.store.Remove(msg.Chat.ID); err != nil {
+		log.Printf("error: delete %s: %s", chatRef(msg.Chat.ID), err.Error())
+		return reply(msg, "Не удалось удалить данные, попробуйте позже")
+	}
+
+	err := r.notifier.state.update(func(st *state) {
+		forgetChat(st, msg.Chat.ID)
+	})
+	if err != nil {
+		log.Printf("error: forget %s: %s", chatRef(msg.Chat.ID), err.Error())
+		return reply(msg, "Подписка отменена, но удалить настройки не удалось, попробуйте позже")
+	}
+
+	r.notifier.audit.record(actorOf(msg), "delete", map[string]string{"chat": strconv.FormatInt(msg.Chat.ID, 10)})
+	return reply(msg, "Подписка отменена, все данные этого чата удалены")
+}
+
+func retentionDays(retention time.Duration) string {
+	return fmt.Sprintf("%d дн.", int(retention.Hours()/24))
+}
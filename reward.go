@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+const (
+	atomicUnitsPerXMR = 1_000_000_000_000
+	// rewardTolerance is how far the API reward may be off the chain, 0.01 XMR.
+	rewardTolerance = atomicUnitsPerXMR / 100
+)
+
+// validateBlockReward reports whether the reward claimed by the pool API
+// agrees with the one on chain.
+func validateBlockReward(apiReward, nodeReward uint64) bool {
+	if apiReward > nodeReward {
+		return apiReward-nodeReward <= rewardTolerance
+	}
+	return nodeReward-apiReward <= rewardTolerance
+}
+
+// checkReward replaces the reward of b with the on-chain one when a Monero
+// node is configured. Node errors are logged and leave b untouched.
+func (n *Notifier) checkReward(pool poolConfig, b block) block {
+	if n.monero == nil || b.hash == "" {
+		return b
+	}
+
+	header, err := n.monero.GetBlockHeaderByHash(b.hash)
+	if err != nil {
+		log.Printf("error: %s: fetch block header %s: %s", pool.Name, b.hash, err.Error())
+		return b
+	}
+
+	if b.reward != 0 && !validateBlockReward(b.reward, header.Reward) {
+		log.Printf("warning: %s: block %d reward mismatch, API says %s, node says %s", pool.Name, b.height, formatXMR(b.reward), formatXMR(header.Reward))
+	}
+	b.reward = header.Reward
+
+	return b
+}
+
+func formatXMR(atomic uint64) string {
+	return fmt.Sprintf("%.6f XMR", float64(atomic)/atomicUnitsPerXMR)
+}
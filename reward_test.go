@@ -0,0 +1,58 @@
+package main
+
+import "testing"
+
+func TestValidateBlockReward(t *testing.T) {
+	const xmr = atomicUnitsPerXMR
+
+	tests := []struct {
+		name       string
+		api, node  uint64
+		wantAgrees bool
+	}{
+		{"equal", 6 * xmr / 10, 6 * xmr / 10, true},
+		{"api higher within tolerance", 600_010_000_000, 600_000_000_000, true},
+		{"node higher within tolerance", 600_000_000_000, 600_010_000_000, true},
+		{"exactly 0.01 XMR", 610_000_000_000, 600_000_000_000, true},
+		{"api higher past tolerance", 610_000_000_001, 600_000_000_000, false},
+		{"node higher past tolerance", 600_000_000_000, 610_000_000_001, false},
+		{"zero node reward", 600_000_000_000, 0, false},
+	}
+
+	for _, tt := range tests {
+		if got := validateBlockReward(tt.api, tt.node); got != tt.wantAgrees {
+			t.Errorf("%s: validateBlockReward(%d, %d) = %v, want %v", tt.name, tt.api, tt.node, got, tt.wantAgrees)
+		}
+	}
+}
+
+func TestCheckRewardUsesNodeValue(t *testing.T) {
+	const hash = "ab"
+
+	tests := []struct {
+		name       string
+		noNode     bool
+		apiReward  uint64
+		wantReward uint64
+	}{
+		{name: "mismatch", apiReward: 700_000_000_000, wantReward: 600_000_000_000},
+		{name: "api has none", apiReward: 0, wantReward: 600_000_000_000},
+		{name: "no node", noNode: true, apiReward: 700_000_000_000, wantReward: 700_000_000_000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNotifier(t, config{}, newTestStore(t))
+			if !tt.noNode {
+				n.monero = newMoneroRPCClient(newMoneroNode(t, "", "", map[string]string{
+					"get_block_header_by_hash": `{"block_header":{"hash":"ab","reward":600000000000},"status":"OK"}`,
+				}), "", "")
+			}
+
+			b := n.checkReward(poolConfig{Name: defaultPoolName}, block{height: 1, hash: hash, reward: tt.apiReward})
+			if b.reward != tt.wantReward {
+				t.Errorf("reward = %d, want %d", b.reward, tt.wantReward)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const rollupTimeLayout = "15:04"
+
+// dailyRollup summarizes the last day of a pool against the week before.
+type dailyRollup struct {
+	blocks int
+	// weekAverage is the average number of blocks a day over the last 7
+	// days, partial if the history doesn't reach back that far.
+	weekAverage float64
+	partial     bool
+	// effort is the average effort of the last day's rounds, zero if
+	// unknown.
+	effort float64
+	// reward sums the known rewards of the last day's blocks.
+	reward uint64
+}
+
+// computeDailyRollup summarizes blocks, newest first, as returned by
+// fetchRecentBlocks.
+func computeDailyRollup(blocks []block, now time.Time) dailyRollup {
+	var r dailyRollup
+	if len(blocks) == 0 {
+		return r
+	}
+
+	day := now.Add(-24 * time.Hour)
+	week := now.AddDate(0, 0, -7)
+	r.partial = blocks[len(blocks)-1].ts.After(week)
+
+	var weekBlocks, rounds int
+	var effort float64
+	for _, b := range blocks {
+		if b.ts.Before(week) {
+			break
+		}
+		weekBlocks++
+
+		if b.ts.Before(day) {
+			continue
+		}
+		r.blocks++
+		r.reward += b.reward
+		if b.effort > 0 {
+			effort += b.effort
+			rounds++
+		}
+	}
+
+	days := 7.0
+	if r.partial {
+		days = now.Sub(blocks[len(blocks)-1].ts).Hours() / 24
+	}
+	if days > 0 {
+		r.weekAverage = float64(weekBlocks) / days
+	}
+	if rounds > 0 {
+		r.effort = effort / float64(rounds)
+	}
+
+	return r
+}
+
+func (r dailyRollup) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "блоков за сутки: %d", r.blocks)
+	if r.partial {
+		fmt.Fprintf(&sb, " (в среднем %.1f в день, история неполная)", r.weekAverage)
+	} else {
+		fmt.Fprintf(&sb, " (в среднем за 7 дней %.1f в день)", r.weekAverage)
+	}
+
+	switch {
+	case r.effort == 0:
+	case r.effort <= 1:
+		fmt.Fprintf(&sb, "\nсреднее усилие %s — удачные сутки", formatEffort(r.effort))
+	default:
+		fmt.Fprintf(&sb, "\nсреднее усилие %s — неудачные сутки", formatEffort(r.effort))
+	}
+
+	if r.reward > 0 {
+		fmt.Fprintf(&sb, "\nнаграда за блоки: около %s", formatXMR(r.reward))
+	}
+	return sb.String()
+}
+
+// nextRollup returns the first time after now at the given clock time,
+// in now's location.
+func nextRollup(now time.Time, at time.Time) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), at.Hour(), at.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// rollupWorker posts the daily rollup to channelID every day at the clock
+// time at, local time.
+func (n *Notifier) rollupWorker(ctx context.Context, at time.Time, channelID int64) {
+	for {
+		timer := time.NewTimer(time.Until(nextRollup(time.Now(), at)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		// A restart right around the rollup time mustn't post it twice.
+		var last time.Time
+		n.state.view(func(st state) {
+			last = st.LastRollup
+		})
+		if time.Since(last) < 12*time.Hour {
+			continue
+		}
+
+		if err := n.postRollup(channelID); err != nil {
+			log.Printf("error: post daily rollup: %s", err.Error())
+			continue
+		}
+
+		err := n.state.update(func(st *state) {
+			st.LastRollup = time.Now()
+		})
+		if err != nil {
+			log.Printf("error: save rollup time: %s", err.Error())
+		}
+	}
+}
+
+func (n *Notifier) postRollup(channelID int64) error {
+	now := time.Now()
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Итоги суток на %s\n", now.Format(n.timeFormat))
+	for _, pool := range n.pools {
+		blocks, err := fetchRecentBlocks(pool.URL)
+		if err != nil {
+			log.Printf("error: %s: fetch recent blocks: %s", pool.Name, err.Error())
+			fmt.Fprintf(&sb, "\n%s: API недоступно", pool.Name)
+			continue
+		}
+		fmt.Fprintf(&sb, "\n%s: %s", pool.Name, computeDailyRollup(blocks, now))
+	}
+
+	_, err := n.bot.Send(tgbotapi.NewMessage(channelID, sb.String()))
+	return err
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// maxCommandInput is how much of an incoming message command parsing
+	// looks at; Telegram allows 4096 characters.
+	maxCommandInput = 512
+	// maxEchoLength caps user input quoted back in replies and logs.
+	maxEchoLength = 64
+)
+
+// limitInput trims msg text to maxCommandInput runes before it is parsed.
+func limitInput(text string) string {
+	return truncateRunes(text, maxCommandInput)
+}
+
+// sanitize makes user-provided text safe to log or quote in a reply: it
+// drops control characters, including newlines that could forge log lines
+// and bidi overrides that could reorder the surrounding text, and caps the
+// length.
+func sanitize(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || unicode.Is(unicode.Bidi_Control, r) || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, s)
+
+	if utf8.RuneCountInString(s) > maxEchoLength {
+		s = truncateRunes(s, maxEchoLength) + "…"
+	}
+	return s
+}
+
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// splitMessage cuts text into parts of at most n runes, between lines
+// where it can.
+func splitMessage(text string, n int) []string {
+	var parts []string
+	var part strings.Builder
+	partLen := 0
+	for _, line := range strings.SplitAfter(text, "\n") {
+		for lineLen := utf8.RuneCountInString(line); lineLen > 0; lineLen = utf8.RuneCountInString(line) {
+			if partLen+lineLen <= n {
+				part.WriteString(line)
+				partLen += lineLen
+				break
+			}
+			if partLen > 0 {
+				parts = append(parts, part.String())
+				part.Reset()
+				partLen = 0
+				continue
+			}
+			// A line longer than a whole part.
+			head := truncateRunes(line, n)
+			parts = append(parts, head)
+			line = line[len(head):]
+		}
+	}
+	if partLen > 0 {
+		parts = append(parts, part.String())
+	}
+	return parts
+}
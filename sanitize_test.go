@@ -0,0 +1,144 @@
+package main
+
+import (
+	"log"
+	"slices"
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		n    int
+		want []string
+	}{
+		{"empty", "", 10, nil},
+		{"fits", "one\ntwo", 10, []string{"one\ntwo"}},
+		{"between lines", "one\ntwo\nthree", 8, []string{"one\ntwo\n", "three"}},
+		{"long line", "abcdefghij\nk", 4, []string{"abcd", "efgh", "ij\nk"}},
+		{"runes", "ыыыыы", 2, []string{"ыы", "ыы", "ы"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitMessage(tt.text, tt.n)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("splitMessage = %q, want %q", got, tt.want)
+			}
+			if strings.Join(got, "") != tt.text {
+				t.Errorf("parts %q don't add up to the text", got)
+			}
+			for _, part := range got {
+				if utf8.RuneCountInString(part) > tt.n {
+					t.Errorf("part %q is over %d runes", part, tt.n)
+				}
+			}
+		})
+	}
+}
+
+// adversarialInputs are user messages meant to break replies, logs or the
+// bot itself.
+var adversarialInputs = []string{
+	"\u202etxt.exe",
+	"a\x00b\x00c",
+	"*bold* _it_ [link](tg://user?id=1) `code` <b>html</b>",
+	"x\nlevel=ERROR msg=forged\r\n",
+	"\xff\xfe not UTF-8 \xc3",
+	strings.Repeat("я", 4096),
+	strings.Repeat("\u202e", 1000),
+	"{{.Height}}{{printf \"%s\" 1}}",
+	"%s%d%n%x%!",
+	"../../etc/passwd",
+	"",
+}
+
+func FuzzSanitize(f *testing.F) {
+	for _, in := range adversarialInputs {
+		f.Add(in)
+	}
+	f.Fuzz(func(t *testing.T, in string) {
+		out := sanitize(in)
+		if !utf8.ValidString(out) {
+			t.Errorf("sanitize(%q) = %q, not valid UTF-8", in, out)
+		}
+		for _, r := range out {
+			if unicode.IsControl(r) || unicode.Is(unicode.Bidi_Control, r) {
+				t.Errorf("sanitize(%q) = %q, keeps %U", in, out, r)
+			}
+		}
+		if n := utf8.RuneCountInString(out); n > maxEchoLength+1 {
+			t.Errorf("sanitize(%q) is %d runes long, want at most %d", in, n, maxEchoLength+1)
+		}
+	})
+}
+
+func FuzzLimitInput(f *testing.F) {
+	for _, in := range adversarialInputs {
+		f.Add(in)
+	}
+	f.Fuzz(func(t *testing.T, in string) {
+		out := limitInput(in)
+		if n := utf8.RuneCountInString(out); n > maxCommandInput {
+			t.Errorf("limitInput kept %d runes, want at most %d", n, maxCommandInput)
+		}
+		if utf8.ValidString(in) && !strings.HasPrefix(in, out) {
+			t.Errorf("limitInput(%q) = %q, not a prefix", in, out)
+		}
+		if utf8.ValidString(in) && !utf8.ValidString(out) {
+			t.Errorf("limitInput(%q) = %q, cut a character", in, out)
+		}
+	})
+}
+
+// TestAdversarialInputThroughRouter feeds every adversarial input through
+// the commands that look at or echo their argument.
+func TestAdversarialInputThroughRouter(t *testing.T) {
+	const admin = 1
+
+	logs := captureLogs(t)
+	tg := newFakeTelegram(t)
+	store := newTestStore(t)
+	conf := config{AdminIDs: []int64{admin}}
+	n := newTestNotifier(t, conf, store)
+	n.bot = tg.bot(t)
+	r := newCommandRouter(n.bot, store, n, n.usage, conf)
+
+	for _, in := range adversarialInputs {
+		// Telegram marks only valid command names as commands, so "/" + in
+		// arrives as text.
+		msgs := []*tgbotapi.Message{textMessage(admin, in), textMessage(admin, "/"+in)}
+		for _, cmd := range []string{"/luck ", "/diff ", "/subscribers find ", "/subscribers list "} {
+			msgs = append(msgs, command(admin, cmd+in))
+		}
+		for _, msg := range msgs {
+			msg.From.UserName = in
+			r.handle(msg)
+		}
+	}
+
+	for _, reply := range tg.sentTexts() {
+		if !utf8.ValidString(reply) {
+			t.Errorf("reply %q is not valid UTF-8", reply)
+		}
+		if strings.ContainsAny(reply, "\x00\u202e") {
+			t.Errorf("reply %q echoes control characters", reply)
+		}
+	}
+
+	// As main logs every message.
+	for _, in := range adversarialInputs {
+		log.Printf("[%s] %s", sanitize(in), sanitize(in))
+	}
+	for _, line := range strings.Split(logs.String(), "\n") {
+		if strings.HasPrefix(line, "level=ERROR msg=forged") {
+			t.Errorf("forged log line %q", line)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	seenPrefix = "seen:"
+
+	// seenBlocksKept is how many of the latest blocks keep their Seen
+	// counts; presses on older notifications aren't counted.
+	seenBlocksKept = 100
+)
+
+func seenKeyboard(height, count int) tgbotapi.InlineKeyboardMarkup {
+	label := "👍 Seen"
+	if count > 0 {
+		label = fmt.Sprintf("👍 Seen · %d", count)
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(label, seenPrefix+strconv.Itoa(height)),
+	))
+}
+
+// notificationKeyboard is the button under a notification about the block
+// at height sent to chat id, if any: channels with SeenButtons get a Seen
+// counter, other chats the acknowledgment button.
+func (n *Notifier) notificationKeyboard(id int64, height int) (tgbotapi.InlineKeyboardMarkup, bool) {
+	if n.seenButtons && n.chatType(id) == "channel" {
+		return seenKeyboard(height, n.seenCount(height)), true
+	}
+	if n.ackButtons {
+		return ackKeyboard(height), true
+	}
+	return tgbotapi.InlineKeyboardMarkup{}, false
+}
+
+func (n *Notifier) seenCount(height int) int {
+	count := 0
+	n.state.view(func(st state) { count = len(st.Seen[height]) })
+	return count
+}
+
+// markSeen counts userID as having seen the block at height, once per user,
+// and returns the count.
+func (n *Notifier) markSeen(height int, userID int64) (int, error) {
+	count := 0
+	err := n.state.update(func(st *state) {
+		if st.Seen == nil {
+			st.Seen = make(map[int][]int64)
+		}
+		if !slices.Contains(st.Seen[height], userID) {
+			st.Seen[height] = append(st.Seen[height], userID)
+		}
+		count = len(st.Seen[height])
+
+		if len(st.Seen) > seenBlocksKept {
+			heights := make([]int, 0, len(st.Seen))
+			for h := range st.Seen {
+				heights = append(heights, h)
+			}
+			slices.Sort(heights)
+			for _, h := range heights[:len(heights)-seenBlocksKept] {
+				delete(st.Seen, h)
+			}
+		}
+	})
+	return count, err
+}
+
+// markSeenFromButton handles a press of the Seen button under msg and
+// updates its label with the new count. It returns the text shown to the
+// user.
+func (r *commandRouter) markSeenFromButton(msg *tgbotapi.Message, from *tgbotapi.User, height string) string {
+	h, err := strconv.Atoi(height)
+	if err != nil {
+		return ""
+	}
+
+	count, err := r.notifier.markSeen(h, from.ID)
+	if err != nil {
+		log.Printf("error: record seen of block %d: %s", h, err.Error())
+		return "Не удалось сохранить отметку"
+	}
+
+	edit := tgbotapi.NewEditMessageReplyMarkup(msg.Chat.ID, msg.MessageID, seenKeyboard(h, count))
+	if _, err := r.bot.Request(edit); err != nil {
+		// Telegram rejects edits that change nothing, e.g. a second press.
+		log.Printf("warning: update seen button: %s", err.Error())
+	}
+	return "👍"
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestSeenButtonCounts(t *testing.T) {
+	const channel, height = -1001, 3000000
+
+	tg := newFakeTelegram(t)
+	store := newTestStore(t)
+	conf := config{SeenButtons: true}
+	n := newTestNotifier(t, conf, store)
+	n.bot = tg.bot(t)
+	r := newCommandRouter(n.bot, store, n, n.usage, conf)
+
+	presses := []struct {
+		user      int64
+		wantCount int
+	}{
+		{10, 1},
+		{11, 2},
+		// A second press by the same user doesn't count.
+		{10, 2},
+		{12, 3},
+	}
+
+	for i, p := range presses {
+		r.handleCallback(&tgbotapi.CallbackQuery{
+			ID:      strconv.Itoa(i),
+			From:    &tgbotapi.User{ID: p.user},
+			Message: &tgbotapi.Message{MessageID: 5, Chat: &tgbotapi.Chat{ID: channel, Type: "channel"}},
+			Data:    seenPrefix + strconv.Itoa(height),
+		})
+
+		if got := n.seenCount(height); got != p.wantCount {
+			t.Errorf("press %d: count = %d, want %d", i, got, p.wantCount)
+		}
+		markups := tg.editedMarkups()
+		if len(markups) != i+1 {
+			t.Fatalf("press %d: %d button updates, want %d", i, len(markups), i+1)
+		}
+		if want := "Seen · " + strconv.Itoa(p.wantCount); !strings.Contains(markups[i], want) {
+			t.Errorf("press %d: button %s, want the label %q", i, markups[i], want)
+		}
+	}
+}
+
+func TestNotificationKeyboard(t *testing.T) {
+	const channel, private = -1001, 1
+
+	tests := []struct {
+		name     string
+		conf     config
+		chat     int64
+		wantData string
+		wantNone bool
+	}{
+		{name: "seen in channel", conf: config{SeenButtons: true, DisableAckButtons: true}, chat: channel, wantData: seenPrefix + "7"},
+		{name: "ack in private chats", conf: config{SeenButtons: true}, chat: private, wantData: ackPrefix + "7"},
+		{name: "seen not in private chats", conf: config{SeenButtons: true, DisableAckButtons: true}, chat: private, wantNone: true},
+		{name: "ack", conf: config{}, chat: channel, wantData: ackPrefix + "7"},
+		{name: "none", conf: config{DisableAckButtons: true}, chat: channel, wantNone: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNotifier(t, tt.conf, newTestStore(t))
+			n.rememberChatType(channel, "channel")
+			n.rememberChatType(private, "private")
+
+			keyboard, ok := n.notificationKeyboard(tt.chat, 7)
+			if ok == tt.wantNone {
+				t.Fatalf("keyboard = %v, want one %v", ok, !tt.wantNone)
+			}
+			if ok && *keyboard.InlineKeyboard[0][0].CallbackData != tt.wantData {
+				t.Errorf("button data = %q, want %q", *keyboard.InlineKeyboard[0][0].CallbackData, tt.wantData)
+			}
+		})
+	}
+}
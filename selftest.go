@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+const apiStartupCheckTimeout = 30 * time.Second
+
+// checkPoolAPIs fetches the last block of every pool once, so the bot
+// doesn't take subscriptions while it can't see any pool. Pools that are
+// down while others are up are only logged; polling retries them.
+func checkPoolAPIs(pools []poolConfig, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	blocks, errs, err := fetchAllPools(ctx, pools)
+	if err != nil {
+		return fmt.Errorf("pool API check: %w", err)
+	}
+
+	for _, pool := range pools {
+		if err, ok := errs[pool.Name]; ok {
+			log.Printf("warning: pool %s API is down, starting anyway: %s", pool.Name, err.Error())
+			continue
+		}
+		b := blocks[pool.Name]
+		log.Printf("pool %s API is up, last block %d at %s", pool.Name, b.height, b.ts.Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckPoolAPIs(t *testing.T) {
+	up := newPoolAPI(t, http.StatusOK, blocksJSON(4500111))
+	down := newPoolAPI(t, http.StatusBadGateway, "")
+	garbage := newPoolAPI(t, http.StatusOK, "<html>")
+
+	tests := []struct {
+		name    string
+		pools   []poolConfig
+		wantErr bool
+	}{
+		{name: "all up", pools: []poolConfig{{Name: "mini", URL: up}, {Name: "main", URL: up}}},
+		{name: "one down", pools: []poolConfig{{Name: "mini", URL: up}, {Name: "main", URL: down}}},
+		{name: "all down", pools: []poolConfig{{Name: "mini", URL: down}, {Name: "main", URL: garbage}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkPoolAPIs(tt.pools, time.Second)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error: %t", err, tt.wantErr)
+			}
+			if err != nil && !strings.HasPrefix(err.Error(), "pool API check: ") {
+				t.Errorf("error = %q, want it to name the check", err)
+			}
+		})
+	}
+}
+
+func TestCheckPoolAPIsTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	start := time.Now()
+	err := checkPoolAPIs([]poolConfig{{Name: "mini", URL: srv.URL}}, 100*time.Millisecond)
+	if err == nil {
+		t.Fatal("no error from a pool that never answers")
+	}
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("check took %s, want it to give up after the timeout", took)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// sendInterval spaces out the messages of broadcasts and pushes to stay
+	// below the Telegram limit of about 30 messages a second.
+	sendInterval = 40 * time.Millisecond
+	// sendAttempts is how often a message is tried when Telegram answers
+	// 429 Too Many Requests.
+	sendAttempts = 3
+)
+
+// sendQueue hands out send slots sendInterval apart to every broadcast and
+// push at once, so that two of them running together still keep to the
+// rate limit.
+type sendQueue struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+
+	waiting atomic.Int64
+}
+
+func newSendQueue(interval time.Duration) *sendQueue {
+	return &sendQueue{interval: interval}
+}
+
+// wait blocks until the next free slot or until ctx is done.
+func (q *sendQueue) wait(ctx context.Context) error {
+	q.waiting.Add(1)
+	defer q.waiting.Add(-1)
+
+	q.mu.Lock()
+	at := q.next
+	if now := time.Now(); at.Before(now) {
+		at = now
+	}
+	q.next = at.Add(q.interval)
+	q.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(at))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// backOff holds every sender back for d, as Telegram asked with a 429.
+func (q *sendQueue) backOff(d time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if until := time.Now().Add(d); q.next.Before(until) {
+		q.next = until
+	}
+}
+
+// depth is the number of messages waiting for a slot.
+func (q *sendQueue) depth() int {
+	return int(q.waiting.Load())
+}
+
+// send delivers msg in its turn of the send queue. A 429 holds the queue
+// back for as long as Telegram asks and the message is tried again, up to
+// sendAttempts times.
+func (n *Notifier) send(ctx context.Context, msg tgbotapi.MessageConfig) (tgbotapi.Message, error) {
+	for attempt := 1; ; attempt++ {
+		if err := n.sends.wait(ctx); err != nil {
+			return tgbotapi.Message{}, err
+		}
+
+		sent, err := n.sendMigrating(ctx, msg)
+		var tgErr *tgbotapi.Error
+		if !errors.As(err, &tgErr) || tgErr.RetryAfter == 0 || attempt == sendAttempts {
+			return sent, err
+		}
+
+		retryAfter := time.Duration(tgErr.RetryAfter) * time.Second
+		logger(ctx).Warn("rate limited by Telegram, retrying", "chat", chatRef(msg.ChatID), "after", retryAfter)
+		n.sends.backOff(retryAfter)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSendQueueSpacesSends(t *testing.T) {
+	const interval, senders, perSender = 10 * time.Millisecond, 3, 4
+	q := newSendQueue(interval)
+
+	started := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perSender; j++ {
+				if err := q.wait(context.Background()); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if elapsed, want := time.Since(started), (senders*perSender-1)*interval; elapsed < want {
+		t.Errorf("%d sends took %s, want at least %s", senders*perSender, elapsed, want)
+	}
+	if depth := q.depth(); depth != 0 {
+		t.Errorf("depth = %d after every send, want 0", depth)
+	}
+}
+
+func TestSendQueueCanceled(t *testing.T) {
+	q := newSendQueue(time.Hour)
+	q.wait(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := q.wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("wait = %v, want the context error", err)
+	}
+}
+
+func TestPushRateLimited(t *testing.T) {
+	tests := []struct {
+		name       string
+		throttled  int
+		wantSent   int
+		wantFailed int
+	}{
+		{"not limited", 0, 3, 0},
+		{"retried after 429", 1, 3, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tg := newFakeTelegram(t)
+			tg.throttle(2, tt.throttled)
+			store := newTestStore(t)
+			for _, id := range []int64{1, 2, 3} {
+				store.Add(id)
+			}
+			n := newTestNotifier(t, config{}, store)
+			n.bot = tg.bot(t)
+
+			started := time.Now()
+			sent, failed, err := n.push(context.Background(), "", "maintenance")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sent != tt.wantSent || failed != tt.wantFailed {
+				t.Errorf("sent %d, failed %d; want %d, %d", sent, failed, tt.wantSent, tt.wantFailed)
+			}
+			if wait := time.Duration(tt.throttled) * time.Second; time.Since(started) < wait {
+				t.Errorf("push took %s, want it to wait out the %s Telegram asked for", time.Since(started), wait)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// cmdSkipNext implements /skipnext [cancel]: the chat isn't notified about
+// the next block, of any pool, and the skip clears itself.
+func (r *commandRouter) cmdSkipNext(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	cancel := strings.TrimSpace(msg.CommandArguments()) == "cancel"
+
+	var armed bool
+	err := r.notifier.state.update(func(st *state) {
+		armed = st.SkipNext[msg.Chat.ID]
+		if cancel {
+			delete(st.SkipNext, msg.Chat.ID)
+			return
+		}
+		if st.SkipNext == nil {
+			st.SkipNext = make(map[int64]bool)
+		}
+		st.SkipNext[msg.Chat.ID] = true
+	})
+	if err != nil {
+		log.Printf("error: save skip of %s: %s", chatRef(msg.Chat.ID), err.Error())
+		return reply(msg, "Не удалось сохранить настройку")
+	}
+
+	switch {
+	case cancel && armed:
+		return reply(msg, "Пропуск отменён, следующий блок придёт как обычно")
+	case cancel:
+		return reply(msg, "Пропуск и не был включён")
+	case armed:
+		return reply(msg, "Пропуск уже включён: уведомление о следующем блоке не придёт. Отменить: /skipnext cancel")
+	default:
+		return reply(msg, "Уведомление о следующем блоке не придёт, дальше всё как обычно. Отменить: /skipnext cancel")
+	}
+}
+
+// skipsNext returns the chats among ids that asked to skip the next block.
+func (n *Notifier) skipsNext(ids []int64) map[int64]bool {
+	skip := make(map[int64]bool)
+	n.state.view(func(st state) {
+		for _, id := range ids {
+			if st.SkipNext[id] {
+				skip[id] = true
+			}
+		}
+	})
+	return skip
+}
+
+// clearSkips disarms /skipnext of chats that just skipped a block.
+func (n *Notifier) clearSkips(ids []int64) {
+	if len(ids) == 0 {
+		return
+	}
+
+	err := n.state.update(func(st *state) {
+		for _, id := range ids {
+			delete(st.SkipNext, id)
+		}
+	})
+	if err != nil {
+		log.Printf("error: clear skips: %s", err.Error())
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// checkStale alerts the admins once when the newest block of pool is older
+// than staleAfter, which suggests the API is stuck, and again once fresh
+// blocks come back.
+func (n *Notifier) checkStale(ctx context.Context, pool poolConfig, b block) {
+	if n.staleAfter == 0 || n.inMaintenance() {
+		return
+	}
+
+	age := time.Since(b.ts)
+	stale := age > n.staleAfter
+
+	n.mu.Lock()
+	changed := n.staleAlerted[pool.Name] != stale
+	n.staleAlerted[pool.Name] = stale
+	n.mu.Unlock()
+	if !changed {
+		return
+	}
+
+	var text string
+	if stale {
+		logger(ctx).Warn("pool API may serve stale data", "height", b.height, "age", age.Round(time.Second))
+		text = fmt.Sprintf("⚠️ %s: последний блок в API (%d) старше %s, возможно API отдаёт устаревшие данные", pool.Name, b.height, age.Round(time.Minute))
+	} else {
+		logger(ctx).Info("pool API data fresh again", "height", b.height)
+		text = fmt.Sprintf("✅ %s: API снова отдаёт свежие данные, последний блок %d", pool.Name, b.height)
+	}
+
+	for id := range n.admins {
+		if _, err := n.bot.Send(tgbotapi.NewMessage(id, text)); err != nil {
+			logger(ctx).Error("send stale alert", "chat", chatRef(id), "err", err)
+		}
+	}
+}
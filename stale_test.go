@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckStale(t *testing.T) {
+	tg := newFakeTelegram(t)
+	n := newTestNotifier(t, config{StaleBlockAfter: "1h", AdminIDs: []int64{7}}, newTestStore(t))
+	n.bot = tg.bot(t)
+	pool := poolConfig{Name: defaultPoolName}
+
+	// The steps run in order against the same notifier.
+	steps := []struct {
+		name        string
+		age         time.Duration
+		maintenance bool
+		wantAlert   string
+	}{
+		{name: "fresh", age: time.Minute},
+		{name: "goes stale", age: 2 * time.Hour, wantAlert: "⚠️ mini: последний блок в API (102) старше 2h0m0s"},
+		{name: "still stale", age: 3 * time.Hour},
+		{name: "fresh again", age: time.Minute, wantAlert: "✅ mini: API снова отдаёт свежие данные, последний блок 104"},
+		{name: "stale during maintenance", age: 2 * time.Hour, maintenance: true},
+		{name: "stale after maintenance", age: 2 * time.Hour, wantAlert: "⚠️ mini:"},
+	}
+
+	for i, step := range steps {
+		n.state.update(func(st *state) {
+			st.MaintenanceUntil = time.Time{}
+			if step.maintenance {
+				st.MaintenanceUntil = time.Now().Add(time.Hour)
+			}
+		})
+		before := len(tg.sentTexts())
+		n.checkStale(context.Background(), pool, block{height: 101 + i, ts: time.Now().Add(-step.age)})
+
+		texts := tg.sentTexts()[before:]
+		switch {
+		case step.wantAlert == "" && len(texts) > 0:
+			t.Errorf("%s: alerted %q", step.name, texts)
+		case step.wantAlert != "" && (len(texts) != 1 || !strings.HasPrefix(texts[0], step.wantAlert)):
+			t.Errorf("%s: alerts = %q, want one starting with %q", step.name, texts, step.wantAlert)
+		}
+	}
+	if sent := tg.sentTo(); slices.ContainsFunc(sent, func(id int64) bool { return id != 7 }) {
+		t.Errorf("alerts sent to %v, want only the admin", sent)
+	}
+}
+
+func TestCheckStaleDisabled(t *testing.T) {
+	tg := newFakeTelegram(t)
+	n := newTestNotifier(t, config{AdminIDs: []int64{7}}, newTestStore(t))
+	n.bot = tg.bot(t)
+
+	n.checkStale(context.Background(), poolConfig{Name: defaultPoolName}, block{height: 101, ts: time.Now().Add(-24 * time.Hour)})
+	if texts := tg.sentTexts(); len(texts) != 0 {
+		t.Errorf("alerted %q without StaleBlockAfter", texts)
+	}
+}
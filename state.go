@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// state is everything the bot keeps between restarts apart from the
+// subscribers themselves.
+type state struct {
+	Usage usageCounters `json:"usage"`
+
+	// ChatTypes caches the Telegram chat type of subscribers by chat ID.
+	ChatTypes map[int64]string `json:"chat_types,omitempty"`
+
+	// Names are the usernames or titles of chats, for /subscribers find.
+	// HiddenNames are the chats that asked with /hidename not to keep one.
+	Names       map[int64]string `json:"names,omitempty"`
+	HiddenNames map[int64]bool   `json:"hidden_names,omitempty"`
+
+	// Sources is the deep-link source each chat subscribed through, for
+	// chats that came in through one.
+	Sources map[int64]string `json:"sources,omitempty"`
+
+	// SkipNext marks chats that asked with /skipnext not to be notified
+	// about the next block.
+	SkipNext map[int64]bool `json:"skip_next,omitempty"`
+
+	// Templates holds the notification templates chats set with /template.
+	Templates map[int64]string `json:"templates,omitempty"`
+
+	// Unsubscribed is when chats unsubscribed, for the chats whose
+	// settings are kept in case they come back.
+	Unsubscribed map[int64]time.Time `json:"unsubscribed,omitempty"`
+
+	// DeliveryFailures counts the failed deliveries in a row per chat, see
+	// PruneAfterFailures.
+	DeliveryFailures map[int64]int `json:"delivery_failures,omitempty"`
+
+	// Tags are the labels admins gave chats with /tag, for /broadcast.
+	Tags map[int64][]string `json:"tags,omitempty"`
+
+	// Paused holds back block notifications, collecting them in Deferred
+	// until an admin resumes the bot.
+	Paused   bool         `json:"paused,omitempty"`
+	Deferred []savedBlock `json:"deferred,omitempty"`
+
+	// LastBlocks is the last block seen of every pool, so a restart doesn't
+	// announce it again.
+	LastBlocks map[string]savedBlock `json:"last_blocks,omitempty"`
+
+	// Delivered is the last block height each chat was notified about,
+	// per pool.
+	Delivered map[string]map[int64]int `json:"delivered,omitempty"`
+
+	// WebhookHeights is the last block height delivered to webhooks per
+	// pool, so a restart doesn't post it twice.
+	WebhookHeights map[string]int `json:"webhook_heights,omitempty"`
+
+	// Witnessed is what each chat went through as a subscriber, for /me.
+	Witnessed map[int64]witnessStats `json:"witnessed,omitempty"`
+
+	// Seen holds the users who pressed the Seen button under the
+	// notifications about a block, by height.
+	Seen map[int][]int64 `json:"seen,omitempty"`
+
+	// Milestones are the found block counts already announced, per pool.
+	Milestones map[string][]int `json:"milestones,omitempty"`
+
+	// MaintenanceUntil is the end of the maintenance set with /maintenance.
+	// MaintenanceAnnounced is the end of the maintenance subscribers were
+	// last told about, zero once they got the all-clear.
+	MaintenanceUntil     time.Time `json:"maintenance_until,omitempty"`
+	MaintenanceAnnounced time.Time `json:"maintenance_announced,omitempty"`
+
+	// LogSalt keys the chat ID hashes in the logs, see LogRedactChatIDs.
+	LogSalt string `json:"log_salt,omitempty"`
+
+	// LastRollup is when the daily rollup was last posted.
+	LastRollup time.Time `json:"last_rollup,omitempty"`
+}
+
+type savedBlock struct {
+	Pool      string    `json:"pool"`
+	Height    int       `json:"height"`
+	Timestamp time.Time `json:"ts"`
+	Hash      string    `json:"hash,omitempty"`
+	Reward    uint64    `json:"reward,omitempty"`
+	Effort    float64   `json:"effort,omitempty"`
+}
+
+func newSavedBlock(pool string, b block) savedBlock {
+	return savedBlock{Pool: pool, Height: b.height, Timestamp: b.ts, Hash: b.hash, Reward: b.reward, Effort: b.effort}
+}
+
+func (d savedBlock) block() block {
+	return block{height: d.Height, ts: d.Timestamp, hash: d.Hash, reward: d.Reward, effort: d.Effort}
+}
+
+// stateStore guards the persisted state. With an empty path the state only
+// lives in memory.
+type stateStore struct {
+	path string
+	// corrupt is set when the state file couldn't be read and was moved
+	// aside, leaving an empty state.
+	corrupt bool
+
+	mu   sync.Mutex
+	data state
+}
+
+func loadState(path string) (*stateStore, error) {
+	s := &stateStore{path: path}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &s.data); err != nil {
+		aside := fmt.Sprintf("%s.corrupt-%d", path, time.Now().Unix())
+		if err := os.Rename(path, aside); err != nil {
+			return nil, err
+		}
+		log.Printf("warning: state file %s is corrupt (%s), moved to %s and starting afresh", path, err.Error(), aside)
+
+		s.data = state{}
+		s.corrupt = true
+	}
+
+	return s, nil
+}
+
+// update applies fn to the state and writes the result to disk.
+func (s *stateStore) update(fn func(st *state)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fn(&s.data)
+	return s.save()
+}
+
+// view calls fn with the current state. fn must not keep references to it.
+func (s *stateStore) view(fn func(st state)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fn(s.data)
+}
+
+// save atomically replaces the state file. Must be called with s.mu held.
+func (s *stateStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// statusSnapshot is the state of the bot at one instant, taken under a
+// single lock. Every output formats a snapshot rather than reading the
+// Notifier itself, so they agree on the times they show.
+type statusSnapshot struct {
+	takenAt          time.Time
+	startedAt        time.Time
+	lastBlocks       map[string]block
+	fetches          map[string]poolFetch
+	maintenanceUntil time.Time
+}
+
+// snapshot is taken under mu, which every writer of the last blocks and the
+// fetches holds as well, so the times in it belong together.
+func (n *Notifier) snapshot() statusSnapshot {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	now := time.Now()
+	until, _ := n.maintenanceUntil(now)
+	fetches := make(map[string]poolFetch, len(n.fetches))
+	for name, f := range n.fetches {
+		fetches[name] = *f
+	}
+	return statusSnapshot{
+		takenAt:          now,
+		startedAt:        n.startedAt,
+		lastBlocks:       n.lastBlocks.all(),
+		fetches:          fetches,
+		maintenanceUntil: until,
+	}
+}
+
+// fetchAge is how long before the snapshot the API of pool was last
+// reached, or false if it never was.
+func (s statusSnapshot) fetchAge(pool string) (time.Duration, bool) {
+	f := s.fetches[pool]
+	if f.lastSuccess.IsZero() {
+		return 0, false
+	}
+	return s.takenAt.Sub(f.lastSuccess), true
+}
+
+// poolNames are the pools in s, sorted.
+func (s statusSnapshot) poolNames() []string {
+	names := make([]string, 0, len(s.fetches))
+	for name := range s.fetches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// healthResponse presents s for /healthz, times in RFC 3339. It is
+// unhealthy as soon as one pool API hasn't been reached for maxFetchAge;
+// the top-level fetch time is that of the pool reached longest ago.
+func (s statusSnapshot) healthResponse(maxFetchAge time.Duration) healthResponse {
+	resp := healthResponse{
+		Status:      "ok",
+		LastHeights: make(map[string]int, len(s.lastBlocks)),
+		StartedAt:   timeOrNil(s.startedAt),
+		Pools:       make(map[string]poolFetchStatus, len(s.fetches)),
+	}
+	for name, b := range s.lastBlocks {
+		resp.LastHeights[name] = b.height
+	}
+
+	var oldestFetch, lastBlockSeen time.Time
+	var fetchErrors []string
+	for i, name := range s.poolNames() {
+		f := s.fetches[name]
+		resp.Pools[name] = f.status()
+		if age, ok := s.fetchAge(name); !ok || age > maxFetchAge {
+			resp.Status = "unhealthy"
+		}
+		if i == 0 || f.lastSuccess.Before(oldestFetch) {
+			oldestFetch = f.lastSuccess
+		}
+		if f.lastBlockSeenAt.After(lastBlockSeen) {
+			lastBlockSeen = f.lastBlockSeenAt
+		}
+		if f.lastError != nil {
+			fetchErrors = append(fetchErrors, name+": "+f.lastError.Error())
+		}
+	}
+	resp.LastSuccessfulFetch = timeOrNil(oldestFetch)
+	resp.LastBlockSeenAt = timeOrNil(lastBlockSeen)
+	resp.LastFetchError = strings.Join(fetchErrors, "; ")
+	return resp
+}
+
+// fetchText presents the polling of every pool in s for /stats.
+func (s statusSnapshot) fetchText() string {
+	var sb strings.Builder
+	sb.WriteString("Опрос API пулов:")
+	for _, name := range s.poolNames() {
+		f := s.fetches[name]
+		fmt.Fprintf(&sb, "\n%s — ", name)
+		if age, ok := s.fetchAge(name); ok {
+			fmt.Fprintf(&sb, "успешно %s назад", age.Round(time.Second))
+		} else {
+			sb.WriteString("ни одного успешного опроса")
+		}
+		if !f.lastBlockSeenAt.IsZero() {
+			fmt.Fprintf(&sb, ", новый блок замечен %s назад", s.takenAt.Sub(f.lastBlockSeenAt).Round(time.Second))
+		}
+		if f.lastError != nil {
+			fmt.Fprintf(&sb, ", ошибка: %s", f.lastError.Error())
+		}
+	}
+	return sb.String()
+}
+
+// poolsText presents s for /pools, times relative to the snapshot.
+func (s statusSnapshot) poolsText(pools []poolConfig) string {
+	var sb strings.Builder
+	sb.WriteString("Отслеживаемые пулы:\n")
+	for _, pool := range pools {
+		b := s.lastBlocks[pool.Name]
+		if b.height == 0 {
+			fmt.Fprintf(&sb, "%s — нет данных\n", pool.Name)
+			continue
+		}
+		fmt.Fprintf(&sb, "%s — высота %d, последний блок %s назад\n", pool.Name, b.height, s.takenAt.Sub(b.ts).Round(time.Second))
+	}
+	if !s.maintenanceUntil.IsZero() {
+		fmt.Fprintf(&sb, "\n🔧 Технические работы до %s, предупреждения отключены\n", s.maintenanceUntil.Format("15:04 MST"))
+	}
+	fmt.Fprintf(&sb, "\nБот работает %s", s.takenAt.Sub(s.startedAt).Round(time.Second))
+	return sb.String()
+}
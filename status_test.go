@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHealthResponsePerPool(t *testing.T) {
+	now := time.Now()
+	const maxFetchAge = time.Minute
+
+	tests := []struct {
+		name       string
+		fetches    map[string]poolFetch
+		wantStatus string
+		wantError  string
+	}{
+		{
+			name: "all pools fresh",
+			fetches: map[string]poolFetch{
+				"mini": {lastSuccess: now.Add(-10 * time.Second)},
+				"main": {lastSuccess: now.Add(-20 * time.Second)},
+			},
+			wantStatus: "ok",
+		},
+		{
+			name: "one pool down",
+			fetches: map[string]poolFetch{
+				"mini": {lastSuccess: now.Add(-10 * time.Second)},
+				"main": {lastSuccess: now.Add(-time.Hour), lastError: errors.New("timeout")},
+			},
+			wantStatus: "unhealthy",
+			wantError:  "main: timeout",
+		},
+		{
+			name: "one pool never reached",
+			fetches: map[string]poolFetch{
+				"mini": {lastSuccess: now.Add(-10 * time.Second)},
+				"main": {lastError: errors.New("refused")},
+			},
+			wantStatus: "unhealthy",
+			wantError:  "main: refused",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := statusSnapshot{takenAt: now, fetches: tt.fetches}
+			resp := s.healthResponse(maxFetchAge)
+			if resp.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", resp.Status, tt.wantStatus)
+			}
+			if resp.LastFetchError != tt.wantError {
+				t.Errorf("last fetch error = %q, want %q", resp.LastFetchError, tt.wantError)
+			}
+			if len(resp.Pools) != len(tt.fetches) {
+				t.Errorf("got %d pools, want %d", len(resp.Pools), len(tt.fetches))
+			}
+		})
+	}
+}
+
+func TestRecordFetchKeepsPoolsApart(t *testing.T) {
+	conf := config{Pools: []poolConfig{{Name: "mini", URL: "http://mini"}, {Name: "main", URL: "http://main"}}}
+	n := newTestNotifier(t, conf, newTestStore(t))
+
+	n.recordFetch("mini", nil)
+	n.recordFetch("main", errors.New("timeout"))
+
+	s := n.snapshot()
+	if s.fetches["mini"].lastSuccess.IsZero() || s.fetches["mini"].lastError != nil {
+		t.Errorf("mini = %+v, want a success", s.fetches["mini"])
+	}
+	if !s.fetches["main"].lastSuccess.IsZero() || s.fetches["main"].lastError == nil {
+		t.Errorf("main = %+v, want a failure only", s.fetches["main"])
+	}
+	if resp := s.healthResponse(time.Minute); resp.Status != "unhealthy" {
+		t.Errorf("status = %q with main never reached, want unhealthy", resp.Status)
+	}
+
+	var sb strings.Builder
+	if err := writeMetrics(&sb, n.metrics()); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`p2pool_notifier_last_fetch_failed{pool="main"} 1`,
+		`p2pool_notifier_last_fetch_failed{pool="mini"} 0`,
+		`p2pool_notifier_last_successful_fetch_timestamp_seconds{pool="mini"}`,
+	} {
+		if !strings.Contains(sb.String(), want) {
+			t.Errorf("metrics lack %s:\n%s", want, sb.String())
+		}
+	}
+}
+
+// TestPresentersAgree polls a pool and checks that /healthz, /pools and the
+// metrics tell the same heights and instants.
+func TestPresentersAgree(t *testing.T) {
+	pool := poolConfig{Name: "mini", URL: newPoolAPI(t, 200, blocksJSON(3000002, 3000001))}
+	store := newTestStore(t)
+	n := newTestNotifier(t, config{Pools: []poolConfig{pool}}, store)
+	if err := n.tryNotifyIfNewBlock(context.Background(), pool); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(httpMux(n, time.Minute, ""))
+	defer srv.Close()
+	res, err := http.Get(srv.URL + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var health healthResponse
+	if err := json.NewDecoder(res.Body).Decode(&health); err != nil {
+		t.Fatal(err)
+	}
+	r := newCommandRouter(nil, store, n, n.usage, config{})
+	pools := r.cmdPools(command(1, "/pools")).Text
+	metrics := n.metrics()
+
+	if got := health.LastHeights["mini"]; got != 3000002 {
+		t.Errorf("/healthz height = %d, want 3000002", got)
+	}
+	if !strings.Contains(pools, "высота 3000002") {
+		t.Errorf("/pools = %q, want height 3000002", pools)
+	}
+	if got := metricValue(t, metrics, "p2pool_notifier_last_block_height"); got != 3000002 {
+		t.Errorf("height metric = %g, want 3000002", got)
+	}
+
+	f := health.Pools["mini"]
+	if f.LastSuccessfulFetch == nil || f.LastBlockSeenAt == nil {
+		t.Fatalf("/healthz pool = %+v, want the fetch and the block seen", f)
+	}
+	for _, tt := range []struct {
+		metric string
+		want   time.Time
+	}{
+		{"p2pool_notifier_last_successful_fetch_timestamp_seconds", *f.LastSuccessfulFetch},
+		{"p2pool_notifier_last_block_seen_timestamp_seconds", *f.LastBlockSeenAt},
+	} {
+		if got := metricValue(t, metrics, tt.metric); got != unixSeconds(tt.want) {
+			t.Errorf("%s = %f, /healthz says %f", tt.metric, got, unixSeconds(tt.want))
+		}
+	}
+}
+
+// TestSnapshotConsistent takes snapshots while the first block of a pool
+// comes in over and over and checks that none has the block without the
+// time it was seen.
+func TestSnapshotConsistent(t *testing.T) {
+	pool := poolConfig{Name: "mini", URL: newPoolAPI(t, 200, blocksJSON(101))}
+	n := newTestNotifier(t, config{Pools: []poolConfig{pool}}, newTestStore(t))
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			s := n.snapshot()
+			if s.lastBlocks["mini"].height != 0 && s.fetches["mini"].lastBlockSeenAt.IsZero() {
+				t.Error("snapshot has a block that was never seen")
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < 20; i++ {
+		n.mu.Lock()
+		n.lastBlocks.setLastBlock("mini", block{})
+		n.fetches["mini"].lastBlockSeenAt = time.Time{}
+		n.mu.Unlock()
+		if err := n.tryNotifyIfNewBlock(context.Background(), pool); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"time"
+)
+
+const (
+	defaultBackend = "file"
+	// Telegram chat IDs have at most 52 significant bits, channels and
+	// supergroups included (-100…).
+	maxChatIDMagnitude = 1 << 52
+
+	defaultSubscribeAttempts = 3
+	// subscribeBackoff is the wait before the first retry of a failed
+	// write, doubled for every further one.
+	subscribeBackoff = 100 * time.Millisecond
+)
+
+var errInvalidChatID = errors.New("invalid chat ID 0")
+
+// Storer persists the chat IDs of subscribed users.
+type Storer interface {
+	// Add subscribes tgid, recording a subscribe event unless it already
+	// was subscribed.
+	Add(tgid int64) error
+	Subscribers() ([]int64, error)
+	// Remove unsubscribes tgid, recording an unsubscribe event if it was
+	// subscribed.
+	Remove(tgid int64) error
+	// Count returns the number of subscribers.
+	Count() (int, error)
+	// Replace swaps oldID for newID, e.g. when a group becomes a supergroup.
+	Replace(oldID, newID int64) error
+	// List returns up to limit subscribers starting at offset, along with
+	// the total number of subscribers.
+	List(offset, limit int) ([]int64, int, error)
+	// Find returns the subscribers match reports true for, in the order
+	// of Subscribers.
+	Find(match func(tgid int64) bool) ([]int64, error)
+
+	// RecordAck notes that a subscriber has read the notification about
+	// the block at height.
+	RecordAck(subID int64, height int) error
+	// GetAckStats returns how many subscribers acknowledged the block at
+	// height, out of how many.
+	GetAckStats(height int) (int, int, error)
+
+	// RecordSentMessage notes the Telegram message that told chatID about
+	// the block at height.
+	RecordSentMessage(msgID, chatID int64, height int) error
+	// GetSentMessages returns the messages sent about the block at height.
+	GetSentMessages(height int) ([]sentMessage, error)
+
+	// GetEvents returns the subscribe and unsubscribe events that occurred
+	// at or after since, oldest first. Every Add and Remove that changed
+	// the subscribers is one, whether from a command, an admin, a prune or
+	// an import.
+	GetEvents(since time.Time) ([]subscriptionEvent, error)
+}
+
+// sentMessage is a block notification as delivered to one chat.
+type sentMessage struct {
+	MessageID int64
+	ChatID    int64
+	Height    int
+	SentAt    time.Time
+}
+
+const (
+	eventSubscribe   = "subscribe"
+	eventUnsubscribe = "unsubscribe"
+)
+
+// subscriptionEvent is a chat subscribing or unsubscribing.
+type subscriptionEvent struct {
+	SubscriberID int64
+	Type         string
+	OccurredAt   time.Time
+}
+
+type backend struct {
+	description string
+	open        func(conf config) (Storer, error)
+}
+
+// backendRegistry holds every storage backend compiled into the binary.
+// Backends add themselves from an init function via registerBackend.
+var backendRegistry = map[string]backend{}
+
+func registerBackend(name string, b backend) {
+	if _, ok := backendRegistry[name]; ok {
+		panic("storage backend registered twice: " + name)
+	}
+	backendRegistry[name] = b
+}
+
+func backendName(conf config) string {
+	if conf.Storage == "" {
+		return defaultBackend
+	}
+	return conf.Storage
+}
+
+func openStore(conf config) (Storer, error) {
+	name := backendName(conf)
+	b, ok := backendRegistry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown storage backend %q", name)
+	}
+	return b.open(conf)
+}
+
+// validateChatID rejects IDs that can never be delivered to and warns about
+// ones outside the range Telegram hands out.
+func validateChatID(id int64) error {
+	if id == 0 {
+		return errInvalidChatID
+	}
+	if id >= maxChatIDMagnitude || id <= -maxChatIDMagnitude {
+		log.Printf("warning: chat ID %s looks implausible", chatRef(id))
+	}
+	return nil
+}
+
+// retry calls fn up to attempts times, backing off between failures. Invalid
+// chat IDs are not retried as they would fail the same way every time.
+func retry(attempts int, backoff time.Duration, fn func() error) error {
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		err = fn()
+		if err == nil || errors.Is(err, errInvalidChatID) || errors.Is(err, errStoreReadOnly) {
+			return err
+		}
+	}
+	return err
+}
+
+func printBackends(out io.Writer) {
+	names := make([]string, 0, len(backendRegistry))
+	for name := range backendRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(out, "%s: %s\n", name, backendRegistry[name].description)
+	}
+}
@@ -0,0 +1,618 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+func init() {
+	registerBackend("file", backend{
+		description: "flat-file line-separated IDs (default)",
+		open: func(conf config) (Storer, error) {
+			if err := checkSubscribersPath(conf.SubscribersFile); err != nil {
+				return nil, err
+			}
+			s := newFileStore(conf.SubscribersFile)
+			if err := s.quarantineCorrupt(); err != nil {
+				return nil, fmt.Errorf("check %s: %w", s.path, err)
+			}
+			removed, err := s.dedup()
+			if err != nil {
+				return nil, fmt.Errorf("dedup %s: %w", s.path, err)
+			}
+			if removed > 0 {
+				log.Printf("removed %d duplicate subscribers from %s", removed, s.path)
+			}
+			return s, nil
+		},
+	})
+}
+
+var errStoreClosed = errors.New("store is closed")
+
+// fileStore keeps one subscriber chat ID per line in a plain text file.
+// A single goroutine owns the file: every method hands it an operation
+// and waits for the result, so the operations never overlap.
+type fileStore struct {
+	path string
+	// quarantine is set when the file had unparsable lines on startup and
+	// was moved aside.
+	quarantine *quarantineReport
+
+	ops  chan fileOp
+	done chan struct{}
+
+	// closing guards sending on ops against Close.
+	closing sync.RWMutex
+	closed  bool
+
+	// degraded is set while the file can't be written, see doWrite.
+	degraded     atomic.Bool
+	readOnlyMu   sync.Mutex
+	readOnlyHook func(readOnly bool, err error)
+}
+
+type fileOp struct {
+	fn     func() error
+	result chan error
+}
+
+// checkSubscribersPath fails unless path is a regular file or can be
+// created as one, so a misconfigured path is reported once on startup
+// instead of by every operation.
+func checkSubscribersPath(path string) error {
+	info, err := os.Stat(path)
+	switch {
+	case err == nil && info.IsDir():
+		return fmt.Errorf("SubscribersFile %s is a directory, point it at a file such as %s", path, filepath.Join(path, "subscribers.txt"))
+	case err == nil && !info.Mode().IsRegular():
+		return fmt.Errorf("SubscribersFile %s is not a regular file", path)
+	case err == nil:
+		return nil
+	case !errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("SubscribersFile: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+	info, err = os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("SubscribersFile: can't create %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("SubscribersFile: can't create %s, %s is not a directory", path, dir)
+	}
+	return nil
+}
+
+func newFileStore(path string) *fileStore {
+	s := &fileStore{
+		path: path,
+		ops:  make(chan fileOp),
+		done: make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *fileStore) run() {
+	defer close(s.done)
+	for op := range s.ops {
+		op.result <- op.fn()
+	}
+}
+
+// do runs fn on the store goroutine and returns its error.
+func (s *fileStore) do(fn func() error) error {
+	s.closing.RLock()
+	if s.closed {
+		s.closing.RUnlock()
+		return errStoreClosed
+	}
+	result := make(chan error, 1)
+	s.ops <- fileOp{fn: fn, result: result}
+	s.closing.RUnlock()
+
+	return <-result
+}
+
+// Close waits for the operations already handed over to finish and stops
+// the store goroutine. Later calls fail with errStoreClosed.
+func (s *fileStore) Close() error {
+	s.closing.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.ops)
+	}
+	s.closing.Unlock()
+
+	<-s.done
+	return nil
+}
+
+func (s *fileStore) Add(tgid int64) error {
+	if err := validateChatID(tgid); err != nil {
+		return err
+	}
+
+	return s.doWrite(func() error {
+		ids, err := s.read()
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if id == tgid {
+				return nil
+			}
+		}
+
+		file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = file.WriteString(strconv.FormatInt(tgid, 10) + "\n")
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+
+		s.recordEvent(tgid, eventSubscribe)
+		return nil
+	})
+}
+
+// quarantineReport describes a subscribers file that was moved aside.
+type quarantineReport struct {
+	path             string
+	valid, corrupted int
+}
+
+// corruptLineRatio is the share of unparsable lines from which the file is
+// taken to be corrupt, e.g. overwritten by garbage after a disk problem,
+// rather than carrying the odd stray line.
+const corruptLineRatio = 0.2
+
+// parseSubscriberLine parses one line of the file. Surrounding whitespace,
+// \r of CRLF line endings included, is ignored, and blank lines are
+// skipped with ok false and no error.
+func parseSubscriberLine(line string) (id int64, ok bool, err error) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return 0, false, nil
+	}
+	id, err = strconv.ParseInt(line, 10, 64)
+	if err != nil {
+		return 0, false, err
+	}
+	return id, true, nil
+}
+
+// quarantineCorrupt checks the file for unparsable lines on startup. A
+// corrupt file, see corruptLineRatio, is moved to path.corrupt-<unix> and
+// admins are told; the bot carries on with the IDs that did parse. With
+// only a few bad lines, a copy is kept as path.invalid-<unix> and the
+// lines are dropped. Nothing is ever deleted: the original stays for
+// manual recovery or the import subcommand.
+func (s *fileStore) quarantineCorrupt() error {
+	return s.do(s.quarantineCorruptOp)
+}
+
+func (s *fileStore) quarantineCorruptOp() error {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var valid []int64
+	corrupted := 0
+	for _, line := range strings.Split(string(data), "\n") {
+		id, ok, err := parseSubscriberLine(line)
+		if err != nil {
+			corrupted++
+		}
+		if ok {
+			valid = append(valid, id)
+		}
+	}
+	if corrupted == 0 {
+		return nil
+	}
+
+	if float64(corrupted) < corruptLineRatio*float64(corrupted+len(valid)) {
+		backup := fmt.Sprintf("%s.invalid-%d", s.path, time.Now().Unix())
+		if err := os.WriteFile(backup, data, 0600); err != nil {
+			return err
+		}
+		if err := s.write(valid); err != nil {
+			return err
+		}
+		log.Printf("warning: subscribers file %s had %d unparsable lines, dropped them and kept a copy in %s",
+			s.path, corrupted, backup)
+		return nil
+	}
+
+	aside := fmt.Sprintf("%s.corrupt-%d", s.path, time.Now().Unix())
+	if err := os.Rename(s.path, aside); err != nil {
+		return err
+	}
+	if err := s.write(valid); err != nil {
+		return err
+	}
+
+	s.quarantine = &quarantineReport{path: aside, valid: len(valid), corrupted: corrupted}
+	log.Printf("warning: subscribers file %s has %d unparsable lines, moved to %s, kept %d subscribers",
+		s.path, corrupted, aside, len(valid))
+	return nil
+}
+
+// dedup rewrites the file without the duplicates older versions appended
+// on every /start, keeping the first occurrence of each ID. The file is
+// left alone when there are none.
+func (s *fileStore) dedup() (removed int, err error) {
+	err = s.do(func() error {
+		ids, err := s.read()
+		if err != nil {
+			return err
+		}
+
+		seen := make(map[int64]bool, len(ids))
+		unique := make([]int64, 0, len(ids))
+		for _, id := range ids {
+			if !seen[id] {
+				seen[id] = true
+				unique = append(unique, id)
+			}
+		}
+
+		removed = len(ids) - len(unique)
+		if removed == 0 {
+			return nil
+		}
+		return s.write(unique)
+	})
+	return removed, err
+}
+
+func (s *fileStore) Subscribers() (ids []int64, err error) {
+	err = s.do(func() error {
+		ids, err = s.read()
+		return err
+	})
+	return ids, err
+}
+
+func (s *fileStore) Count() (int, error) {
+	ids, err := s.Subscribers()
+	return len(ids), err
+}
+
+func (s *fileStore) Remove(tgid int64) error {
+	return s.doWrite(func() error {
+		ids, err := s.read()
+		if err != nil {
+			return err
+		}
+
+		kept := make([]int64, 0, len(ids))
+		for _, id := range ids {
+			if id != tgid {
+				kept = append(kept, id)
+			}
+		}
+		if len(kept) == len(ids) {
+			return nil
+		}
+
+		if err := s.write(kept); err != nil {
+			return err
+		}
+		s.recordEvent(tgid, eventUnsubscribe)
+		return nil
+	})
+}
+
+func (s *fileStore) Replace(oldID, newID int64) error {
+	if err := validateChatID(newID); err != nil {
+		return err
+	}
+
+	return s.doWrite(func() error {
+		ids, err := s.read()
+		if err != nil {
+			return err
+		}
+
+		replaced := make([]int64, 0, len(ids))
+		for _, id := range ids {
+			if id == newID {
+				continue
+			}
+			if id == oldID {
+				id = newID
+			}
+			replaced = append(replaced, id)
+		}
+
+		return s.write(replaced)
+	})
+}
+
+func (s *fileStore) List(offset, limit int) ([]int64, int, error) {
+	ids, err := s.Subscribers()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if offset >= len(ids) {
+		return nil, len(ids), nil
+	}
+	end := offset + limit
+	if end > len(ids) {
+		end = len(ids)
+	}
+
+	return ids[offset:end], len(ids), nil
+}
+
+func (s *fileStore) Find(match func(tgid int64) bool) (found []int64, err error) {
+	err = s.do(func() error {
+		ids, err := s.read()
+		for _, id := range ids {
+			if match(id) {
+				found = append(found, id)
+			}
+		}
+		return err
+	})
+	return found, err
+}
+
+// RecordAck appends "height subscriber unix-time" to the .acks file next
+// to the subscribers file.
+func (s *fileStore) RecordAck(subID int64, height int) error {
+	return s.doWrite(func() error {
+		file, err := os.OpenFile(s.path+".acks", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		_, err = fmt.Fprintf(file, "%d %d %d\n", height, subID, time.Now().Unix())
+		return err
+	})
+}
+
+// GetAckStats counts every subscriber once. The total is the number of
+// chats the block was sent to, or the current subscriber count for blocks
+// sent before messages were recorded.
+func (s *fileStore) GetAckStats(height int) (acked, total int, err error) {
+	err = s.do(func() error {
+		acked, total, err = s.ackStats(height)
+		return err
+	})
+	return acked, total, err
+}
+
+func (s *fileStore) ackStats(height int) (int, int, error) {
+	ids, err := s.read()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	sent, err := s.sentMessages(height)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(sent) > 0 {
+		chats := make(map[int64]bool, len(sent))
+		for _, m := range sent {
+			chats[m.ChatID] = true
+		}
+		ids = ids[:0]
+		for id := range chats {
+			ids = append(ids, id)
+		}
+	}
+
+	file, err := os.Open(s.path + ".acks")
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, len(ids), nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	acked := make(map[int64]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var h int
+		var subID, ackAt int64
+		if _, err := fmt.Sscan(scanner.Text(), &h, &subID, &ackAt); err != nil {
+			return 0, 0, err
+		}
+		if h == height {
+			acked[subID] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	return len(acked), len(ids), nil
+}
+
+// RecordSentMessage appends "height chat message unix-time" to the .sent
+// file next to the subscribers file.
+func (s *fileStore) RecordSentMessage(msgID, chatID int64, height int) error {
+	return s.doWrite(func() error {
+		file, err := os.OpenFile(s.path+".sent", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		_, err = fmt.Fprintf(file, "%d %d %d %d\n", height, chatID, msgID, time.Now().Unix())
+		return err
+	})
+}
+
+func (s *fileStore) GetSentMessages(height int) (sent []sentMessage, err error) {
+	err = s.do(func() error {
+		sent, err = s.sentMessages(height)
+		return err
+	})
+	return sent, err
+}
+
+// sentMessages must be called on the store goroutine.
+func (s *fileStore) sentMessages(height int) ([]sentMessage, error) {
+	file, err := os.Open(s.path + ".sent")
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var sent []sentMessage
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var m sentMessage
+		var sentAt int64
+		if _, err := fmt.Sscan(scanner.Text(), &m.Height, &m.ChatID, &m.MessageID, &sentAt); err != nil {
+			return nil, err
+		}
+		if m.Height == height {
+			m.SentAt = time.Unix(sentAt, 0)
+			sent = append(sent, m)
+		}
+	}
+	return sent, scanner.Err()
+}
+
+// recordEvent appends "unix-time chat type" to the .events file next to the
+// subscribers file. The subscription itself already changed, so a failure
+// is only logged. Must be called on the store goroutine.
+func (s *fileStore) recordEvent(subID int64, eventType string) {
+	file, err := os.OpenFile(s.path+".events", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err == nil {
+		_, err = fmt.Fprintf(file, "%d %d %s\n", time.Now().Unix(), subID, eventType)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		log.Printf("error: record %s of %s: %s", eventType, chatRef(subID), err.Error())
+	}
+}
+
+func (s *fileStore) GetEvents(since time.Time) (events []subscriptionEvent, err error) {
+	err = s.do(func() error {
+		file, err := os.Open(s.path + ".events")
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var e subscriptionEvent
+			var occurredAt int64
+			if _, err := fmt.Sscan(scanner.Text(), &occurredAt, &e.SubscriberID, &e.Type); err != nil {
+				return err
+			}
+			e.OccurredAt = time.Unix(occurredAt, 0)
+			if !e.OccurredAt.Before(since) {
+				events = append(events, e)
+			}
+		}
+		return scanner.Err()
+	})
+	return events, err
+}
+
+// read must be called on the store goroutine.
+func (s *fileStore) read() ([]int64, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		var pErr *fs.PathError
+		if errors.As(err, &pErr) {
+			log.Printf("no subscribers yet, skip")
+			return nil, nil
+		}
+	}
+	defer file.Close()
+
+	var ids []int64
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		id, ok, err := parseSubscriberLine(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			ids = append(ids, id)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// write atomically replaces the file with ids, keeping its permissions, or
+// giving a new file those Add creates it with. Must be called on the store
+// goroutine.
+func (s *fileStore) write(ids []int64) error {
+	mode := fs.FileMode(0644)
+	if info, err := os.Stat(s.path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	// CreateTemp makes the file 0600.
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	w := bufio.NewWriter(tmp)
+	for _, id := range ids {
+		w.WriteString(strconv.FormatInt(id, 10) + "\n")
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileStoreWriteKeepsMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing fs.FileMode // 0 for no file
+		want     fs.FileMode
+	}{
+		{"new file", 0, 0644},
+		{"world readable", 0644, 0644},
+		{"group writable", 0664, 0664},
+		{"private", 0600, 0600},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "subscribers.txt")
+			if tt.existing != 0 {
+				if err := os.WriteFile(path, []byte("1\n1\n2\n"), tt.existing); err != nil {
+					t.Fatal(err)
+				}
+				// WriteFile is subject to the umask.
+				if err := os.Chmod(path, tt.existing); err != nil {
+					t.Fatal(err)
+				}
+			}
+			s := newFileStore(path)
+			defer s.Close()
+
+			if err := s.do(func() error { return s.write([]int64{1, 2}) }); err != nil {
+				t.Fatal(err)
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := info.Mode().Perm(); got != tt.want {
+				t.Errorf("mode = %o, want %o", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileStoreDedup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "subscribers.txt")
+	if err := os.WriteFile(path, []byte("1\n2\n1\n3\n2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := newFileStore(path)
+	defer s.Close()
+
+	removed, err := s.dedup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 {
+		t.Errorf("removed = %d, want 2", removed)
+	}
+	ids, _ := s.Subscribers()
+	if len(ids) != 3 || ids[0] != 1 || ids[1] != 2 || ids[2] != 3 {
+		t.Errorf("subscribers = %v, want [1 2 3]", ids)
+	}
+}
+
+func TestQuarantineCorrupt(t *testing.T) {
+	tests := []struct {
+		name           string
+		content        string
+		wantIDs        []int64
+		wantQuarantine bool
+		wantBackup     bool
+	}{
+		{name: "clean", content: "1\n2\n3\n", wantIDs: []int64{1, 2, 3}},
+		{name: "CRLF line endings", content: "1\r\n2\r\n3\r\n", wantIDs: []int64{1, 2, 3}},
+		{name: "blank lines", content: "1\n\n2\n  \n3\n", wantIDs: []int64{1, 2, 3}},
+		{
+			name:       "a stray line",
+			content:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\noops\n",
+			wantIDs:    []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+			wantBackup: true,
+		},
+		{
+			name:           "partial corruption",
+			content:        "1\n2\n\x00\x7f\x01\n\xff\xfe\n3\n",
+			wantIDs:        []int64{1, 2, 3},
+			wantQuarantine: true,
+		},
+		{
+			name:           "total corruption",
+			content:        "\x00\x01\x02\n\xff\xfe\xfd\n",
+			wantIDs:        nil,
+			wantQuarantine: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "subscribers.txt")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			s := newFileStore(path)
+			defer s.Close()
+
+			if err := s.quarantineCorrupt(); err != nil {
+				t.Fatal(err)
+			}
+
+			ids, err := s.Subscribers()
+			if err != nil {
+				t.Fatalf("Subscribers after the check: %s", err)
+			}
+			if !slices.Equal(ids, tt.wantIDs) {
+				t.Errorf("subscribers = %v, want %v", ids, tt.wantIDs)
+			}
+			if got := s.quarantine != nil; got != tt.wantQuarantine {
+				t.Errorf("quarantined = %v, want %v", got, tt.wantQuarantine)
+			}
+
+			corrupt, _ := filepath.Glob(path + ".corrupt-*")
+			invalid, _ := filepath.Glob(path + ".invalid-*")
+			if got := len(corrupt) == 1; got != tt.wantQuarantine {
+				t.Errorf("corrupt files = %v", corrupt)
+			}
+			if got := len(invalid) == 1; got != tt.wantBackup {
+				t.Errorf("invalid line backups = %v", invalid)
+			}
+			for _, kept := range append(corrupt, invalid...) {
+				if data, _ := os.ReadFile(kept); string(data) != tt.content {
+					t.Errorf("%s = %q, want the original content", kept, data)
+				}
+			}
+		})
+	}
+}
+
+func TestFileStoreRecordsEvents(t *testing.T) {
+	type event struct {
+		id  int64
+		typ string
+	}
+	tests := []struct {
+		name string
+		ops  func(s *fileStore) error
+		want []event
+	}{
+		{"add new", func(s *fileStore) error { return s.Add(1) }, []event{{1, eventSubscribe}}},
+		{"add existing", func(s *fileStore) error {
+			if err := s.Add(1); err != nil {
+				return err
+			}
+			return s.Add(1)
+		}, []event{{1, eventSubscribe}}},
+		{"remove present", func(s *fileStore) error {
+			if err := s.Add(1); err != nil {
+				return err
+			}
+			return s.Remove(1)
+		}, []event{{1, eventSubscribe}, {1, eventUnsubscribe}}},
+		{"remove absent", func(s *fileStore) error { return s.Remove(1) }, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			if err := tt.ops(s); err != nil {
+				t.Fatal(err)
+			}
+
+			events, err := s.GetEvents(time.Time{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []event
+			for _, e := range events {
+				got = append(got, event{e.SubscriberID, e.Type})
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("events = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileStoreConcurrentWrites(t *testing.T) {
+	const workers, perWorker = 8, 50
+	s := newTestStore(t)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 1; i <= perWorker; i++ {
+				id := int64(w*perWorker + i)
+				if err := s.Add(id); err != nil {
+					t.Error(err)
+					return
+				}
+				if id%2 == 0 {
+					if err := s.Remove(id); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+				if _, err := s.Subscribers(); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	ids, err := s.Subscribers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(ids)
+	var want []int64
+	for id := int64(1); id <= workers*perWorker; id += 2 {
+		want = append(want, id)
+	}
+	if !slices.Equal(ids, want) {
+		t.Errorf("got %d subscribers, want the %d odd IDs", len(ids), len(want))
+	}
+}
+
+func TestFileStoreClose(t *testing.T) {
+	s := newFileStore(filepath.Join(t.TempDir(), "subscribers.txt"))
+
+	var wg sync.WaitGroup
+	for id := int64(1); id <= 20; id++ {
+		wg.Add(1)
+		go func(id int64) {
+			defer wg.Done()
+			if err := s.Add(id); err != nil && !errors.Is(err, errStoreClosed) {
+				t.Error(err)
+			}
+		}(id)
+	}
+	s.Close()
+	wg.Wait()
+
+	if err := s.Add(100); !errors.Is(err, errStoreClosed) {
+		t.Errorf("Add after Close = %v, want errStoreClosed", err)
+	}
+	// Whatever was handed over before Close made it to the file whole.
+	reopened := newFileStore(s.path)
+	defer reopened.Close()
+	ids, err := reopened.Subscribers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) > 20 {
+		t.Errorf("%d subscribers, want at most 20", len(ids))
+	}
+	for _, id := range ids {
+		if id < 1 || id > 20 {
+			t.Errorf("unexpected subscriber %d", id)
+		}
+	}
+}
+
+func TestFileStoreSentMessages(t *testing.T) {
+	s := newTestStore(t)
+	records := []struct {
+		msgID, chatID int64
+		height        int
+	}{
+		{10, 1, 100},
+		{11, 2, 100},
+		{20, 1, 101},
+		{12, -1001, 100},
+	}
+	before := time.Now().Add(-time.Second)
+	for _, r := range records {
+		if err := s.RecordSentMessage(r.msgID, r.chatID, r.height); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		height   int
+		wantMsgs []int64
+	}{
+		{100, []int64{10, 11, 12}},
+		{101, []int64{20}},
+		{102, nil},
+	}
+
+	for _, tt := range tests {
+		sent, err := s.GetSentMessages(tt.height)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var msgs []int64
+		for _, m := range sent {
+			msgs = append(msgs, m.MessageID)
+			if m.Height != tt.height || m.SentAt.Before(before) {
+				t.Errorf("height %d: record %+v", tt.height, m)
+			}
+		}
+		if !slices.Equal(msgs, tt.wantMsgs) {
+			t.Errorf("height %d: messages %v, want %v", tt.height, msgs, tt.wantMsgs)
+		}
+	}
+}
+
+func TestFileStoreNoSentMessages(t *testing.T) {
+	sent, err := newTestStore(t).GetSentMessages(100)
+	if err != nil || len(sent) != 0 {
+		t.Errorf("GetSentMessages = %v, %v, want none", sent, err)
+	}
+}
+
+func TestCheckSubscribersPath(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "subscribers.txt")
+	if err := os.WriteFile(existing, []byte("1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	notDir := filepath.Join(dir, "file")
+	if err := os.WriteFile(notDir, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{name: "existing file", path: existing},
+		{name: "new file", path: filepath.Join(dir, "new.txt")},
+		{name: "directory", path: dir, wantErr: "is a directory, point it at a file such as " + filepath.Join(dir, "subscribers.txt")},
+		{name: "missing directory", path: filepath.Join(dir, "missing", "subscribers.txt"), wantErr: "can't create"},
+		{name: "parent is a file", path: filepath.Join(notDir, "subscribers.txt"), wantErr: "not a directory"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkSubscribersPath(tt.path)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("error = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOpenFileStoreInDirectory(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := openStore(config{Storage: "file", SubscribersFile: dir}); err == nil {
+		t.Fatal("opened a directory as the subscribers file")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("opening left %d files in the directory", len(entries))
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileBackendRegistered(t *testing.T) {
+	if _, ok := backendRegistry["file"]; !ok {
+		t.Fatal("file backend isn't registered")
+	}
+
+	var out strings.Builder
+	printBackends(&out)
+	if !strings.Contains("\n"+out.String(), "\nfile: ") {
+		t.Errorf("printBackends output lacks the file backend:\n%s", out.String())
+	}
+}
+
+func TestOpenStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "subscribers.txt")
+
+	tests := []struct {
+		name    string
+		storage string
+		wantErr bool
+	}{
+		{"default", "", false},
+		{"file", "file", false},
+		{"unknown", "nosuch", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := openStore(config{Storage: tt.storage, SubscribersFile: path})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if closer, ok := store.(io.Closer); ok {
+				closer.Close()
+			}
+		})
+	}
+}
+
+// flakyStore fails the first failures calls to Add.
+type flakyStore struct {
+	Storer
+	failures int
+	calls    int
+}
+
+func (s *flakyStore) Add(id int64) error {
+	s.calls++
+	if s.calls <= s.failures {
+		return errors.New("disk busy")
+	}
+	return s.Storer.Add(id)
+}
+
+func TestStartRetriesTransientErrors(t *testing.T) {
+	retries := 2
+
+	tests := []struct {
+		name           string
+		failures       int
+		wantSubscribed bool
+		wantCalls      int
+	}{
+		{"no failure", 0, true, 1},
+		{"fails once", 1, true, 2},
+		{"fails on every attempt", 5, false, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := newTestStore(t)
+			store := &flakyStore{Storer: files, failures: tt.failures}
+			conf := config{SubscribeRetries: &retries}
+			n := newTestNotifier(t, conf, store)
+			r := newCommandRouter(nil, store, n, n.usage, conf)
+
+			resp := r.cmdStart(command(1, "/start"))
+
+			ids, _ := files.Subscribers()
+			if subscribed := slices.Contains(ids, 1); subscribed != tt.wantSubscribed {
+				t.Errorf("subscribed = %v, want %v; reply %q", subscribed, tt.wantSubscribed, resp.Text)
+			}
+			if store.calls != tt.wantCalls {
+				t.Errorf("Add called %d times, want %d", store.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryStopsOnPermanentErrors(t *testing.T) {
+	for _, permanent := range []error{errInvalidChatID, errStoreReadOnly} {
+		calls := 0
+		err := retry(3, time.Millisecond, func() error {
+			calls++
+			return permanent
+		})
+		if !errors.Is(err, permanent) || calls != 1 {
+			t.Errorf("%v: retry = %v after %d calls, want it after 1", permanent, err, calls)
+		}
+	}
+}
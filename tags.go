@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+var tagPattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// tagged returns the chats of ids that carry tag.
+func (n *Notifier) tagged(ids []int64, tag string) []int64 {
+	var out []int64
+	n.state.view(func(st state) {
+		for _, id := range ids {
+			if slices.Contains(st.Tags[id], tag) {
+				out = append(out, id)
+			}
+		}
+	})
+	return out
+}
+
+// parseTagArgs parses "<chat ID> <tag>".
+func parseTagArgs(args string) (int64, string, bool) {
+	fields := strings.Fields(args)
+	if len(fields) != 2 {
+		return 0, "", false
+	}
+	chatID, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return 0, "", false
+	}
+	tag := strings.ToLower(fields[1])
+	if !tagPattern.MatchString(tag) {
+		return 0, "", false
+	}
+	return chatID, tag, true
+}
+
+// cmdTag implements /tag <chat_id> <tag>.
+func (r *commandRouter) cmdTag(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	chatID, tag, ok := parseTagArgs(msg.CommandArguments())
+	if !ok {
+		return reply(msg, "Использование: /tag <ID чата> <метка>, метка из латинских букв, цифр, _ и -")
+	}
+
+	err := r.notifier.state.update(func(st *state) {
+		if slices.Contains(st.Tags[chatID], tag) {
+			return
+		}
+		if st.Tags == nil {
+			st.Tags = make(map[int64][]string)
+		}
+		st.Tags[chatID] = append(st.Tags[chatID], tag)
+	})
+	if err != nil {
+		log.Printf("error: tag %s: %s", chatRef(chatID), err.Error())
+		return reply(msg, "Не удалось сохранить метку")
+	}
+
+	r.notifier.audit.record(actorOf(msg), "tag", map[string]string{"chat": strconv.FormatInt(chatID, 10), "tag": tag})
+	return reply(msg, fmt.Sprintf("Чат %d помечен меткой %s", chatID, tag))
+}
+
+// cmdUntag implements /untag <chat_id> <tag>.
+func (r *commandRouter) cmdUntag(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	chatID, tag, ok := parseTagArgs(msg.CommandArguments())
+	if !ok {
+		return reply(msg, "Использование: /untag <ID чата> <метка>")
+	}
+
+	err := r.notifier.state.update(func(st *state) {
+		tags := slices.DeleteFunc(st.Tags[chatID], func(t string) bool { return t == tag })
+		if len(tags) == 0 {
+			delete(st.Tags, chatID)
+			return
+		}
+		st.Tags[chatID] = tags
+	})
+	if err != nil {
+		log.Printf("error: untag %s: %s", chatRef(chatID), err.Error())
+		return reply(msg, "Не удалось удалить метку")
+	}
+
+	r.notifier.audit.record(actorOf(msg), "untag", map[string]string{"chat": strconv.FormatInt(chatID, 10), "tag": tag})
+	return reply(msg, fmt.Sprintf("С чата %d снята метка %s", chatID, tag))
+}
+
+// cmdBroadcast implements /broadcast [--tag <tag>] <text>: text goes to
+// every subscriber, or only to those tagged with tag.
+func (r *commandRouter) cmdBroadcast(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	const usage = "Использование: /broadcast [--tag <метка>] <текст>"
+
+	text := strings.TrimSpace(msg.CommandArguments())
+	var tag string
+	if fields := strings.Fields(text); len(fields) > 0 && fields[0] == "--tag" {
+		if len(fields) < 2 {
+			return reply(msg, usage)
+		}
+		tag = strings.ToLower(fields[1])
+		rest := strings.TrimSpace(strings.TrimPrefix(text, "--tag"))
+		text = strings.TrimSpace(strings.TrimPrefix(rest, fields[1]))
+	}
+	if text == "" || (tag != "" && !tagPattern.MatchString(tag)) {
+		return reply(msg, usage)
+	}
+
+	sent, failed, err := r.notifier.push(context.Background(), tag, text)
+	if err != nil {
+		log.Printf("error: broadcast: %s", err.Error())
+		return reply(msg, "Не удалось получить список подписчиков")
+	}
+
+	log.Printf("broadcast by %s to tag %q: %d sent, %d failed", chatRef(msg.From.ID), tag, sent, failed)
+	r.notifier.audit.record(actorOf(msg), "broadcast", map[string]string{
+		"tag": tag, "text_sha256": textDigest(text), "sent": strconv.Itoa(sent), "failed": strconv.Itoa(failed),
+	})
+	return reply(msg, fmt.Sprintf("Отправлено: %d, ошибок: %d", sent, failed))
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBroadcastToTag(t *testing.T) {
+	const admin = 100
+	long := strings.Repeat("ы", 2*maxCommandInput)
+
+	tests := []struct {
+		name     string
+		text     string
+		wantSent []int64
+		wantText string
+	}{
+		{name: "everyone", text: "/broadcast hello", wantSent: []int64{1, 2, 3}, wantText: "hello"},
+		{name: "tagged", text: "/broadcast --tag vip hello", wantSent: []int64{2}, wantText: "hello"},
+		{name: "tag in upper case", text: "/broadcast --tag VIP hello", wantSent: []int64{2}, wantText: "hello"},
+		{name: "unknown tag", text: "/broadcast --tag nobody hello"},
+		{name: "not the tag flag", text: "/broadcast --tagged vip", wantSent: []int64{1, 2, 3}, wantText: "--tagged vip"},
+		{name: "no tag", text: "/broadcast --tag"},
+		{name: "no text", text: "/broadcast --tag vip"},
+		{name: "longer than commands", text: "/broadcast --tag vip " + long, wantSent: []int64{2}, wantText: long},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tg := newFakeTelegram(t)
+			store := newTestStore(t)
+			for _, id := range []int64{1, 2, 3} {
+				store.Add(id)
+			}
+			conf := config{AdminIDs: []int64{admin}}
+			n := newTestNotifier(t, conf, store)
+			n.bot = tg.bot(t)
+			r := newCommandRouter(n.bot, store, n, n.usage, conf)
+			r.handle(command(admin, "/tag 2 vip"))
+
+			r.handle(command(admin, tt.text))
+
+			// /broadcast replies to the admin once it is done, after the
+			// reply to /tag.
+			texts := tg.waitForTexts(t, len(tt.wantSent)+2)
+			var sent []int64
+			for i, id := range tg.sentTo() {
+				if id == admin {
+					continue
+				}
+				sent = append(sent, id)
+				if texts[i] != tt.wantText {
+					t.Errorf("chat %d got %.40q, want %.40q", id, texts[i], tt.wantText)
+				}
+			}
+			if !slices.Equal(sent, tt.wantSent) {
+				t.Errorf("sent to %v, want %v", sent, tt.wantSent)
+			}
+		})
+	}
+}
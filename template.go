@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxMessageLength is the most Telegram accepts in one message.
+const maxMessageLength = 4096
+
+var errMessageTooLong = errors.New("message too long")
+
+// templateData is what notification templates can refer to, e.g.
+// {{.Pool}} or {{.Height}}.
+type templateData struct {
+	Pool   string
+	Height int
+	Time   string
+	Hash   string
+	Effort string
+	Reward string
+}
+
+func newTemplateData(pool poolConfig, b block, timeFormat string) templateData {
+	d := templateData{Pool: pool.Name, Height: b.height, Time: b.ts.Format(timeFormat), Hash: b.hash}
+	if b.effort != 0 {
+		d.Effort = formatEffort(b.effort)
+	}
+	if b.reward != 0 {
+		d.Reward = formatXMR(b.reward)
+	}
+	return d
+}
+
+// parseMessageTemplate parses a notification template and tries it on a
+// sample block. Templates may only be text and {{.Field}} actions: no
+// range, if or other control structures, so one from a user renders in
+// linear time, and rendering stops at maxMessageLength.
+func parseMessageTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("message").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTemplateNodes(tmpl); err != nil {
+		return nil, err
+	}
+
+	sample := block{height: 3000000, ts: time.Unix(1700000000, 0), hash: strings.Repeat("0", 64), reward: 600000000000, effort: 1}
+	out, err := renderTemplate(tmpl, newTemplateData(poolConfig{Name: defaultPoolName}, sample, time.RFC850))
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(out) == "" {
+		return nil, errors.New("template renders an empty message")
+	}
+
+	return tmpl, nil
+}
+
+// checkTemplateNodes rejects anything in tmpl but text and actions that
+// print a single field.
+func checkTemplateNodes(tmpl *template.Template) error {
+	if len(tmpl.Templates()) > 1 {
+		return errors.New("define and block aren't supported")
+	}
+	if tmpl.Tree == nil {
+		return nil
+	}
+
+	for _, node := range tmpl.Tree.Root.Nodes {
+		switch node := node.(type) {
+		case *parse.TextNode:
+		case *parse.ActionNode:
+			if !isFieldAction(node) {
+				return fmt.Errorf("%s: only fields such as {{.Height}} are supported", node)
+			}
+		default:
+			return fmt.Errorf("%s: only fields such as {{.Height}} are supported", node)
+		}
+	}
+	return nil
+}
+
+// isFieldAction reports whether node is a plain {{.Field}}.
+func isFieldAction(node *parse.ActionNode) bool {
+	pipe := node.Pipe
+	if len(pipe.Decl) > 0 || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return false
+	}
+	field, ok := pipe.Cmds[0].Args[0].(*parse.FieldNode)
+	return ok && len(field.Ident) == 1
+}
+
+func renderTemplate(tmpl *template.Template, data templateData) (string, error) {
+	var w limitedBuilder
+	if err := tmpl.Execute(&w, data); err != nil {
+		return "", err
+	}
+	return w.String(), nil
+}
+
+// limitedBuilder fails writes past maxMessageLength, which aborts the
+// template execution.
+type limitedBuilder struct {
+	strings.Builder
+}
+
+func (b *limitedBuilder) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > maxMessageLength {
+		return 0, errMessageTooLong
+	}
+	return b.Builder.Write(p)
+}
+
+// chatTemplate returns the template a chat picked with /template, or nil.
+func (n *Notifier) chatTemplate(id int64) *template.Template {
+	var text string
+	n.state.view(func(st state) {
+		text = st.Templates[id]
+	})
+	if text == "" {
+		return nil
+	}
+
+	tmpl, err := parseMessageTemplate(text)
+	if err != nil {
+		log.Printf("error: template of %s: %s", chatRef(id), err.Error())
+		return nil
+	}
+	return tmpl
+}
+
+// setChatTemplate stores the template of a chat, an empty text resets it.
+func (n *Notifier) setChatTemplate(id int64, text string) error {
+	return n.state.update(func(st *state) {
+		if text == "" {
+			delete(st.Templates, id)
+			return
+		}
+		if st.Templates == nil {
+			st.Templates = make(map[int64]string)
+		}
+		st.Templates[id] = text
+	})
+}
+
+// messageFor renders the notification about b for one chat: with its own
+// template, else with the global one, else in the built-in format.
+func (n *Notifier) messageFor(id int64, pool poolConfig, b block) string {
+	data := newTemplateData(pool, b, n.timeFormat)
+	for _, tmpl := range []*template.Template{n.chatTemplate(id), n.template} {
+		if tmpl == nil {
+			continue
+		}
+		text, err := renderTemplate(tmpl, data)
+		if err == nil {
+			return text
+		}
+		log.Printf("error: render template for %s: %s", chatRef(id), err.Error())
+	}
+	return n.blockMessage(pool, b)
+}
+
+// cmdTemplate implements /template [text|reset].
+func (r *commandRouter) cmdTemplate(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	text := strings.TrimSpace(msg.CommandArguments())
+	switch text {
+	case "":
+		return reply(msg, "Использование: /template <шаблон> или /template reset\n"+
+			"Доступные поля: {{.Pool}}, {{.Height}}, {{.Time}}, {{.Hash}}, {{.Effort}}, {{.Reward}}\n"+
+			"Например: /template Новый блок {{.Height}} на {{.Pool}}")
+	case "reset":
+		text = ""
+	default:
+		if _, err := parseMessageTemplate(text); err != nil {
+			return reply(msg, fmt.Sprintf("Шаблон не подходит: %s", sanitize(err.Error())))
+		}
+	}
+
+	if err := r.notifier.setChatTemplate(msg.Chat.ID, text); err != nil {
+		log.Printf("error: save template of %s: %s", chatRef(msg.Chat.ID), err.Error())
+		return reply(msg, "Не удалось сохранить шаблон")
+	}
+
+	if text == "" {
+		return reply(msg, "Шаблон сброшен, уведомления будут в обычном формате")
+	}
+	return reply(msg, "Шаблон сохранён")
+}
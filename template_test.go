@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMessageTemplate(t *testing.T) {
+	tests := []struct {
+		text    string
+		wantErr bool
+	}{
+		{"Новый блок {{.Height}} на {{.Pool}}", false},
+		{"{{.Time}} {{.Hash}} {{.Effort}} {{.Reward}}", false},
+		{"plain text", false},
+		{"{{range 2000000000}}{{end}}x", true},
+		{"{{if .Height}}yes{{end}}", true},
+		{"{{with .Pool}}{{.}}{{end}}", true},
+		{`{{define "x"}}a{{end}}{{template "x"}}`, true},
+		{"{{.Height | printf \"%d\"}}", true},
+		{"{{printf \"%s\" .Pool}}", true},
+		{"{{$x := .Pool}}{{$x}}", true},
+		{"{{.}}", true},
+		{"{{.Missing}}", true},
+		{"{{.Height", true},
+		{"   ", true},
+		{"{{.Pool}}" + strings.Repeat("x", maxMessageLength), true},
+	}
+	for _, tt := range tests {
+		_, err := parseMessageTemplate(tt.text)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseMessageTemplate(%q) error = %v, want error %v", tt.text, err, tt.wantErr)
+		}
+	}
+}
+
+func TestMessageForChatTemplate(t *testing.T) {
+	n := newTestNotifier(t, config{}, newTestStore(t))
+	if err := n.setChatTemplate(1, "Блок {{.Height}} на {{.Pool}}"); err != nil {
+		t.Fatal(err)
+	}
+	b := block{height: 42, ts: time.Unix(1700000000, 0)}
+
+	if got := n.messageFor(1, n.pools[0], b); got != "Блок 42 на mini" {
+		t.Errorf("messageFor with template = %q", got)
+	}
+	if got := n.messageFor(2, n.pools[0], b); got != n.blockMessage(n.pools[0], b) {
+		t.Errorf("messageFor without template = %q, want the built-in format", got)
+	}
+}
+
+func TestTemplateKeepsLongText(t *testing.T) {
+	store := newTestStore(t)
+	n := newTestNotifier(t, config{}, store)
+	tg := newFakeTelegram(t)
+	n.bot = tg.bot(t)
+	r := newCommandRouter(n.bot, store, n, n.usage, config{})
+
+	body := "Блок {{.Height}}" + strings.Repeat(".", maxCommandInput) + "конец"
+	r.handle(command(1, "/template "+body))
+
+	text := n.messageFor(1, n.pools[0], block{height: 42})
+	if !strings.HasSuffix(text, "конец") || !strings.HasPrefix(text, "Блок 42") {
+		t.Errorf("rendered %.60q…, want the whole template", text)
+	}
+}
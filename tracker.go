@@ -0,0 +1,39 @@
+package main
+
+import "sync"
+
+// blockTracker remembers the last block seen for each pool. Every pool
+// worker only touches its own entry, readers may look at all of them.
+type blockTracker struct {
+	mu     sync.RWMutex
+	blocks map[string]block
+}
+
+func newBlockTracker() *blockTracker {
+	return &blockTracker{blocks: make(map[string]block)}
+}
+
+func (t *blockTracker) getLastBlock(pool string) block {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.blocks[pool]
+}
+
+func (t *blockTracker) setLastBlock(pool string, b block) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.blocks[pool] = b
+}
+
+func (t *blockTracker) all() map[string]block {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	blocks := make(map[string]block, len(t.blocks))
+	for pool, b := range t.blocks {
+		blocks[pool] = b
+	}
+	return blocks
+}
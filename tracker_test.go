@@ -0,0 +1,43 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestBlockTrackerConcurrentPools(t *testing.T) {
+	const updates = 1000
+	pools := []string{"mini", "main"}
+	tracker := newBlockTracker()
+
+	var wg sync.WaitGroup
+	for _, pool := range pools {
+		wg.Add(1)
+		go func(pool string) {
+			defer wg.Done()
+			for height := 1; height <= updates; height++ {
+				if last := tracker.getLastBlock(pool); last.height != height-1 {
+					t.Errorf("%s: last block %d before setting %d, another pool overwrote it", pool, last.height, height)
+					return
+				}
+				tracker.setLastBlock(pool, block{height: height})
+				_ = tracker.all()
+			}
+		}(pool)
+	}
+	wg.Wait()
+
+	for _, pool := range pools {
+		if got := tracker.getLastBlock(pool).height; got != updates {
+			t.Errorf("%s: last block %d, want %d", pool, got, updates)
+		}
+	}
+}
+
+func TestBlockTrackerUnknownPool(t *testing.T) {
+	tracker := newBlockTracker()
+	tracker.setLastBlock("mini", block{height: 1})
+	if got := tracker.getLastBlock("main"); got.height != 0 {
+		t.Errorf("unknown pool has block %d", got.height)
+	}
+}
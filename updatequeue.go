@@ -0,0 +1,62 @@
+package main
+
+import (
+	"sync/atomic"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// updateQueue hands Telegram updates to the router. tgbotapi fetches them
+// into a channel of bot.Buffer updates and stops fetching while it is full,
+// so a backlog after downtime stays with Telegram rather than in memory. It
+// still has to be worked through, so while the channel is full plain
+// messages and button presses are dropped to get to the commands sooner.
+type updateQueue struct {
+	updates tgbotapi.UpdatesChannel
+	dropped atomic.Int64
+}
+
+func newUpdateQueue(updates tgbotapi.UpdatesChannel) *updateQueue {
+	return &updateQueue{updates: updates}
+}
+
+// next returns the next update worth handling, false once the channel is
+// closed.
+func (q *updateQueue) next() (tgbotapi.Update, bool) {
+	for update := range q.updates {
+		if len(q.updates) < cap(q.updates) || !droppableUpdate(update) {
+			return update, true
+		}
+		q.dropped.Add(1)
+	}
+	return tgbotapi.Update{}, false
+}
+
+// depth is the number of updates waiting, 0 for a nil queue.
+func (q *updateQueue) depth() int {
+	if q == nil {
+		return 0
+	}
+	return len(q.updates)
+}
+
+func (q *updateQueue) droppedCount() int64 {
+	if q == nil {
+		return 0
+	}
+	return q.dropped.Load()
+}
+
+// droppableUpdate reports whether update is chatter that can be lost under
+// load: a message that isn't a command or a group migration, or a button
+// press. Anything else, such as the bot being added to a chat, is kept.
+func droppableUpdate(update tgbotapi.Update) bool {
+	switch {
+	case update.Message != nil:
+		return !update.Message.IsCommand() && update.Message.MigrateToChatID == 0
+	case update.CallbackQuery != nil:
+		return true
+	default:
+		return false
+	}
+}
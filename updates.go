@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// knownUpdateTypes are the Telegram update types the bot can handle.
+var knownUpdateTypes = []string{"message", "callback_query", "my_chat_member"}
+
+// neededUpdates maps the update types the enabled features rely on to the
+// feature, for error messages.
+func (c config) neededUpdates() map[string]string {
+	needed := map[string]string{"message": "commands"}
+	if !c.DisableAckButtons {
+		needed["callback_query"] = "the acknowledgment button"
+	} else if len(c.AdminIDs) > 0 {
+		needed["callback_query"] = "the buttons of /reset and /subscribers"
+	}
+	if !c.DisableChatOnboarding {
+		needed["my_chat_member"] = "greeting groups and channels"
+	}
+	return needed
+}
+
+// allowedUpdates is the allowed_updates list to ask Telegram for:
+// AllowedUpdates if set, else what the enabled features need.
+func (c config) allowedUpdates() []string {
+	if len(c.AllowedUpdates) > 0 {
+		return c.AllowedUpdates
+	}
+
+	needed := c.neededUpdates()
+	var types []string
+	for _, t := range knownUpdateTypes {
+		if _, ok := needed[t]; ok {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+func (c config) validateAllowedUpdates() []error {
+	if len(c.AllowedUpdates) == 0 {
+		return nil
+	}
+
+	var problems []error
+	for _, t := range c.AllowedUpdates {
+		if !slices.Contains(knownUpdateTypes, t) {
+			problems = append(problems, fmt.Errorf("AllowedUpdates: the bot doesn't handle %q updates", t))
+		}
+	}
+	needed := c.neededUpdates()
+	for _, t := range knownUpdateTypes {
+		if feature, ok := needed[t]; ok && !slices.Contains(c.AllowedUpdates, t) {
+			problems = append(problems, fmt.Errorf("AllowedUpdates: %s needs %q updates, add them or turn the feature off", feature, t))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAllowedUpdates(t *testing.T) {
+	tests := []struct {
+		name string
+		conf config
+		want []string
+	}{
+		{
+			name: "everything on",
+			conf: config{},
+			want: []string{"message", "callback_query", "my_chat_member"},
+		},
+		{
+			name: "no ack buttons, no admins",
+			conf: config{DisableAckButtons: true},
+			want: []string{"message", "my_chat_member"},
+		},
+		{
+			name: "no ack buttons, admins",
+			conf: config{DisableAckButtons: true, AdminIDs: []int64{7}},
+			want: []string{"message", "callback_query", "my_chat_member"},
+		},
+		{
+			name: "no onboarding",
+			conf: config{DisableChatOnboarding: true},
+			want: []string{"message", "callback_query"},
+		},
+		{
+			name: "commands only",
+			conf: config{DisableAckButtons: true, DisableChatOnboarding: true},
+			want: []string{"message"},
+		},
+		{
+			name: "configured",
+			conf: config{AllowedUpdates: []string{"message", "callback_query", "my_chat_member", "edited_message"}},
+			want: []string{"message", "callback_query", "my_chat_member", "edited_message"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.conf.allowedUpdates(); !slices.Equal(got, tt.want) {
+				t.Errorf("allowed updates = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateAllowedUpdates(t *testing.T) {
+	tests := []struct {
+		name         string
+		conf         config
+		wantProblems int
+	}{
+		{name: "derived", conf: config{}},
+		{name: "all known", conf: config{AllowedUpdates: []string{"message", "callback_query", "my_chat_member"}}},
+		{name: "unknown type", conf: config{AllowedUpdates: []string{"message", "callback_query", "my_chat_member", "poll"}}, wantProblems: 1},
+		{name: "buttons left out", conf: config{AllowedUpdates: []string{"message", "my_chat_member"}}, wantProblems: 1},
+		{name: "buttons off", conf: config{DisableAckButtons: true, AllowedUpdates: []string{"message", "my_chat_member"}}},
+		{name: "commands left out", conf: config{DisableAckButtons: true, DisableChatOnboarding: true, AllowedUpdates: []string{"callback_query"}}, wantProblems: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if problems := tt.conf.validateAllowedUpdates(); len(problems) != tt.wantProblems {
+				t.Errorf("problems = %v, want %d", problems, tt.wantProblems)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// degradedAfter and downAfter are the numbers of failed polls in a row at
+// which /uptime calls the pool API degraded and down.
+const (
+	degradedAfter = 1
+	downAfter     = 3
+)
+
+// runStats counts what happened since the bot started. It is kept in
+// memory only.
+type runStats struct {
+	blocksFound        atomic.Int64
+	notificationsSent  atomic.Int64
+	notificationErrors atomic.Int64
+	// sentByPriority counts the notifications sent per sendPriority.
+	sentByPriority [priorityBulk + 1]atomic.Int64
+
+	polls        atomic.Int64
+	pollNanos    atomic.Int64
+	failedInARow atomic.Int64
+}
+
+func (s *runStats) recordPoll(took time.Duration) {
+	s.polls.Add(1)
+	s.pollNanos.Add(int64(took))
+}
+
+func (s *runStats) recordFetch(err error) {
+	if err != nil {
+		s.failedInARow.Add(1)
+	} else {
+		s.failedInARow.Store(0)
+	}
+}
+
+func (s *runStats) averagePoll() time.Duration {
+	polls := s.polls.Load()
+	if polls == 0 {
+		return 0
+	}
+	return time.Duration(s.pollNanos.Load() / polls)
+}
+
+func (s *runStats) apiStatus() string {
+	switch failed := s.failedInARow.Load(); {
+	case s.polls.Load() == 0:
+		return "нет данных"
+	case failed >= downAfter:
+		return "недоступен"
+	case failed >= degradedAfter:
+		return "сбои"
+	default:
+		return "работает"
+	}
+}
+
+// cmdUptime implements /uptime.
+func (r *commandRouter) cmdUptime(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	s := &r.notifier.stats
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Бот работает %s\n", time.Since(r.notifier.startedAt).Round(time.Second))
+	fmt.Fprintf(&sb, "Найдено блоков: %d\n", s.blocksFound.Load())
+	fmt.Fprintf(&sb, "Отправлено уведомлений: %d, ошибок: %d\n", s.notificationsSent.Load(), s.notificationErrors.Load())
+	fmt.Fprintf(&sb, "Средний опрос API: %s\n", s.averagePoll().Round(time.Millisecond))
+	fmt.Fprintf(&sb, "API пула: %s", s.apiStatus())
+	return reply(msg, sb.String())
+}
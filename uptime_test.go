@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIStatus(t *testing.T) {
+	failure := errors.New("timeout")
+
+	tests := []struct {
+		name    string
+		fetches []error
+		want    string
+	}{
+		{"never polled", nil, "нет данных"},
+		{"fine", []error{nil, nil}, "работает"},
+		{"one failure", []error{nil, failure}, "сбои"},
+		{"down", []error{failure, failure, failure}, "недоступен"},
+		{"recovered", []error{failure, failure, failure, nil}, "работает"},
+	}
+
+	for _, tt := range tests {
+		var s runStats
+		for _, err := range tt.fetches {
+			s.recordPoll(time.Second)
+			s.recordFetch(err)
+		}
+		if got := s.apiStatus(); got != tt.want {
+			t.Errorf("%s: apiStatus = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAveragePoll(t *testing.T) {
+	var s runStats
+	if got := s.averagePoll(); got != 0 {
+		t.Errorf("average of no polls = %s", got)
+	}
+	for _, d := range []time.Duration{100 * time.Millisecond, 300 * time.Millisecond} {
+		s.recordPoll(d)
+	}
+	if got := s.averagePoll(); got != 200*time.Millisecond {
+		t.Errorf("averagePoll = %s, want 200ms", got)
+	}
+}
+
+func TestUptimeCounters(t *testing.T) {
+	tg := newFakeTelegram(t)
+	tg.failFor(2)
+	store := newTestStore(t)
+	store.Add(1)
+	store.Add(2)
+	store.Add(3)
+	pool := poolConfig{Name: defaultPoolName, URL: newPoolAPI(t, http.StatusOK, blocksJSON(101, 100))}
+	n := newTestNotifier(t, config{Pools: []poolConfig{pool}, PruneAfterFailures: 5}, store)
+	n.bot = tg.bot(t)
+	n.lastBlocks.setLastBlock(pool.Name, block{height: 100})
+
+	if err := n.tryNotifyIfNewBlock(context.Background(), pool); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := n.stats.blocksFound.Load(); got != 1 {
+		t.Errorf("blocks found = %d, want 1", got)
+	}
+	if got := n.stats.notificationsSent.Load(); got != 2 {
+		t.Errorf("notifications sent = %d, want 2", got)
+	}
+	if got := n.stats.notificationErrors.Load(); got != 1 {
+		t.Errorf("notification errors = %d, want 1", got)
+	}
+
+	r := newCommandRouter(n.bot, store, n, n.usage, config{})
+	text := r.cmdUptime(command(1, "/uptime")).Text
+	for _, want := range []string{"Найдено блоков: 1", "Отправлено уведомлений: 2, ошибок: 1"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("/uptime = %q, lacks %q", text, want)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// usageFlushInterval is how often counted usage is written to the state.
+const usageFlushInterval = time.Minute
+
+// usageCounters are anonymous usage statistics. They never contain message
+// contents, usernames or chat IDs, only how often things happen.
+type usageCounters struct {
+	Commands      map[string]int64 `json:"commands,omitempty"`
+	Notifications int64            `json:"notifications,omitempty"`
+	// Subscribes and Unsubscribes count chats subscribing and opting out
+	// again, by command or by adding and removing the bot in channels.
+	Subscribes   int64 `json:"subscribes,omitempty"`
+	Unsubscribes int64 `json:"unsubscribes,omitempty"`
+}
+
+// usageStats counts usage into the state store unless disabled. Counts
+// are kept in memory and added to the state by flush, so a busy broadcast
+// doesn't rewrite the state file for every message.
+type usageStats struct {
+	state    *stateStore
+	disabled bool
+	// sources are the deep-link sources counted by name.
+	sources map[string]bool
+
+	mu      sync.Mutex
+	pending usageCounters
+}
+
+func (u *usageStats) countCommand(name string) {
+	u.count(func(c *usageCounters) {
+		if c.Commands == nil {
+			c.Commands = make(map[string]int64)
+		}
+		c.Commands[name]++
+	})
+}
+
+func (u *usageStats) countNotification() {
+	u.count(func(c *usageCounters) {
+		c.Notifications++
+	})
+}
+
+func (u *usageStats) countSubscribe() {
+	u.count(func(c *usageCounters) {
+		c.Subscribes++
+	})
+}
+
+func (u *usageStats) countUnsubscribe() {
+	u.count(func(c *usageCounters) {
+		c.Unsubscribes++
+	})
+}
+
+func (u *usageStats) count(fn func(c *usageCounters)) {
+	if u.disabled {
+		return
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	fn(&u.pending)
+}
+
+// add adds the counts of other to c.
+func (c *usageCounters) add(other usageCounters) {
+	c.Notifications += other.Notifications
+	c.Subscribes += other.Subscribes
+	c.Unsubscribes += other.Unsubscribes
+	for name, count := range other.Commands {
+		if c.Commands == nil {
+			c.Commands = make(map[string]int64)
+		}
+		c.Commands[name] += count
+	}
+}
+
+// flush adds the counts since the last flush to the state. They are kept
+// for the next one if the state can't be written.
+func (u *usageStats) flush() {
+	u.mu.Lock()
+	pending := u.pending
+	u.pending = usageCounters{}
+	u.mu.Unlock()
+
+	if pending.Notifications == 0 && pending.Subscribes == 0 && pending.Unsubscribes == 0 && len(pending.Commands) == 0 {
+		return
+	}
+	err := u.state.update(func(st *state) {
+		st.Usage.add(pending)
+	})
+	if err != nil {
+		log.Printf("error: save usage stats: %s", err.Error())
+		u.count(func(c *usageCounters) { c.add(pending) })
+	}
+}
+
+// flushWorker flushes every interval until ctx is done. main flushes once
+// more on shutdown.
+func (u *usageStats) flushWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			u.flush()
+		}
+	}
+}
+
+// sourceOther counts deep-link sources that aren't in StartSources.
+const sourceOther = "other"
+
+// recordSource notes which deep link, t.me/<bot>?start=<source>, brought a
+// chat in. Only the first one counts.
+func (u *usageStats) recordSource(id int64, source string) {
+	if u.disabled || source == "" {
+		return
+	}
+	if !u.sources[source] {
+		source = sourceOther
+	}
+
+	err := u.state.update(func(st *state) {
+		if _, ok := st.Sources[id]; ok {
+			return
+		}
+		if st.Sources == nil {
+			st.Sources = make(map[int64]string)
+		}
+		st.Sources[id] = source
+	})
+	if err != nil {
+		log.Printf("error: save source of %s: %s", chatRef(id), err.Error())
+	}
+}
+
+func (u *usageStats) report() string {
+	if u.disabled {
+		return "Сбор статистики отключён в конфигурации"
+	}
+
+	var c usageCounters
+	bySource := make(map[string]int)
+	u.state.view(func(st state) {
+		for _, source := range st.Sources {
+			bySource[source]++
+		}
+		c.add(st.Usage)
+	})
+	u.mu.Lock()
+	c.add(u.pending)
+	u.mu.Unlock()
+
+	names := make([]string, 0, len(c.Commands))
+	for name := range c.Commands {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return c.Commands[names[i]] > c.Commands[names[j]]
+	})
+
+	var sb strings.Builder
+	sb.WriteString("Команды:\n")
+	for _, name := range names {
+		fmt.Fprintf(&sb, "/%s — %d\n", name, c.Commands[name])
+	}
+	fmt.Fprintf(&sb, "Отправлено уведомлений: %d\n", c.Notifications)
+	fmt.Fprintf(&sb, "Подписок: %d, отписок: %d", c.Subscribes, c.Unsubscribes)
+	if c.Subscribes > 0 {
+		fmt.Fprintf(&sb, " (%.0f%% от числа подписок)", 100*float64(c.Unsubscribes)/float64(c.Subscribes))
+	}
+
+	if len(bySource) > 0 {
+		sources := make([]string, 0, len(bySource))
+		for source := range bySource {
+			sources = append(sources, source)
+		}
+		sort.Slice(sources, func(i, j int) bool {
+			return bySource[sources[i]] > bySource[sources[j]]
+		})
+
+		sb.WriteString("\n\nПодписки по ссылкам:\n")
+		for _, source := range sources {
+			fmt.Fprintf(&sb, "%s — %d\n", source, bySource[source])
+		}
+	}
+
+	return sb.String()
+}
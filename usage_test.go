@@ -0,0 +1,143 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUsageCountsInMemoryUntilFlush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	st, err := loadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := &usageStats{state: st}
+
+	for i := 0; i < 100; i++ {
+		u.countNotification()
+	}
+	u.countCommand("start")
+	u.countCommand("start")
+	u.countCommand("pools")
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("state file written before flush: %v", err)
+	}
+	if report := u.report(); !strings.Contains(report, "/start — 2") || !strings.Contains(report, "уведомлений: 100") {
+		t.Errorf("report before flush misses pending counts:\n%s", report)
+	}
+
+	u.flush()
+	u.countCommand("start")
+	u.flush()
+
+	reloaded, err := loadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded.view(func(st state) {
+		if st.Usage.Notifications != 100 {
+			t.Errorf("notifications = %d, want 100", st.Usage.Notifications)
+		}
+		if got := st.Usage.Commands["start"]; got != 3 {
+			t.Errorf("start = %d, want 3", got)
+		}
+		if got := st.Usage.Commands["pools"]; got != 1 {
+			t.Errorf("pools = %d, want 1", got)
+		}
+	})
+}
+
+func TestUsageDisabledCountsNothing(t *testing.T) {
+	st, _ := loadState("")
+	u := &usageStats{state: st, disabled: true}
+	u.countNotification()
+	u.countCommand("start")
+	u.flush()
+
+	st.view(func(st state) {
+		if st.Usage.Notifications != 0 || len(st.Usage.Commands) != 0 {
+			t.Errorf("disabled stats counted %+v", st.Usage)
+		}
+	})
+}
+
+func TestUsageReportsOptOutRate(t *testing.T) {
+	store := newTestStore(t)
+	n := newTestNotifier(t, config{}, store)
+	usage := &usageStats{state: n.state}
+	r := newCommandRouter(nil, store, n, usage, config{})
+
+	for id := int64(1); id <= 4; id++ {
+		r.cmdStart(command(id, "/start"))
+	}
+	// Subscribing twice is one subscription.
+	r.cmdStart(command(1, "/start"))
+	r.cmdUnsubscribe(command(2, "/stop"))
+	// Not subscribed, nothing to opt out of.
+	r.cmdUnsubscribe(command(5, "/stop"))
+
+	usage.flush()
+	n.state.view(func(st state) {
+		if st.Usage.Subscribes != 4 || st.Usage.Unsubscribes != 1 {
+			t.Errorf("saved %d subscribes and %d unsubscribes, want 4 and 1", st.Usage.Subscribes, st.Usage.Unsubscribes)
+		}
+	})
+	if want := "Подписок: 4, отписок: 1 (25% от числа подписок)"; !strings.Contains(usage.report(), want) {
+		t.Errorf("report lacks %q:\n%s", want, usage.report())
+	}
+}
+
+func TestUsageAttributesStartSources(t *testing.T) {
+	store := newTestStore(t)
+	n := newTestNotifier(t, config{}, store)
+	usage := &usageStats{state: n.state, sources: map[string]bool{"site": true, "forum": true}}
+	r := newCommandRouter(nil, store, n, usage, config{})
+
+	for id, text := range map[int64]string{
+		1: "/start site",
+		2: "/start site",
+		3: "/start forum",
+		4: "/start reddit",
+		5: "/start",
+		6: "/subscribe site",
+	} {
+		r.cmdStart(command(id, text))
+	}
+	// A chat keeps the link it first came in through.
+	r.cmdStart(command(1, "/start forum"))
+
+	want := map[int64]string{1: "site", 2: "site", 3: "forum", 4: sourceOther}
+	n.state.view(func(st state) {
+		if !maps.Equal(st.Sources, want) {
+			t.Errorf("sources = %v, want %v", st.Sources, want)
+		}
+	})
+	report := usage.report()
+	if want := "Подписки по ссылкам:\nsite — 2\n"; !strings.Contains(report, want) {
+		t.Errorf("report lacks %q:\n%s", want, report)
+	}
+	for _, want := range []string{"forum — 1\n", "other — 1\n"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report lacks %q:\n%s", want, report)
+		}
+	}
+}
+
+func TestUsageDisabledRecordsNoSource(t *testing.T) {
+	store := newTestStore(t)
+	n := newTestNotifier(t, config{}, store)
+	usage := &usageStats{state: n.state, disabled: true, sources: map[string]bool{"site": true}}
+	r := newCommandRouter(nil, store, n, usage, config{})
+
+	r.cmdStart(command(1, "/start site"))
+
+	n.state.view(func(st state) {
+		if len(st.Sources) != 0 {
+			t.Errorf("disabled stats recorded sources %v", st.Sources)
+		}
+	})
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const verifyHistoryInterval = 7 * 24 * time.Hour
+
+// historyMismatch is a block of the local history that the API disagrees
+// with. apiHash is empty if the API lacks the height.
+type historyMismatch struct {
+	height             int
+	localHash, apiHash string
+}
+
+func (m historyMismatch) String() string {
+	if m.apiHash == "" {
+		return fmt.Sprintf("%d: нет в API (локально %s)", m.height, shortHash(m.localHash))
+	}
+	return fmt.Sprintf("%d: хеш %s, в API %s", m.height, shortHash(m.localHash), shortHash(m.apiHash))
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12] + "…"
+	}
+	return hash
+}
+
+// compareHistory checks the blocks of local, oldest first, against api,
+// newest first, over the heights both cover. Only the blocks local has are
+// compared: it may lack some in between, e.g. after a restart, and those
+// are no mismatch.
+func compareHistory(local, api []block) []historyMismatch {
+	if len(local) == 0 || len(api) == 0 {
+		return nil
+	}
+
+	lo := max(local[0].height, api[len(api)-1].height)
+	hi := min(local[len(local)-1].height, api[0].height)
+
+	apiByHeight := make(map[int]block, len(api))
+	for _, b := range api {
+		if b.height >= lo && b.height <= hi {
+			apiByHeight[b.height] = b
+		}
+	}
+
+	var mismatches []historyMismatch
+	for _, b := range local {
+		if b.height < lo || b.height > hi {
+			continue
+		}
+		a, ok := apiByHeight[b.height]
+		switch {
+		case !ok:
+			mismatches = append(mismatches, historyMismatch{height: b.height, localHash: b.hash})
+		case a.hash != "" && b.hash != "" && a.hash != b.hash:
+			mismatches = append(mismatches, historyMismatch{height: b.height, localHash: b.hash, apiHash: a.hash})
+		}
+	}
+	return mismatches
+}
+
+// verifyHistory compares the history of every pool with its API and, if
+// repair is set, takes the API's blocks over the overlapping range. It
+// returns a report for admins.
+func (n *Notifier) verifyHistory(repair bool) string {
+	var sb strings.Builder
+	for _, pool := range n.pools {
+		api, err := fetchRecentBlocks(pool.URL)
+		if err != nil {
+			log.Printf("error: %s: verify history: %s", pool.Name, err.Error())
+			fmt.Fprintf(&sb, "%s: API недоступно\n", pool.Name)
+			continue
+		}
+
+		local := n.history[pool.Name].All()
+		mismatches := compareHistory(local, api)
+		log.Printf("%s: history verified, %d local blocks, %d mismatches", pool.Name, len(local), len(mismatches))
+		if len(mismatches) == 0 {
+			fmt.Fprintf(&sb, "%s: история сходится с API (%d блоков)\n", pool.Name, len(local))
+			continue
+		}
+
+		fmt.Fprintf(&sb, "%s: расхождений с API: %d\n", pool.Name, len(mismatches))
+		for _, m := range mismatches {
+			fmt.Fprintf(&sb, "  %s\n", m)
+		}
+
+		if repair {
+			n.repairHistory(pool, local, api, mismatches)
+			sb.WriteString("  исправлено по данным API\n")
+		}
+	}
+	return sb.String()
+}
+
+// repairHistory replaces the overlapping range of the local history with
+// the API's blocks, journaling every change.
+func (n *Notifier) repairHistory(pool poolConfig, local, api []block, mismatches []historyMismatch) {
+	lo := max(local[0].height, api[len(api)-1].height)
+	hi := min(local[len(local)-1].height, api[0].height)
+
+	var repaired []block
+	for _, b := range local {
+		if b.height < lo || b.height > hi {
+			repaired = append(repaired, b)
+		}
+	}
+	for _, b := range api {
+		if b.height >= lo && b.height <= hi {
+			repaired = append(repaired, b)
+		}
+	}
+	sort.Slice(repaired, func(i, j int) bool { return repaired[i].height < repaired[j].height })
+
+	history := newRingBuffer(historySize)
+	for _, b := range repaired {
+		history.Push(b)
+	}
+	n.history[pool.Name].replace(history)
+
+	for _, m := range mismatches {
+		n.journalRepair(fmt.Sprintf("%s pool=%s height=%d local_hash=%q api_hash=%q",
+			time.Now().UTC().Format(time.RFC3339), pool.Name, m.height, m.localHash, m.apiHash))
+	}
+}
+
+// journalRepair records a history repair in the log and, with a state file,
+// in <StateFile>.repairs for auditing.
+func (n *Notifier) journalRepair(entry string) {
+	log.Printf("history repair: %s", entry)
+	if n.state.path == "" {
+		return
+	}
+
+	file, err := os.OpenFile(n.state.path+".repairs", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Printf("error: open repair journal: %s", err.Error())
+		return
+	}
+	defer file.Close()
+
+	if _, err := fmt.Fprintln(file, entry); err != nil {
+		log.Printf("error: write repair journal: %s", err.Error())
+	}
+}
+
+// verifyWorker checks the history against the APIs every week and tells
+// the admins.
+func (n *Notifier) verifyWorker(ctx context.Context) {
+	ticker := time.NewTicker(verifyHistoryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report := n.verifyHistory(false)
+			for id := range n.admins {
+				for _, part := range splitMessage("Еженедельная проверка истории блоков:\n"+report, maxMessageLength) {
+					if _, err := n.bot.Send(tgbotapi.NewMessage(id, part)); err != nil {
+						log.Printf("error: send history report to %s: %s", chatRef(id), err.Error())
+					}
+				}
+			}
+		}
+	}
+}
+
+// cmdVerifyHistory implements /verifyhistory [repair].
+func (r *commandRouter) cmdVerifyHistory(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	repair := strings.TrimSpace(msg.CommandArguments()) == "repair"
+	if repair {
+		log.Printf("history repair requested by %s", chatRef(msg.From.ID))
+		r.notifier.audit.record(actorOf(msg), "repair_history", nil)
+	}
+	return r.replyLong(msg, r.notifier.verifyHistory(repair))
+}
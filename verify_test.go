@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCompareHistory(t *testing.T) {
+	hashed := func(height int, hash string) block { return block{height: height, hash: hash} }
+
+	tests := []struct {
+		name  string
+		local []block
+		// api is newest first.
+		api  []block
+		want []historyMismatch
+	}{
+		{
+			name:  "same",
+			local: []block{hashed(1, "a"), hashed(2, "b")},
+			api:   []block{hashed(2, "b"), hashed(1, "a")},
+		},
+		{
+			name:  "local lacks blocks in between",
+			local: []block{hashed(1, "a"), hashed(4, "d")},
+			api:   []block{hashed(4, "d"), hashed(3, "c"), hashed(2, "b"), hashed(1, "a")},
+		},
+		{
+			name:  "API covers more than local",
+			local: []block{hashed(3, "c")},
+			api:   []block{hashed(5, "e"), hashed(4, "d"), hashed(3, "c"), hashed(2, "b")},
+		},
+		{
+			name:  "different hash",
+			local: []block{hashed(1, "a"), hashed(2, "x")},
+			api:   []block{hashed(2, "b"), hashed(1, "a")},
+			want:  []historyMismatch{{height: 2, localHash: "x", apiHash: "b"}},
+		},
+		{
+			name:  "orphaned locally",
+			local: []block{hashed(1, "a"), hashed(2, "x"), hashed(3, "c")},
+			api:   []block{hashed(3, "c"), hashed(1, "a")},
+			want:  []historyMismatch{{height: 2, localHash: "x"}},
+		},
+		{
+			name:  "older than the API",
+			local: []block{hashed(1, "x"), hashed(5, "e")},
+			api:   []block{hashed(5, "e"), hashed(4, "d")},
+		},
+		{
+			name: "no local history",
+			api:  []block{hashed(5, "e")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compareHistory(tt.local, tt.api); !slices.Equal(got, tt.want) {
+				t.Errorf("compareHistory = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyHistoryReportSplit(t *testing.T) {
+	const admin = 100
+
+	api := make([]int, historySize)
+	for i := range api {
+		api[i] = 1000 + historySize - i
+	}
+	tg := newFakeTelegram(t)
+	conf := config{AdminIDs: []int64{admin}, Pools: []poolConfig{{Name: defaultPoolName, URL: newPoolAPI(t, http.StatusOK, blocksJSON(api...))}}}
+	store := newTestStore(t)
+	n := newTestNotifier(t, conf, store)
+	n.bot = tg.bot(t)
+	for i := len(api) - 1; i >= 0; i-- {
+		n.history[defaultPoolName].Push(block{height: api[i], hash: "forked"})
+	}
+	r := newCommandRouter(n.bot, store, n, n.usage, conf)
+
+	r.handle(command(admin, "/verifyhistory"))
+
+	texts := tg.waitForTexts(t, 2)
+	report := strings.Join(texts, "")
+	if !strings.Contains(report, "расхождений с API: 200") {
+		t.Errorf("report = %.100q…, want 200 mismatches", report)
+	}
+	for _, text := range texts {
+		if n := utf8.RuneCountInString(text); n > maxMessageLength {
+			t.Errorf("a message of %d runes, over the Telegram limit", n)
+		}
+	}
+}
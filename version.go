@@ -0,0 +1,18 @@
+package main
+
+import "strings"
+
+// version is set at build time with -ldflags "-X main.version=v1.2.3".
+var version = "dev"
+
+const projectURL = "github.com/ArtyomArtamonov/p2pool-tg-notifier"
+
+// userAgent is the User-Agent of requests to the pool APIs, with the
+// operator's suffix, e.g. contact details, appended.
+func userAgent(suffix string) string {
+	ua := "p2pool-tg-notifier/" + version + " (" + projectURL + ")"
+	if suffix = strings.TrimSpace(suffix); suffix != "" {
+		ua += " " + suffix
+	}
+	return ua
+}
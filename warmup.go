@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// waitWarmup holds a broadcast back until InitialBroadcastDelay has passed
+// since startup, so a block found while the bot restarts isn't announced
+// before the store and rate limiter have settled.
+func (n *Notifier) waitWarmup(ctx context.Context, l *slog.Logger) error {
+	wait := time.Until(n.startedAt.Add(n.initialDelay))
+	if wait <= 0 {
+		return nil
+	}
+
+	l.Info("broadcast deferred until warmed up", "delay", wait)
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFirstBroadcastWaitsForWarmup(t *testing.T) {
+	const delay = 200 * time.Millisecond
+
+	tests := []struct {
+		name      string
+		startedAt time.Duration // before now
+		cancel    bool
+		wantWait  time.Duration
+		wantSent  int
+		wantErr   error
+	}{
+		{name: "just started", wantWait: delay, wantSent: 1},
+		{name: "partly warmed up", startedAt: delay / 2, wantWait: delay / 2, wantSent: 1},
+		{name: "warmed up", startedAt: time.Minute, wantSent: 1},
+		{name: "shut down while waiting", cancel: true, wantErr: context.Canceled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tg := newFakeTelegram(t)
+			store := newTestStore(t)
+			store.Add(1)
+			n := newTestNotifier(t, config{InitialBroadcastDelay: delay.String()}, store)
+			n.bot = tg.bot(t)
+			n.startedAt = time.Now().Add(-tt.startedAt)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				time.AfterFunc(delay/4, cancel)
+			}
+
+			began := time.Now()
+			err := n.broadcast(ctx, poolConfig{Name: defaultPoolName}, []block{{height: 100, ts: time.Now()}}, func(int64) string { return "block" })
+			took := time.Since(began)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			// Leave some slack for the clock reads around startedAt.
+			if took < tt.wantWait-20*time.Millisecond {
+				t.Errorf("broadcast went out after %s, want at least %s", took, tt.wantWait)
+			}
+			if tt.wantWait == 0 && took > delay/2 {
+				t.Errorf("broadcast took %s after warmup", took)
+			}
+			if got := len(tg.sentTo()); got != tt.wantSent {
+				t.Errorf("sent %d messages, want %d", got, tt.wantSent)
+			}
+		})
+	}
+}
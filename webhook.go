@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWebhookTimeout     = 10 * time.Second
+	defaultWebhookConcurrency = 4
+)
+
+type webhookPayload struct {
+	Pool      string    `json:"pool"`
+	Height    int       `json:"height"`
+	Timestamp time.Time `json:"ts"`
+}
+
+// webhookDispatcher POSTs found blocks to the configured URLs, at most
+// concurrency at a time and each bounded by its own timeout, so one slow
+// endpoint can't hold up the others.
+type webhookDispatcher struct {
+	urls    []string
+	timeout time.Duration
+	sem     chan struct{}
+	client  *http.Client
+}
+
+func newWebhookDispatcher(urls []string, timeout time.Duration, concurrency int) *webhookDispatcher {
+	return &webhookDispatcher{
+		urls:    urls,
+		timeout: timeout,
+		sem:     make(chan struct{}, concurrency),
+		client:  &http.Client{},
+	}
+}
+
+// deliver sends payload to every webhook and returns the failures once all
+// deliveries have finished.
+func (d *webhookDispatcher) deliver(ctx context.Context, payload webhookPayload) []error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return []error{err}
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, url := range d.urls {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+
+			d.sem <- struct{}{}
+			defer func() { <-d.sem }()
+
+			if err := d.post(ctx, url, body); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("webhook %s: %w", url, err))
+				mu.Unlock()
+			}
+		}(url)
+	}
+	wg.Wait()
+
+	return errs
+}
+
+func (d *webhookDispatcher) post(ctx context.Context, url string, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", res.Status)
+	}
+
+	return nil
+}
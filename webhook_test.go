@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebhookNotRedeliveredAfterRestart(t *testing.T) {
+	var posts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posts.Add(1)
+	}))
+	defer srv.Close()
+
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	store := newTestStore(t)
+	pool := poolConfig{Name: defaultPoolName}
+	// start is a run of the bot, sharing the state file with the previous
+	// ones.
+	start := func() *Notifier {
+		st, err := loadState(statePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		webhooks := newWebhookDispatcher([]string{srv.URL}, time.Second, 1)
+		return newNotifier(nil, store, config{}, &usageStats{state: st, disabled: true}, st, webhooks, nil)
+	}
+
+	steps := []struct {
+		name      string
+		restart   bool
+		height    int
+		wantPosts int32
+	}{
+		{"first delivery", false, 100, 1},
+		{"same block after restart", true, 100, 1},
+		{"next block", false, 101, 2},
+		{"next block after restart", true, 101, 2},
+	}
+
+	n := start()
+	for _, step := range steps {
+		if step.restart {
+			n = start()
+		}
+		n.deliverWebhooks(context.Background(), pool, block{height: step.height, ts: time.Now()})
+		if got := posts.Load(); got != step.wantPosts {
+			t.Fatalf("%s: %d webhook posts, want %d", step.name, got, step.wantPosts)
+		}
+	}
+}
+
+func TestWebhookSlowAndFast(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer slow.Close()
+
+	tests := []struct {
+		name        string
+		concurrency int
+	}{
+		{"in parallel", 2},
+		// The fast one waits for the slow one's slot, but not longer than
+		// its timeout.
+		{"one at a time", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got atomic.Value
+			fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var payload webhookPayload
+				json.NewDecoder(r.Body).Decode(&payload)
+				got.Store(payload)
+			}))
+			defer fast.Close()
+
+			d := newWebhookDispatcher([]string{slow.URL, fast.URL}, timeout, tt.concurrency)
+			started := time.Now()
+			errs := d.deliver(context.Background(), webhookPayload{Pool: "mini", Height: 100})
+			took := time.Since(started)
+
+			if payload, ok := got.Load().(webhookPayload); !ok || payload.Height != 100 {
+				t.Errorf("fast webhook got %+v, want block 100", got.Load())
+			}
+			if len(errs) != 1 || !errors.Is(errs[0], context.DeadlineExceeded) {
+				t.Errorf("errors = %v, want the slow webhook timed out", errs)
+			}
+			if took > time.Second {
+				t.Errorf("delivery took %s, want the slow webhook cut off after %s", took, timeout)
+			}
+		})
+	}
+}
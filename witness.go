@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// witnessStats is what a chat went through as a subscriber.
+type witnessStats struct {
+	Since time.Time `json:"since"`
+	// Blocks counts the blocks the chat was told about, each block of a
+	// batch included.
+	Blocks int `json:"blocks"`
+	// LastBlockAt is when the last of those blocks was found.
+	LastBlockAt time.Time `json:"last_block_at,omitempty"`
+	// LongestDrought is the longest time between two of those blocks.
+	LongestDrought time.Duration `json:"longest_drought,omitempty"`
+}
+
+// markJoined records when chat id subscribed, unless it did before and
+// its settings were kept.
+func (n *Notifier) markJoined(id int64) {
+	err := n.state.update(func(st *state) {
+		if _, ok := st.Witnessed[id]; ok {
+			return
+		}
+		if st.Witnessed == nil {
+			st.Witnessed = make(map[int64]witnessStats)
+		}
+		st.Witnessed[id] = witnessStats{Since: time.Now()}
+	})
+	if err != nil {
+		log.Printf("error: save join date of %s: %s", chatRef(id), err.Error())
+	}
+}
+
+// recordWitnessed counts blocks, oldest first, for the chats that were
+// delivered the notification about them.
+func (n *Notifier) recordWitnessed(ids []int64, blocks []block) {
+	err := n.state.update(func(st *state) {
+		if st.Witnessed == nil {
+			st.Witnessed = make(map[int64]witnessStats)
+		}
+		for _, id := range ids {
+			w := st.Witnessed[id]
+			if w.Since.IsZero() {
+				// Subscribed before join dates were kept.
+				w.Since = time.Now()
+			}
+			for _, b := range blocks {
+				if !w.LastBlockAt.IsZero() && b.ts.After(w.LastBlockAt) {
+					w.LongestDrought = max(w.LongestDrought, b.ts.Sub(w.LastBlockAt))
+				}
+				w.LastBlockAt = b.ts
+				w.Blocks++
+			}
+			st.Witnessed[id] = w
+		}
+	})
+	if err != nil {
+		log.Printf("error: save witnessed blocks: %s", err.Error())
+	}
+}
+
+func (w witnessStats) String(now time.Time) string {
+	text := fmt.Sprintf("Вы подписаны %d дн. назад, блоков на ваших глазах: %d", int(now.Sub(w.Since).Hours()/24), w.Blocks)
+	if w.LongestDrought > 0 {
+		text += fmt.Sprintf(", самая долгая засуха, которую вы пережили: %s", formatDrought(w.LongestDrought))
+	}
+	return text
+}
+
+// formatDrought shows d in whole hours, or minutes below an hour.
+func formatDrought(d time.Duration) string {
+	if d < time.Hour {
+		return fmt.Sprintf("%dм", int(d.Round(time.Minute).Minutes()))
+	}
+	return fmt.Sprintf("%dч", int(d.Round(time.Hour).Hours()))
+}
+
+// cmdMe implements /me, the chat's subscription streak and totals.
+func (r *commandRouter) cmdMe(msg *tgbotapi.Message) tgbotapi.MessageConfig {
+	var w witnessStats
+	var ok bool
+	r.notifier.state.view(func(st state) { w, ok = st.Witnessed[msg.Chat.ID] })
+	if !ok {
+		return reply(msg, "Пока нечего показать: подпишитесь с /subscribe и дождитесь первого блока")
+	}
+	return reply(msg, w.String(time.Now()))
+}